@@ -0,0 +1,124 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// IPFeedFormatText is a feed with one range per line. Empty lines and
+	// comments starting with # are ignored.
+	IPFeedFormatText = "text"
+	// IPFeedFormatJSON is a JSON document. The ranges are selected with the
+	// JSONPath of the feed.
+	IPFeedFormatJSON = "json"
+
+	IPFamilyIPv4 = "IPv4"
+	IPFamilyIPv6 = "IPv6"
+
+	// ConditionReady is true when the last fetch of the feed succeeded.
+	ConditionReady = "Ready"
+
+	ReasonFetched     = "Fetched"
+	ReasonFetchFailed = "FetchFailed"
+)
+
+// IPFeedSpec defines where the ranges of an IPFeed are fetched from.
+type IPFeedSpec struct {
+	// URL of the feed. Only https is supported.
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// Format of the feed, text or json.
+	// +kubebuilder:validation:Enum=text;json
+	// +kubebuilder:default=text
+	// +optional
+	Format string `json:"format,omitempty"`
+
+	// JSONPath selecting the ranges of json feeds, like
+	// {.prefixes[*].ipv4Prefix}. The selected values must be strings or
+	// lists of strings.
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty"`
+
+	// RefreshInterval is how often the feed is fetched.
+	// +kubebuilder:default="1h"
+	// +optional
+	RefreshInterval metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// Filter selects the ranges of the feed that are used.
+	// +optional
+	Filter *IPFeedFilter `json:"filter,omitempty"`
+}
+
+// IPFeedFilter selects ranges of a feed.
+type IPFeedFilter struct {
+	// IPFamily keeps only IPv4 or IPv6 ranges.
+	// +kubebuilder:validation:Enum=IPv4;IPv6
+	// +optional
+	IPFamily string `json:"ipFamily,omitempty"`
+
+	// Pattern is a regular expression the entries of the feed must match,
+	// like ^13\.
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// IPFeedStatus holds the ranges of the last successful fetch.
+type IPFeedStatus struct {
+	// Ranges of the last successful fetch. They are kept when fetching the
+	// feed fails.
+	// +optional
+	Ranges []string `json:"ranges,omitempty"`
+
+	// LastFetchTime is the time of the last successful fetch.
+	// +optional
+	LastFetchTime *metav1.Time `json:"lastFetchTime,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// IPFeed is a list of IP ranges published by a vendor, like the egress IPs
+// of a CI provider. Allowlists reference it with ipfeed/<name>.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Last Fetch",type=date,JSONPath=`.status.lastFetchTime`
+type IPFeed struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPFeedSpec   `json:"spec,omitempty"`
+	Status IPFeedStatus `json:"status,omitempty"`
+}
+
+// IPFeedList contains a list of IPFeed.
+//
+// +kubebuilder:object:root=true
+type IPFeedList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPFeed `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IPFeed{}, &IPFeedList{})
+}
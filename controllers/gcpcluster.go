@@ -2,56 +2,336 @@ package controllers
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/allowlist"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
-	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
 )
 
-const FinalizerFirewall = "capg-firewall-rule-operator.finalizers.giantswarm.io"
+const (
+	// FinalizerFirewall guarded all managed resources before they got a
+	// finalizer each. Clusters that still have it are cleaned up like
+	// clusters with all of the finalizers below.
+	FinalizerFirewall = "capg-firewall-rule-operator.finalizers.giantswarm.io"
+
+	// AnnotationOrphan set to "true" removes the finalizers of a cluster that
+	// is being deleted without deleting the managed GCP resources.
+	AnnotationOrphan = "capg-firewall-rule-operator.giantswarm.io/orphan"
+
+	// AnnotationRemainingResources lists the sub-reconcilers whose resources
+	// are not deleted yet while the cluster is being deleted.
+	AnnotationRemainingResources = "capg-firewall-rule-operator.giantswarm.io/remaining-resources"
+
+	// AnnotationStatusPrefix is the prefix of the annotations with the
+	// status of each sub-reconciler, like
+	// status.capg-firewall-rule-operator.giantswarm.io/firewall-rules.
+	AnnotationStatusPrefix = "status.capg-firewall-rule-operator.giantswarm.io"
+
+	StatusReady       = "Ready"
+	StatusProgressing = "Progressing"
+	StatusDisabled    = "Disabled"
+	// StatusFailedPrefix is followed by the error of the sub-reconciler.
+	StatusFailedPrefix = "Failed: "
+
+	// RequeueAfterRetryable is how long to wait before reconciling again
+	// when the GCP API was still unavailable after the clients' retries.
+	RequeueAfterRetryable = time.Second * 30
+	// RequeueAfterQuotaExceeded is how long to wait before reconciling again
+	// when a GCP quota or rate limit was exceeded.
+	RequeueAfterQuotaExceeded = time.Minute * 5
+	// RequeueAfterOperationPending is how long to wait before polling the
+	// GCP operations started by a reconciliation.
+	RequeueAfterOperationPending = time.Second * 5
+)
 
 type GCPClusterClient interface {
 	Get(context.Context, types.NamespacedName) (*capg.GCPCluster, error)
+	List(context.Context) ([]capg.GCPCluster, error)
 	GetOwner(context.Context, *capg.GCPCluster) (*capi.Cluster, error)
-	AddFinalizer(context.Context, *capg.GCPCluster, string) error
-	RemoveFinalizer(context.Context, *capg.GCPCluster, string) error
+	AddFinalizer(context.Context, *capg.GCPCluster, ...string) error
+	RemoveFinalizer(context.Context, *capg.GCPCluster, ...string) error
+	SetAnnotations(context.Context, *capg.GCPCluster, map[string]string) error
 }
 
 type GCPClusterReconciler struct {
-	client                   GCPClusterClient
-	firewallRuleReconciler   *firewall.RuleReconciler
-	securityPolicyReconciler *security.PolicyReconciler
+	client       GCPClusterClient
+	resolver     *allowlist.Resolver
+	registry     *Registry
+	operations   *google.OperationTracker
+	reconcileAll chan event.GenericEvent
 }
 
+// NewGCPClusterReconciler creates the reconciler running the sub-reconcilers
+// of the registry in order with the allowlists resolved by resolver.
+// operations must be the tracker the GCP clients use, so that clusters are
+// reconciled again until the operations they started have finished. It may
+// be nil when the clients wait for operations.
 func NewGCPClusterReconciler(
 	client GCPClusterClient,
-	firewallRuleReconciler *firewall.RuleReconciler,
-	securityPolicyReconciler *security.PolicyReconciler,
+	resolver *allowlist.Resolver,
+	registry *Registry,
+	operations *google.OperationTracker,
 ) *GCPClusterReconciler {
 	return &GCPClusterReconciler{
-		client:                   client,
-		firewallRuleReconciler:   firewallRuleReconciler,
-		securityPolicyReconciler: securityPolicyReconciler,
+		client:       client,
+		resolver:     resolver,
+		registry:     registry,
+		operations:   operations,
+		reconcileAll: make(chan event.GenericEvent),
 	}
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *GCPClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+// SetupWithManager registers the reconciler with the manager.
+// maxConcurrentReconciles is the number of clusters reconciled at the same
+// time. Only GCPClusters accepted by all predicates are reconciled, also
+// when they are queued for one of the watches below. GCPClusters are also
+// reconciled when the annotations or the spec of their CAPI Cluster change,
+// since allowlists can be set there, and when ConfigMaps, Secrets or IPFeeds
+// referenced by their allowlists change. Updates that only change the status
+// annotations are ignored.
+func (r *GCPClusterReconciler) SetupWithManager(mgr ctrl.Manager, maxConcurrentReconciles int, predicates ...predicate.Predicate) error {
+	err := allowlist.IndexFieldReferences(context.Background(), mgr.GetFieldIndexer())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	gcpClusterPredicates := append([]predicate.Predicate{IgnoreStatusAnnotationUpdates()}, predicates...)
+	k8sClient := mgr.GetClient()
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&capg.GCPCluster{}).
+		For(&capg.GCPCluster{}, builder.WithPredicates(gcpClusterPredicates...)).
+		Watches(
+			&source.Kind{Type: &capi.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(filterRequests(k8sClient, clusterToGCPCluster, predicates)),
+			builder.WithPredicates(predicate.Or(predicate.AnnotationChangedPredicate{}, predicate.GenerationChangedPredicate{})),
+		).
+		// Only the metadata of ConfigMaps and Secrets is cached, since all of
+		// them are watched. The resolver reads the referenced ones from the
+		// API server.
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(filterRequests(k8sClient, referenceToGCPClusters(k8sClient, allowlist.ReferenceKindConfigMap), predicates)),
+			builder.OnlyMetadata,
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(filterRequests(k8sClient, referenceToGCPClusters(k8sClient, allowlist.ReferenceKindSecret), predicates)),
+			builder.OnlyMetadata,
+		).
+		Watches(
+			&source.Kind{Type: &v1alpha1.IPFeed{}},
+			handler.EnqueueRequestsFromMapFunc(filterRequests(k8sClient, referenceToGCPClusters(k8sClient, allowlist.ReferenceKindIPFeed), predicates)),
+		).
+		Watches(&source.Channel{Source: r.reconcileAll}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(predicates...)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		Complete(r)
 }
 
+// IgnoreStatusAnnotationUpdates filters updates of GCPClusters that only
+// change the status annotations of the sub-reconcilers, so that writing a
+// failed status doesn't reconcile the cluster again right away instead of
+// after the requeue delay.
+func IgnoreStatusAnnotationUpdates() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !isStatusAnnotationUpdate(e.ObjectOld, e.ObjectNew)
+		},
+	}
+}
+
+func isStatusAnnotationUpdate(oldObj, newObj client.Object) bool {
+	if oldObj == nil || newObj == nil {
+		return false
+	}
+
+	oldStatuses, oldAnnotations := splitStatusAnnotations(oldObj.GetAnnotations())
+	newStatuses, newAnnotations := splitStatusAnnotations(newObj.GetAnnotations())
+	if equality.Semantic.DeepEqual(oldStatuses, newStatuses) {
+		return false
+	}
+
+	oldCopy := oldObj.DeepCopyObject().(client.Object)
+	newCopy := newObj.DeepCopyObject().(client.Object)
+	oldCopy.SetAnnotations(oldAnnotations)
+	newCopy.SetAnnotations(newAnnotations)
+	for _, obj := range []client.Object{oldCopy, newCopy} {
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+	}
+
+	return equality.Semantic.DeepEqual(oldCopy, newCopy)
+}
+
+// splitStatusAnnotations returns the status annotations of the
+// sub-reconcilers and the other annotations.
+func splitStatusAnnotations(objAnnotations map[string]string) (map[string]string, map[string]string) {
+	statuses := map[string]string{}
+	others := map[string]string{}
+	for key, value := range objAnnotations {
+		if strings.HasPrefix(key, AnnotationStatusPrefix+"/") {
+			statuses[key] = value
+			continue
+		}
+		others[key] = value
+	}
+
+	return statuses, others
+}
+
+// referenceToGCPClusters maps ConfigMaps, Secrets or IPFeeds to the
+// GCPClusters whose allowlists reference them, directly or through their CAPI
+// Cluster.
+func referenceToGCPClusters(k8sClient client.Client, kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		ctx := context.Background()
+		logger := log.FromContext(ctx).WithValues("kind", kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+
+		matchingFields := client.MatchingFields{
+			allowlist.IndexReferences: allowlist.GetIndexValue(kind, obj.GetName()),
+		}
+
+		requests := []reconcile.Request{}
+
+		gcpClusters := &capg.GCPClusterList{}
+		err := k8sClient.List(ctx, gcpClusters, client.InNamespace(obj.GetNamespace()), matchingFields)
+		if err != nil {
+			logger.Error(err, "Failed to list GCPClusters referencing allowlist")
+			return nil
+		}
+		for _, gcpCluster := range gcpClusters.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      gcpCluster.Name,
+					Namespace: gcpCluster.Namespace,
+				},
+			})
+		}
+
+		clusters := &capi.ClusterList{}
+		err = k8sClient.List(ctx, clusters, client.InNamespace(obj.GetNamespace()), matchingFields)
+		if err != nil {
+			logger.Error(err, "Failed to list Clusters referencing allowlist")
+			return nil
+		}
+		for i := range clusters.Items {
+			requests = append(requests, clusterToGCPCluster(&clusters.Items[i])...)
+		}
+
+		return requests
+	}
+}
+
+// filterRequests drops the requests of mapFunc for GCPClusters that don't
+// exist or are not accepted by all predicates, like the shard predicate. The
+// predicates only see the watched objects, not the GCPClusters they are
+// mapped to.
+func filterRequests(k8sClient client.Client, mapFunc handler.MapFunc, predicates []predicate.Predicate) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		ctx := context.Background()
+		logger := log.FromContext(ctx)
+
+		requests := []reconcile.Request{}
+		for _, request := range mapFunc(obj) {
+			gcpCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, gcpCluster)
+			if apimachineryerrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				// The reconciliation reads the GCPCluster again and fails
+				// if it still can't be read.
+				logger.Error(err, "Failed to get GCPCluster to filter", "name", request.Name, "namespace", request.Namespace)
+				requests = append(requests, request)
+				continue
+			}
+
+			if isAccepted(gcpCluster, predicates) {
+				requests = append(requests, request)
+			}
+		}
+
+		return requests
+	}
+}
+
+func isAccepted(obj client.Object, predicates []predicate.Predicate) bool {
+	for _, p := range predicates {
+		if !p.Generic(event.GenericEvent{Object: obj}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// clusterToGCPCluster maps CAPI Clusters to the GCPCluster of their
+// infrastructure reference.
+func clusterToGCPCluster(obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*capi.Cluster)
+	if !ok || cluster.Spec.InfrastructureRef == nil {
+		return nil
+	}
+
+	infrastructureRef := cluster.Spec.InfrastructureRef
+	if infrastructureRef.GroupVersionKind().GroupKind() != capg.GroupVersion.WithKind("GCPCluster").GroupKind() {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      infrastructureRef.Name,
+				Namespace: cluster.Namespace,
+			},
+		},
+	}
+}
+
+// ReconcileAll queues all GCPClusters for reconciliation, e.g. after the
+// operator defaults changed. It blocks until all clusters are queued, so it
+// must only be called once the controller is running.
+func (r *GCPClusterReconciler) ReconcileAll(ctx context.Context) error {
+	gcpClusters, err := r.client.List(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for i := range gcpClusters {
+		select {
+		case r.reconcileAll <- event.GenericEvent{Object: &gcpClusters[i]}:
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+
+	return nil
+}
+
 func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.getLogger(ctx)
 
@@ -85,21 +365,50 @@ func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if !gcpCluster.DeletionTimestamp.IsZero() {
 		result, err := r.reconcileDelete(ctx, logger, gcpCluster)
 		if err != nil {
-			return ctrl.Result{}, errors.WithStack(err)
+			return r.handleError(logger, err)
 		}
 
 		return result, nil
 	}
 
-	result, err := r.reconcileNormal(ctx, logger, gcpCluster)
+	result, err := r.reconcileNormal(ctx, logger, gcpCluster, cluster)
 	if err != nil {
-		return ctrl.Result{}, errors.WithStack(err)
+		return r.handleError(logger, err)
 	}
 
 	return result, nil
 }
 
-func (r *GCPClusterReconciler) reconcileNormal(ctx context.Context, logger logr.Logger, gcpCluster *capg.GCPCluster) (ctrl.Result, error) {
+// handleError requeues after a fixed delay when the GCP API is unavailable or
+// rate limited, since the clients already retried with backoff and
+// controller-runtime's backoff would keep growing. Other errors are returned.
+// When several sub-reconcilers failed, the cluster is only requeued after a
+// fixed delay if all of their errors allow it. Otherwise all errors are
+// returned, so that none of them is hidden behind another.
+func (r *GCPClusterReconciler) handleError(logger logr.Logger, err error) (ctrl.Result, error) {
+	errs := []error{err}
+	var aggregate kerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		errs = aggregate.Errors()
+	}
+
+	requeueAfter := time.Duration(0)
+	for _, subErr := range errs {
+		switch google.Classify(subErr) {
+		case google.ErrorKindRetryable:
+			requeueAfter = maxDuration(requeueAfter, RequeueAfterRetryable)
+		case google.ErrorKindQuota:
+			requeueAfter = maxDuration(requeueAfter, RequeueAfterQuotaExceeded)
+		default:
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
+	logger.Error(err, "GCP API unavailable or quota exceeded", "requeueAfter", requeueAfter)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *GCPClusterReconciler) reconcileNormal(ctx context.Context, logger logr.Logger, gcpCluster *capg.GCPCluster, cluster *capi.Cluster) (ctrl.Result, error) {
 	if google.IsNilOrEmpty(gcpCluster.Status.Network.SelfLink) {
 		logger.Info("GCP Cluster does not have network set yet")
 		return ctrl.Result{}, nil
@@ -115,46 +424,211 @@ func (r *GCPClusterReconciler) reconcileNormal(ctx context.Context, logger logr.
 		return ctrl.Result{}, nil
 	}
 
-	err := r.client.AddFinalizer(ctx, gcpCluster, FinalizerFirewall)
-	if err != nil {
-		return ctrl.Result{}, errors.WithStack(err)
+	finalizers := []string{}
+	for _, entry := range r.registry.entries {
+		if entry.enabled {
+			finalizers = append(finalizers, GetFinalizer(entry.subReconciler.Name()))
+		}
 	}
 
-	err = r.firewallRuleReconciler.Reconcile(ctx, gcpCluster)
+	err := r.client.AddFinalizer(ctx, gcpCluster, finalizers...)
 	if err != nil {
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
-	err = r.securityPolicyReconciler.Reconcile(ctx, gcpCluster)
+	if controllerutil.ContainsFinalizer(gcpCluster, FinalizerFirewall) {
+		err = r.client.RemoveFinalizer(ctx, gcpCluster, FinalizerFirewall)
+		if err != nil {
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
+	// The sub-reconcilers get a copy with the resolved allowlists, so that
+	// they are never written to the GCPCluster.
+	resolvedCluster, resolveErrs := r.resolver.Resolve(ctx, gcpCluster, cluster)
+
+	result := ctrl.Result{}
+	statuses := map[string]string{}
+	errs := []error{}
+	for _, entry := range r.registry.entries {
+		name := entry.subReconciler.Name()
+		if !entry.enabled {
+			statuses[GetStatusAnnotation(name)] = StatusDisabled
+			continue
+		}
+
+		// Sub-reconcilers reading an allowlist that failed to resolve are
+		// skipped, so that they don't apply an incomplete allowlist.
+		subResult := ctrl.Result{}
+		err := getResolveError(entry.subReconciler, resolveErrs)
+		if err == nil {
+			subResult, err = entry.subReconciler.Reconcile(ctx, resolvedCluster)
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, name))
+			statuses[GetStatusAnnotation(name)] = StatusFailedPrefix + err.Error()
+			continue
+		}
+
+		statuses[GetStatusAnnotation(name)] = StatusReady
+		operationsResult := r.getOperationsResult(gcpCluster, entry.operationCollections...)
+		if !operationsResult.IsZero() {
+			statuses[GetStatusAnnotation(name)] = StatusProgressing
+		}
+		result = util.LowestNonZeroResult(result, subResult)
+	}
+
+	err = r.setAnnotations(ctx, gcpCluster, statuses)
 	if err != nil {
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
-	return ctrl.Result{}, nil
+	if len(errs) != 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	return util.LowestNonZeroResult(result, r.getOperationsResult(gcpCluster)), nil
 }
 
-func (r *GCPClusterReconciler) reconcileDelete(ctx context.Context, logger logr.Logger, gcpCluster *capg.GCPCluster) (ctrl.Result, error) {
-	if !google.IsNilOrEmpty(gcpCluster.Status.Network.APIServerBackendService) {
-		logger.Info("GCP Cluster backend service not deleted yet")
-		return ctrl.Result{}, nil
+// getResolveError returns the errors of the allowlists the sub-reconciler
+// reads, or of all allowlists if it doesn't implement AllowListReader.
+func getResolveError(subReconciler SubReconciler, resolveErrs map[string]error) error {
+	annotations := allowlist.Annotations
+	reader, ok := subReconciler.(AllowListReader)
+	if ok {
+		annotations = reader.AllowListAnnotations()
 	}
 
-	err := r.firewallRuleReconciler.ReconcileDelete(ctx, gcpCluster)
-	if err != nil {
+	errs := []error{}
+	for _, annotation := range annotations {
+		err, ok := resolveErrs[annotation]
+		if ok {
+			errs = append(errs, err)
+		}
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
+// reconcileDelete deletes the resources of the sub-reconcilers and removes
+// their finalizers. The sub-reconcilers whose resources remain are listed in
+// the AnnotationRemainingResources annotation.
+func (r *GCPClusterReconciler) reconcileDelete(ctx context.Context, logger logr.Logger, gcpCluster *capg.GCPCluster) (ctrl.Result, error) {
+	if gcpCluster.Annotations[AnnotationOrphan] == "true" {
+		logger.Info("Cluster is marked as orphan. Removing finalizers without deleting GCP resources")
+		finalizers := []string{FinalizerFirewall}
+		for _, entry := range r.registry.entries {
+			finalizers = append(finalizers, GetFinalizer(entry.subReconciler.Name()))
+		}
+		err := r.client.RemoveFinalizer(ctx, gcpCluster, finalizers...)
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
-	err = r.securityPolicyReconciler.ReconcileDelete(ctx, gcpCluster)
+	hasLegacyFinalizer := controllerutil.ContainsFinalizer(gcpCluster, FinalizerFirewall)
+
+	result := ctrl.Result{}
+	remaining := []string{}
+	finalizers := []string{}
+	errs := []error{}
+	for _, entry := range r.registry.entries {
+		name := entry.subReconciler.Name()
+		if !hasLegacyFinalizer && !controllerutil.ContainsFinalizer(gcpCluster, GetFinalizer(name)) {
+			continue
+		}
+
+		deleted, entryResult, err := r.deleteResources(ctx, logger, gcpCluster, entry)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, name))
+		}
+		if !deleted {
+			remaining = append(remaining, name)
+			result = util.LowestNonZeroResult(result, entryResult)
+			continue
+		}
+
+		finalizers = append(finalizers, GetFinalizer(name))
+	}
+
+	err := r.setAnnotations(ctx, gcpCluster, map[string]string{
+		AnnotationRemainingResources: strings.Join(remaining, ","),
+	})
 	if err != nil {
 		return ctrl.Result{}, errors.WithStack(err)
 	}
 
-	err = r.client.RemoveFinalizer(ctx, gcpCluster, FinalizerFirewall)
+	if len(remaining) == 0 && hasLegacyFinalizer {
+		finalizers = append(finalizers, FinalizerFirewall)
+	}
+
+	if len(finalizers) != 0 {
+		err = r.client.RemoveFinalizer(ctx, gcpCluster, finalizers...)
+		if err != nil {
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	return result, nil
+}
+
+// deleteResources deletes the resources of a sub-reconciler. It returns true
+// when they are gone and its finalizer can be removed. The result requeues
+// the cluster while GCP operations on the resources are still running.
+func (r *GCPClusterReconciler) deleteResources(ctx context.Context, logger logr.Logger, gcpCluster *capg.GCPCluster, entry registryEntry) (bool, ctrl.Result, error) {
+	logger = logger.WithValues("subReconciler", entry.subReconciler.Name())
+
+	err := entry.subReconciler.ReconcileDelete(ctx, gcpCluster)
 	if err != nil {
-		return ctrl.Result{}, errors.WithStack(err)
+		return false, ctrl.Result{}, errors.WithStack(err)
+	}
+
+	result := r.getOperationsResult(gcpCluster, entry.operationCollections...)
+	if !result.IsZero() {
+		logger.Info("Waiting for GCP operations to finish before removing finalizer")
+		return false, result, nil
+	}
+
+	return true, ctrl.Result{}, nil
+}
+
+// setAnnotations patches the annotations that changed. Empty values remove
+// the annotation.
+func (r *GCPClusterReconciler) setAnnotations(ctx context.Context, gcpCluster *capg.GCPCluster, annotations map[string]string) error {
+	changed := map[string]string{}
+	for key, value := range annotations {
+		if gcpCluster.Annotations[key] != value {
+			changed[key] = value
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return r.client.SetAnnotations(ctx, gcpCluster, changed)
+}
+
+// getOperationsResult requeues the cluster while GCP operations it started
+// on resources of the collections, or on any resource if none are given, are
+// still running.
+func (r *GCPClusterReconciler) getOperationsResult(gcpCluster *capg.GCPCluster, collections ...string) ctrl.Result {
+	cluster := types.NamespacedName{Namespace: gcpCluster.Namespace, Name: gcpCluster.Name}
+	if !r.operations.HasPending(cluster, collections...) {
+		return ctrl.Result{}
+	}
+
+	return ctrl.Result{RequeueAfter: RequeueAfterOperationPending}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
 	}
 
-	return ctrl.Result{}, nil
+	return b
 }
 
 func (r *GCPClusterReconciler) getLogger(ctx context.Context) logr.Logger {
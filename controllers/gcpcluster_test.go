@@ -3,30 +3,46 @@ package controllers_test
 import (
 	"context"
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/giantswarm/to"
 
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
 	"github.com/giantswarm/capg-firewall-rule-operator/controllers"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/allowlist"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall/firewallfakes"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/k8sclient"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security/securityfakes"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/sharding"
 	"github.com/giantswarm/capg-firewall-rule-operator/tests"
 )
 
+var (
+	firewallRulesFinalizer  = controllers.GetFinalizer(firewall.ReconcilerName)
+	securityPolicyFinalizer = controllers.GetFinalizer(security.ReconcilerName)
+)
+
 var _ = Describe("GCPClusterReconciler", func() {
 	var (
 		ctx               context.Context
@@ -37,6 +53,12 @@ var _ = Describe("GCPClusterReconciler", func() {
 		firewallClient       *firewallfakes.FakeFirewallsClient
 		securityPolicyClient *securityfakes.FakeSecurityPolicyClient
 		ipResolver           *securityfakes.FakeClusterNATIPResolver
+		egressIPResolver     *firewallfakes.FakeClusterNATIPResolver
+		aggregateRanges      bool
+		breakGlassAllowList  []string
+		recorder             *record.FakeRecorder
+		enableFirewallRules  bool
+		enableSecurityPolicy bool
 
 		cluster    *capi.Cluster
 		gcpCluster *capg.GCPCluster
@@ -54,31 +76,22 @@ var _ = Describe("GCPClusterReconciler", func() {
 		firewallClient = new(firewallfakes.FakeFirewallsClient)
 		securityPolicyClient = new(securityfakes.FakeSecurityPolicyClient)
 		ipResolver = new(securityfakes.FakeClusterNATIPResolver)
+		egressIPResolver = new(firewallfakes.FakeClusterNATIPResolver)
 
 		ipResolver.GetIPsReturnsOnCall(0, []string{"10.1.1.24", "192.168.1.218"}, nil)
 		ipResolver.GetIPsReturnsOnCall(1, []string{"10.236.0.0", "192.168.128.0"}, nil)
+		egressIPResolver.GetIPsReturns([]string{"10.1.1.24", "192.168.1.218"}, nil)
 
 		managementCluster = types.NamespacedName{
 			Name:      "the-mc-name",
 			Namespace: "the-namespace",
 		}
 
-		defaultAPIAllowList := []string{"10.128.0.0/24", "10.230.0.0/24"}
-		securityPolicyReconciler := security.NewPolicyReconciler(
-			defaultAPIAllowList,
-			managementCluster,
-			securityPolicyClient,
-			ipResolver,
-		)
-
-		defaultBastionHostAllowList := []string{"192.168.0.0/24", "172.158.0.0/24"}
-		firewallReconciler := firewall.NewRuleReconciler(defaultBastionHostAllowList, firewallClient)
-
-		reconciler = controllers.NewGCPClusterReconciler(
-			clusterClient,
-			firewallReconciler,
-			securityPolicyReconciler,
-		)
+		aggregateRanges = false
+		breakGlassAllowList = nil
+		recorder = record.NewFakeRecorder(10)
+		enableFirewallRules = true
+		enableSecurityPolicy = true
 
 		cluster = &capi.Cluster{
 			ObjectMeta: metav1.ObjectMeta{
@@ -130,19 +143,144 @@ var _ = Describe("GCPClusterReconciler", func() {
 	})
 
 	JustBeforeEach(func() {
+		defaultAPIAllowList := []string{"10.128.0.0/24", "10.230.0.0/24"}
+		securityPolicyReconciler := security.NewPolicyReconciler(
+			breakGlassAllowList,
+			defaultAPIAllowList,
+			managementCluster,
+			false,
+			aggregateRanges,
+			securityPolicyClient,
+			ipResolver,
+			recorder,
+		)
+
+		defaultBastionHostAllowList := []string{"192.168.0.0/24", "172.158.0.0/24"}
+		controlPlaneAllowList := []string{"130.211.0.0/22", "35.191.0.0/16"}
+		defaultEgressAllowList := []string{"199.36.153.8/30"}
+		firewallReconciler := firewall.NewRuleReconciler(
+			defaultBastionHostAllowList,
+			controlPlaneAllowList,
+			defaultEgressAllowList,
+			managementCluster,
+			firewall.Logging{},
+			aggregateRanges,
+			firewallClient,
+			egressIPResolver,
+			recorder,
+		)
+
+		registry := controllers.NewRegistry().
+			Register(firewallReconciler, enableFirewallRules, google.CollectionFirewalls).
+			Register(securityPolicyReconciler, enableSecurityPolicy,
+				google.CollectionSecurityPolicies, google.CollectionBackendServices)
+
+		reconciler = controllers.NewGCPClusterReconciler(
+			clusterClient,
+			allowlist.NewResolver(k8sClient),
+			registry,
+			google.NewOperationTracker(),
+		)
+
 		result, reconcileErr = reconciler.Reconcile(ctx, request)
 	})
 
-	It("adds a finalizer to the gcp cluster", func() {
+	It("adds a finalizer per managed resource to the gcp cluster", func() {
 		actualCluster := &capg.GCPCluster{}
 		err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(actualCluster.Finalizers).To(ContainElement(controllers.FinalizerFirewall))
+		Expect(actualCluster.Finalizers).To(ConsistOf(
+			firewallRulesFinalizer,
+			securityPolicyFinalizer,
+		))
+	})
+
+	When("the gcp cluster has the finalizer guarding all resources", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Finalizers = []string{controllers.FinalizerFirewall}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("replaces it with a finalizer per managed resource", func() {
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actualCluster.Finalizers).To(ConsistOf(
+				firewallRulesFinalizer,
+				securityPolicyFinalizer,
+			))
+		})
+	})
+
+	It("sets the status of the sub-reconcilers", func() {
+		actualCluster := &capg.GCPCluster{}
+		err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+			controllers.GetStatusAnnotation(firewall.ReconcilerName), controllers.StatusReady))
+		Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+			controllers.GetStatusAnnotation(security.ReconcilerName), controllers.StatusReady))
+	})
+
+	When("the firewall client fails", func() {
+		BeforeEach(func() {
+			firewallClient.ApplyRuleReturns(false, errors.New("boom"))
+		})
+
+		It("still applies the security policy", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("boom")))
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(1))
+		})
+
+		It("sets the status of the failed sub-reconciler", func() {
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+				controllers.GetStatusAnnotation(firewall.ReconcilerName), HavePrefix(controllers.StatusFailedPrefix)))
+			Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+				controllers.GetStatusAnnotation(security.ReconcilerName), controllers.StatusReady))
+		})
+
+		When("the security policy client fails as well", func() {
+			BeforeEach(func() {
+				securityPolicyClient.ApplyPolicyReturns(errors.New("bang"))
+			})
+
+			It("returns both errors", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("boom")))
+				Expect(reconcileErr).To(MatchError(ContainSubstring("bang")))
+			})
+		})
+	})
+
+	When("the security policy sub-reconciler is disabled", func() {
+		BeforeEach(func() {
+			enableSecurityPolicy = false
+		})
+
+		It("only manages the firewall rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actualCluster.Finalizers).To(ConsistOf(firewallRulesFinalizer))
+			Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+				controllers.GetStatusAnnotation(security.ReconcilerName), controllers.StatusDisabled))
+		})
 	})
 
 	It("applies the firewall rules for the bastions", func() {
-		Expect(firewallClient.ApplyRuleCallCount()).To(Equal(1))
+		Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
 
 		_, actualCluster, actualRule := firewallClient.ApplyRuleArgsForCall(0)
 		Expect(actualCluster.Name).To(Equal("the-gcp-cluster"))
@@ -156,7 +294,24 @@ var _ = Describe("GCPClusterReconciler", func() {
 		Expect(actualRule.Direction).To(Equal(firewall.DirectionIngress))
 		Expect(actualRule.Name).To(Equal("allow-the-gcp-cluster-bastion-ssh"))
 		Expect(actualRule.TargetTags).To(Equal([]string{"the-gcp-cluster-bastion"}))
-		Expect(actualRule.SourceRanges).To(Equal([]string{"128.0.0.0/24", "192.168.0.0/24", "192.168.0.0/24", "172.158.0.0/24"}))
+		Expect(actualRule.SourceRanges).To(Equal([]string{"128.0.0.0/24", "172.158.0.0/24", "192.168.0.0/24"}))
+	})
+
+	It("applies the firewall rule for the control plane", func() {
+		Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
+
+		_, actualCluster, actualRule := firewallClient.ApplyRuleArgsForCall(1)
+		Expect(actualCluster.Name).To(Equal("the-gcp-cluster"))
+		Expect(actualRule.Name).To(Equal("allow-the-gcp-cluster-control-plane-api"))
+		Expect(actualRule.Allowed).To(ConsistOf(firewall.Allowed{
+			IPProtocol: firewall.ProtocolTCP,
+			Ports:      []uint32{firewall.PortKubernetesAPI},
+		}))
+		Expect(actualRule.Description).To(Equal("allow kubernetes api from load balancers and cluster nodes"))
+		Expect(actualRule.Direction).To(Equal(firewall.DirectionIngress))
+		Expect(actualRule.TargetTags).To(Equal([]string{"the-gcp-cluster-control-plane"}))
+		Expect(actualRule.SourceRanges).To(Equal([]string{"35.191.0.0/16", "130.211.0.0/22"}))
+		Expect(actualRule.SourceTags).To(Equal([]string{"the-gcp-cluster"}))
 	})
 
 	It("applies the security policies for the kubernetes api", func() {
@@ -172,6 +327,7 @@ var _ = Describe("GCPClusterReconciler", func() {
 		Expect(actualPolicy.Name).To(Equal("allow-the-gcp-cluster-apiserver"))
 		Expect(actualPolicy.Description).To(Equal("allow IPs to connect to kubernetes api"))
 		Expect(actualPolicy.DefaultAction).To(Equal(security.ActionDeny403))
+		Expect(actualPolicy.LogLevel).To(Equal(security.LogLevelNormal))
 		Expect(actualPolicy.Rules).To(ConsistOf(
 			security.PolicyRule{
 				Action:      security.ActionAllow,
@@ -180,25 +336,25 @@ var _ = Describe("GCPClusterReconciler", func() {
 					"10.0.0.0/24",
 					"172.158.0.0/24",
 				},
-				Priority: 0,
+				Priority: 1,
 			},
 			security.PolicyRule{
 				Action:      security.ActionAllow,
 				Description: "allow MC NAT IPs",
 				SourceIPRanges: []string{
-					"10.1.1.24",
-					"192.168.1.218",
+					"10.1.1.24/32",
+					"192.168.1.218/32",
 				},
-				Priority: 1,
+				Priority: 2,
 			},
 			security.PolicyRule{
 				Action:      security.ActionAllow,
 				Description: "allow WC NAT IPs",
 				SourceIPRanges: []string{
-					"10.236.0.0",
-					"192.168.128.0",
+					"10.236.0.0/32",
+					"192.168.128.0/32",
 				},
-				Priority: 2,
+				Priority: 3,
 			},
 			security.PolicyRule{
 				Action:      security.ActionAllow,
@@ -207,15 +363,247 @@ var _ = Describe("GCPClusterReconciler", func() {
 					"10.128.0.0/24",
 					"10.230.0.0/24",
 				},
-				Priority: 3,
+				Priority: 4,
 			},
 		))
 	})
 
+	Describe("allowlists set on the CAPI Cluster", func() {
+		BeforeEach(func() {
+			patchedCluster := cluster.DeepCopy()
+			patchedCluster.Spec.Topology = &capi.Topology{
+				Class:   "the-cluster-class",
+				Version: "v1.24.0",
+				Variables: []capi.ClusterVariable{
+					{
+						Name: allowlist.VariableAllowLists,
+						Value: apiextensionsv1.JSON{
+							Raw: []byte(`{"api": "10.4.0.0/24", "bastion": "10.5.0.0/24"}`),
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(cluster))).To(Succeed())
+
+			patchedGCPCluster := gcpCluster.DeepCopy()
+			delete(patchedGCPCluster.Annotations, security.AnnotationAPIAllowListSubnets)
+			Expect(k8sClient.Patch(ctx, patchedGCPCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("uses the topology variable when no annotation is set", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(getUserRanges(securityPolicyClient)).To(Equal([]string{"10.4.0.0/24"}))
+		})
+
+		It("prefers the annotations of the gcp cluster", func() {
+			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
+			Expect(actualRule.Name).To(Equal("allow-the-gcp-cluster-bastion-ssh"))
+			Expect(actualRule.SourceRanges).To(Equal([]string{"128.0.0.0/24", "172.158.0.0/24", "192.168.0.0/24"}))
+		})
+
+		It("does not write the resolved allowlists to the gcp cluster", func() {
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actualCluster.Annotations).NotTo(HaveKey(security.AnnotationAPIAllowListSubnets))
+		})
+
+		When("the CAPI Cluster has the allowlist annotation", func() {
+			BeforeEach(func() {
+				patchedCluster := cluster.DeepCopy()
+				patchedCluster.Annotations = map[string]string{
+					security.AnnotationAPIAllowListSubnets: "10.2.0.0/24",
+				}
+				Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(cluster))).To(Succeed())
+			})
+
+			It("prefers the annotation over the topology variable", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(getUserRanges(securityPolicyClient)).To(Equal([]string{"10.2.0.0/24"}))
+			})
+		})
+
+		When("the topology variable is invalid", func() {
+			BeforeEach(func() {
+				patchedCluster := cluster.DeepCopy()
+				patchedCluster.Spec.Topology.Variables[0].Value = apiextensionsv1.JSON{Raw: []byte(`"10.4.0.0/24"`)}
+				Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(cluster))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring(allowlist.VariableAllowLists)))
+				Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("allowlists referencing ConfigMaps and Secrets", func() {
+		BeforeEach(func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "the-allowlist",
+					Namespace: namespace,
+				},
+				Data: map[string]string{
+					"api": "10.6.0.0/24\n# office\n10.7.0.0/24",
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "the-allowlist",
+					Namespace: namespace,
+				},
+				Data: map[string][]byte{
+					"api": []byte("10.8.0.0/24"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[allowlist.GetFromAnnotation(security.AnnotationAPIAllowListSubnets)] =
+				"configmap/the-allowlist#api,secret/the-allowlist#api"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("combines the annotation with the referenced keys", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(getUserRanges(securityPolicyClient)).To(Equal([]string{
+				"10.0.0.0/24",
+				"10.6.0.0/24",
+				"10.7.0.0/24",
+				"10.8.0.0/24",
+				"172.158.0.0/24",
+			}))
+		})
+
+		When("a referenced key does not exist", func() {
+			BeforeEach(func() {
+				patchedCluster := gcpCluster.DeepCopy()
+				patchedCluster.Annotations[allowlist.GetFromAnnotation(security.AnnotationAPIAllowListSubnets)] =
+					"configmap/the-allowlist#bastion"
+				Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+			})
+
+			It("does not change the security policy", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("configmap/the-allowlist#bastion")))
+				Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+			})
+
+			It("still applies the firewall rules", func() {
+				Expect(firewallClient.ApplyRuleCallCount()).NotTo(BeZero())
+			})
+
+			It("sets the status of the skipped sub-reconciler", func() {
+				actualCluster := &capg.GCPCluster{}
+				err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+					controllers.GetStatusAnnotation(firewall.ReconcilerName), controllers.StatusReady))
+				Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+					controllers.GetStatusAnnotation(security.ReconcilerName), And(
+						HavePrefix(controllers.StatusFailedPrefix),
+						ContainSubstring("configmap/the-allowlist#bastion"),
+					)))
+			})
+		})
+
+		When("a referenced Secret contains an invalid entry", func() {
+			BeforeEach(func() {
+				secret := &corev1.Secret{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: "the-allowlist", Namespace: namespace}, secret)
+				Expect(err).NotTo(HaveOccurred())
+
+				patchedSecret := secret.DeepCopy()
+				patchedSecret.Data["api"] = []byte("10.8.0.0/24\n10.9.0.0/08")
+				Expect(k8sClient.Patch(ctx, patchedSecret, client.MergeFrom(secret))).To(Succeed())
+			})
+
+			It("reports the reference without the entry", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("line 2 of secret/the-allowlist#api")))
+				Expect(reconcileErr.Error()).NotTo(ContainSubstring("10.9.0.0"))
+				Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+			})
+
+			It("leaves the entry out of the status", func() {
+				actualCluster := &capg.GCPCluster{}
+				err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+				Expect(err).NotTo(HaveOccurred())
+
+				status := actualCluster.Annotations[controllers.GetStatusAnnotation(security.ReconcilerName)]
+				Expect(status).To(ContainSubstring("secret/the-allowlist#api"))
+				Expect(status).NotTo(ContainSubstring("10.9.0.0"))
+			})
+		})
+
+		When("the allowlist references an IPFeed", func() {
+			var feed *v1alpha1.IPFeed
+
+			BeforeEach(func() {
+				feed = &v1alpha1.IPFeed{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "the-feed",
+						Namespace: namespace,
+					},
+					Spec: v1alpha1.IPFeedSpec{
+						URL: "https://vendor.example.com/ips.txt",
+					},
+				}
+				Expect(k8sClient.Create(ctx, feed)).To(Succeed())
+
+				patchedCluster := gcpCluster.DeepCopy()
+				patchedCluster.Annotations[allowlist.GetFromAnnotation(security.AnnotationAPIAllowListSubnets)] =
+					"ipfeed/the-feed"
+				Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+			})
+
+			It("does not change the security policy until the feed was fetched", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("ipfeed/the-feed has not been fetched yet")))
+				Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+			})
+
+			When("the feed was fetched", func() {
+				BeforeEach(func() {
+					patchedFeed := feed.DeepCopy()
+					patchedFeed.Status.Ranges = []string{"13.0.0.0/16"}
+					Expect(k8sClient.Status().Patch(ctx, patchedFeed, client.MergeFrom(feed))).To(Succeed())
+				})
+
+				It("combines the annotation with the ranges of the feed", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(getUserRanges(securityPolicyClient)).To(Equal([]string{
+						"10.0.0.0/24",
+						"13.0.0.0/16",
+						"172.158.0.0/24",
+					}))
+				})
+			})
+		})
+
+		When("the reference is invalid", func() {
+			BeforeEach(func() {
+				patchedCluster := gcpCluster.DeepCopy()
+				patchedCluster.Annotations[allowlist.GetFromAnnotation(security.AnnotationAPIAllowListSubnets)] =
+					"deployment/the-allowlist#api"
+				Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("invalid kind")))
+			})
+		})
+	})
+
 	When("the gcp cluster is marked for deletion", func() {
 		BeforeEach(func() {
 			patchedCluster := gcpCluster.DeepCopy()
-			patchedCluster.Finalizers = []string{controllers.FinalizerFirewall}
+			patchedCluster.Finalizers = []string{
+				firewallRulesFinalizer,
+				securityPolicyFinalizer,
+			}
 			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
 
 			status := capg.GCPClusterStatus{
@@ -241,12 +629,22 @@ var _ = Describe("GCPClusterReconciler", func() {
 		})
 
 		It("uses the firewall client to remove firewall rules", func() {
-			Expect(firewallClient.DeleteRuleCallCount()).To(Equal(1))
+			Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
 
-			_, actualCluster, actualRule := firewallClient.DeleteRuleArgsForCall(0)
+			_, actualCluster, _ := firewallClient.DeleteRuleArgsForCall(0)
 			Expect(actualCluster.Name).To(Equal("the-gcp-cluster"))
 			Expect(*actualCluster.Status.Network.SelfLink).To(Equal("something"))
-			Expect(actualRule).To(Equal("allow-the-gcp-cluster-bastion-ssh"))
+
+			Expect(getDeletedRules(firewallClient)).To(Equal([]string{
+				"allow-the-gcp-cluster-bastion-ssh",
+				"allow-the-gcp-cluster-bastion-ssh-ipv6",
+				"allow-the-gcp-cluster-control-plane-api",
+				"allow-the-gcp-cluster-control-plane-api-ipv6",
+				"deny-the-gcp-cluster-egress",
+				"deny-the-gcp-cluster-egress-ipv6",
+				"allow-the-gcp-cluster-egress",
+				"allow-the-gcp-cluster-egress-ipv6",
+			}))
 		})
 
 		It("uses the firewall client to remove firewall rules", func() {
@@ -265,7 +663,7 @@ var _ = Describe("GCPClusterReconciler", func() {
 			})
 
 			It("removes the firewall rule", func() {
-				Expect(firewallClient.DeleteRuleCallCount()).To(Equal(1))
+				Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
 			})
 
 			When("the Status.Network.SelfLink is empty", func() {
@@ -286,9 +684,17 @@ var _ = Describe("GCPClusterReconciler", func() {
 					Expect(result.RequeueAfter).To(BeZero())
 					Expect(reconcileErr).NotTo(HaveOccurred())
 
-					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(0))
 					Expect(firewallClient.ApplyRuleCallCount()).To(Equal(0))
 				})
+
+				It("removes all resources without waiting for the backend service", func() {
+					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
+					Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(1))
+
+					actualCluster := &capg.GCPCluster{}
+					err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+					Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+				})
 			})
 
 			When("the Status.Network.Router is empty", func() {
@@ -309,9 +715,17 @@ var _ = Describe("GCPClusterReconciler", func() {
 					Expect(result.RequeueAfter).To(BeZero())
 					Expect(reconcileErr).NotTo(HaveOccurred())
 
-					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(0))
 					Expect(firewallClient.ApplyRuleCallCount()).To(Equal(0))
 				})
+
+				It("removes all resources without waiting for the backend service", func() {
+					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
+					Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(1))
+
+					actualCluster := &capg.GCPCluster{}
+					err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+					Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+				})
 			})
 
 			When("the Status.Network.APIServerBackendService is empty", func() {
@@ -328,7 +742,7 @@ var _ = Describe("GCPClusterReconciler", func() {
 				})
 
 				It("removes the firewall rule", func() {
-					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(1))
+					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
 				})
 
 				It("does not return an error", func() {
@@ -346,12 +760,18 @@ var _ = Describe("GCPClusterReconciler", func() {
 				Expect(reconcileErr).To(HaveOccurred())
 			})
 
-			It("does not remove the finalizer", func() {
+			It("only removes the finalizer of the security policy", func() {
+				Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(1))
+
 				actualCluster := &capg.GCPCluster{}
 				err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(actualCluster.Finalizers).To(ContainElement(controllers.FinalizerFirewall))
+				Expect(actualCluster.Finalizers).To(ConsistOf(firewallRulesFinalizer))
+				Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+					controllers.AnnotationRemainingResources,
+					firewall.ReconcilerName,
+				))
 			})
 		})
 
@@ -364,46 +784,236 @@ var _ = Describe("GCPClusterReconciler", func() {
 				Expect(reconcileErr).To(MatchError(ContainSubstring("boom")))
 			})
 
-			It("does not remove the finalizer", func() {
+			It("only removes the finalizer of the firewall rules", func() {
 				actualCluster := &capg.GCPCluster{}
 				err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(actualCluster.Finalizers).To(ContainElement(controllers.FinalizerFirewall))
+				Expect(actualCluster.Finalizers).To(ConsistOf(securityPolicyFinalizer))
+				Expect(actualCluster.Annotations).To(HaveKeyWithValue(
+					controllers.AnnotationRemainingResources,
+					security.ReconcilerName,
+				))
+			})
+
+			When("the cluster is reconciled again", func() {
+				JustBeforeEach(func() {
+					result, reconcileErr = reconciler.Reconcile(ctx, request)
+				})
+
+				It("does not delete the firewall rules again", func() {
+					Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
+					Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(2))
+				})
 			})
 		})
 	})
 
-	DescribeTable("when the bastion allowlist annotation is invalid",
-		func(annotation string) {
+	When("a gcp cluster is deleted while the security policy sub-reconciler is disabled", func() {
+		BeforeEach(func() {
+			enableSecurityPolicy = false
+
 			patchedCluster := gcpCluster.DeepCopy()
-			patchedCluster.Annotations = map[string]string{
-				firewall.AnnotationBastionAllowListSubnets: annotation,
+			patchedCluster.Finalizers = []string{
+				firewallRulesFinalizer,
+				securityPolicyFinalizer,
 			}
 			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
 
-			_, err := reconciler.Reconcile(ctx, request)
-			Expect(err).To(HaveOccurred())
-		},
-		Entry("the annotation contains an invalid cidr", "128.0.0.0/24,random-string,192.168.0.0/24"),
-		Entry("the annotation is not a CSV list", "128.0.0.0/24 192.168.0.0/24"),
-	)
+			Expect(k8sClient.Delete(ctx, gcpCluster)).To(Succeed())
+		})
 
-	DescribeTable("when the apiserver allowlist annotation is invalid",
-		func(annotation string) {
+		It("still deletes the security policy", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(1))
+
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	When("a gcp cluster with the finalizer guarding all resources is marked for deletion", func() {
+		BeforeEach(func() {
 			patchedCluster := gcpCluster.DeepCopy()
-			patchedCluster.Annotations = map[string]string{
-				security.AnnotationAPIAllowListSubnets: annotation,
-			}
+			patchedCluster.Finalizers = []string{controllers.FinalizerFirewall}
 			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
 
-			_, err := reconciler.Reconcile(ctx, request)
-			Expect(err).To(HaveOccurred())
+			status := capg.GCPClusterStatus{
+				Ready: true,
+				Network: capg.Network{
+					SelfLink:                to.StringP("something"),
+					APIServerBackendService: to.StringP(""),
+				},
+			}
+			tests.PatchClusterStatus(k8sClient, gcpCluster, status)
+
+			Expect(k8sClient.Delete(ctx, gcpCluster)).To(Succeed())
+		})
+
+		It("removes all resources and the finalizer", func() {
+			Expect(firewallClient.DeleteRuleCallCount()).To(Equal(8))
+			Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(1))
+
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	When("a gcp cluster marked as orphan is deleted", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[controllers.AnnotationOrphan] = "true"
+			patchedCluster.Finalizers = []string{
+				firewallRulesFinalizer,
+				securityPolicyFinalizer,
+			}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, gcpCluster)).To(Succeed())
+		})
+
+		It("removes the finalizers without deleting GCP resources", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(firewallClient.DeleteRuleCallCount()).To(Equal(0))
+			Expect(securityPolicyClient.DeletePolicyCallCount()).To(Equal(0))
+
+			actualCluster := &capg.GCPCluster{}
+			err := k8sClient.Get(ctx, request.NamespacedName, actualCluster)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	DescribeTable("when the bastion allowlist annotation is invalid",
+		func(annotation string) {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations = map[string]string{
+				firewall.AnnotationBastionAllowListSubnets: annotation,
+			}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("the annotation contains an invalid cidr", "128.0.0.0/24,random-string,192.168.0.0/24"),
+		Entry("the annotation is not a CSV list", "128.0.0.0/24 192.168.0.0/24"),
+		Entry("the annotation contains an invalid exclusion", "128.0.0.0/24,!random-string"),
+		Entry("the annotation contains an invalid expiry", "128.0.0.0/24@tomorrow"),
+	)
+
+	DescribeTable("when the apiserver allowlist annotation is invalid",
+		func(annotation string) {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations = map[string]string{
+				security.AnnotationAPIAllowListSubnets: annotation,
+			}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).To(HaveOccurred())
 		},
 		Entry("the annotation contains an invalid cidr", "128.0.0.0/24,random-string,192.168.0.0/24"),
 		Entry("the annotation is not a CSV list", "128.0.0.0/24 192.168.0.0/24"),
+		Entry("the annotation contains a loopback range", "128.0.0.0/24,127.0.0.0/8"),
 	)
 
+	When("the allowlist annotations contain comments, newlines and exclusions", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionAllowListSubnets] = `
+				# office
+				128.0.0.0/24, 203.0.113.0/24
+				!128.0.0.0/25 # printer
+			`
+			patchedCluster.Annotations[security.AnnotationAPIAllowListSubnets] = "203.0.113.0/30,\n!203.0.113.1/32"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("applies the ranges without the exclusions", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
+			Expect(actualRule.SourceRanges).To(Equal([]string{
+				"128.0.0.128/25",
+				"172.158.0.0/24",
+				"192.168.0.0/24",
+				"203.0.113.0/24",
+			}))
+
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.Rules[0].SourceIPRanges).To(Equal([]string{
+				"203.0.113.0/32",
+				"203.0.113.2/31",
+			}))
+		})
+	})
+
+	When("the allowlist annotations contain expiring entries", func() {
+		BeforeEach(func() {
+			expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionAllowListSubnets] = "128.0.0.0/24,203.0.113.7/32@" + expiry + ",198.51.100.7/32@2020-01-01T00:00:00Z"
+			patchedCluster.Annotations[security.AnnotationAPIAllowListSubnets] = "10.0.0.0/24,203.0.113.8/32@" + expiry
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("only allows the entries that have not expired", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
+			Expect(actualRule.SourceRanges).To(Equal([]string{
+				"128.0.0.0/24",
+				"172.158.0.0/24",
+				"192.168.0.0/24",
+				"203.0.113.7/32",
+			}))
+
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.Rules[0].SourceIPRanges).To(Equal([]string{
+				"10.0.0.0/24",
+				"203.0.113.8/32",
+			}))
+		})
+
+		It("requeues when the first entry expires", func() {
+			Expect(result.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+			Expect(result.RequeueAfter).To(BeNumerically("<=", time.Hour+time.Second))
+		})
+
+		It("records an event for the expired entry", func() {
+			Expect(recorder.Events).To(Receive(ContainSubstring("198.51.100.7/32")))
+		})
+
+		When("the cluster is reconciled again", func() {
+			JustBeforeEach(func() {
+				Expect(recorder.Events).To(Receive(ContainSubstring("198.51.100.7/32")))
+				result, reconcileErr = reconciler.Reconcile(ctx, request)
+			})
+
+			It("does not record the event again", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(recorder.Events).NotTo(Receive())
+			})
+		})
+	})
+
+	When("the allowlist annotation contains an invalid entry", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionAllowListSubnets] = "128.0.0.0/24,\n192.168.0.0/24,random-string"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("returns an error pointing at the entry", func() {
+			var parseErr *cidr.ParseError
+			Expect(errors.As(reconcileErr, &parseErr)).To(BeTrue())
+			Expect(parseErr.Line).To(Equal(2))
+			Expect(parseErr.Entry).To(Equal("random-string"))
+		})
+	})
+
 	When("the bastion allow list annotation is missing", func() {
 		BeforeEach(func() {
 			patchedCluster := gcpCluster.DeepCopy()
@@ -416,7 +1026,7 @@ var _ = Describe("GCPClusterReconciler", func() {
 		It("still applies the default rules", func() {
 			Expect(reconcileErr).NotTo(HaveOccurred())
 
-			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(1))
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
 			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
 			Expect(actualRule.SourceRanges).To(ConsistOf("192.168.0.0/24", "172.158.0.0/24"))
 		})
@@ -435,12 +1045,384 @@ var _ = Describe("GCPClusterReconciler", func() {
 		It("still applies the default rules", func() {
 			Expect(reconcileErr).NotTo(HaveOccurred())
 
-			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(1))
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
 			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
 			Expect(actualRule.SourceRanges).To(ConsistOf("192.168.0.0/24", "172.158.0.0/24"))
 		})
 	})
 
+	DescribeTable("when the bastion ssh mode annotation is set",
+		func(mode string, expectedRanges []string) {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionSSHMode] = mode
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(4))
+			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(2)
+			Expect(actualRule.SourceRanges).To(Equal(expectedRanges))
+		},
+		Entry("the mode is public", firewall.SSHModePublic,
+			[]string{"128.0.0.0/24", "172.158.0.0/24", "192.168.0.0/24"}),
+		Entry("the mode is iap", firewall.SSHModeIAP,
+			[]string{firewall.IAPSourceRange}),
+		Entry("the mode is both", firewall.SSHModeBoth,
+			[]string{firewall.IAPSourceRange, "128.0.0.0/24", "172.158.0.0/24", "192.168.0.0/24"}),
+	)
+
+	When("the bastion ssh mode is none", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionSSHMode] = firewall.SSHModeNone
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("removes the bastion firewall rule", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(1))
+			Expect(firewallClient.DeleteRuleCallCount()).To(Equal(7))
+
+			_, _, actualRule := firewallClient.DeleteRuleArgsForCall(0)
+			Expect(actualRule).To(Equal("allow-the-gcp-cluster-bastion-ssh"))
+		})
+	})
+
+	When("the cluster egress is default deny", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationEgressDefaultDeny] = "true"
+			patchedCluster.Annotations[firewall.AnnotationEgressAllowListSubnets] = "203.0.113.0/24"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("applies the egress firewall rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			By("using the ip resolver to get the MC's NAT IPs")
+			Expect(egressIPResolver.GetIPsCallCount()).To(Equal(1))
+			_, clusterName := egressIPResolver.GetIPsArgsForCall(0)
+			Expect(clusterName).To(Equal(managementCluster))
+
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(5))
+
+			By("applying the allow rule before the deny rule")
+			_, _, allowRule := firewallClient.ApplyRuleArgsForCall(2)
+			Expect(allowRule.Name).To(Equal("allow-the-gcp-cluster-egress"))
+			Expect(allowRule.Direction).To(Equal(firewall.DirectionEgress))
+			Expect(allowRule.Priority).To(Equal(to.Int32P(firewall.EgressAllowPriority)))
+			Expect(allowRule.Allowed).To(ConsistOf(firewall.Allowed{IPProtocol: firewall.ProtocolAll}))
+			Expect(allowRule.TargetTags).To(Equal([]string{"the-gcp-cluster"}))
+			Expect(allowRule.DestinationRanges).To(Equal([]string{
+				"10.1.1.24/32",
+				"192.168.1.218/32",
+				"199.36.153.8/30",
+				"203.0.113.0/24",
+			}))
+
+			_, _, denyRule := firewallClient.ApplyRuleArgsForCall(3)
+			Expect(denyRule.Name).To(Equal("deny-the-gcp-cluster-egress"))
+			Expect(denyRule.Direction).To(Equal(firewall.DirectionEgress))
+			Expect(denyRule.Priority).To(Equal(to.Int32P(firewall.EgressDenyPriority)))
+			Expect(denyRule.Denied).To(ConsistOf(firewall.Denied{IPProtocol: firewall.ProtocolAll}))
+			Expect(denyRule.TargetTags).To(Equal([]string{"the-gcp-cluster"}))
+			Expect(denyRule.DestinationRanges).To(Equal([]string{firewall.AllIPv4Ranges}))
+
+			By("denying IPv6 egress in a separate rule")
+			_, _, denyIPv6Rule := firewallClient.ApplyRuleArgsForCall(4)
+			Expect(denyIPv6Rule.Name).To(Equal("deny-the-gcp-cluster-egress-ipv6"))
+			Expect(denyIPv6Rule.DestinationRanges).To(Equal([]string{firewall.AllIPv6Ranges}))
+		})
+
+		When("the operation of the allow rule is still running", func() {
+			var allowRulePending bool
+
+			BeforeEach(func() {
+				allowRulePending = true
+				firewallClient.ApplyRuleCalls(func(_ context.Context, _ *capg.GCPCluster, rule firewall.Rule) (bool, error) {
+					return allowRulePending && rule.Name == "allow-the-gcp-cluster-egress", nil
+				})
+			})
+
+			It("does not apply the deny rule yet", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(firewall.RequeueAfterEgressAllowRulePending))
+
+				Expect(firewallClient.ApplyRuleCallCount()).To(Equal(3))
+				_, _, allowRule := firewallClient.ApplyRuleArgsForCall(2)
+				Expect(allowRule.Name).To(Equal("allow-the-gcp-cluster-egress"))
+			})
+
+			When("the operation finished", func() {
+				JustBeforeEach(func() {
+					allowRulePending = false
+					result, reconcileErr = reconciler.Reconcile(ctx, request)
+				})
+
+				It("applies the deny rule", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(firewallClient.ApplyRuleCallCount()).To(Equal(8))
+
+					_, _, denyRule := firewallClient.ApplyRuleArgsForCall(6)
+					Expect(denyRule.Name).To(Equal("deny-the-gcp-cluster-egress"))
+					_, _, denyIPv6Rule := firewallClient.ApplyRuleArgsForCall(7)
+					Expect(denyIPv6Rule.Name).To(Equal("deny-the-gcp-cluster-egress-ipv6"))
+				})
+			})
+		})
+
+		When("the IP resolver fails", func() {
+			BeforeEach(func() {
+				egressIPResolver.GetIPsReturns(nil, errors.New("boom egress"))
+			})
+
+			It("does not apply the deny rule", func() {
+				Expect(reconcileErr).To(MatchError(ContainSubstring("boom egress")))
+				Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	When("the cluster egress is not default deny", func() {
+		It("removes the egress firewall rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(egressIPResolver.GetIPsCallCount()).To(Equal(0))
+
+			Expect(getDeletedRules(firewallClient)).To(ContainElements(
+				"deny-the-gcp-cluster-egress",
+				"deny-the-gcp-cluster-egress-ipv6",
+				"allow-the-gcp-cluster-egress",
+				"allow-the-gcp-cluster-egress-ipv6",
+			))
+		})
+
+		When("the cluster is reconciled again", func() {
+			JustBeforeEach(func() {
+				Expect(getDeletedRules(firewallClient)).To(ContainElement("deny-the-gcp-cluster-egress"))
+				deletedRuleCount := firewallClient.DeleteRuleCallCount()
+
+				result, reconcileErr = reconciler.Reconcile(ctx, request)
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(firewallClient.DeleteRuleCallCount()).To(Equal(deletedRuleCount))
+			})
+
+			It("does not delete the egress firewall rules again", func() {
+				Expect(getDeletedRules(firewallClient)).To(HaveLen(4))
+			})
+
+			When("the cluster enables and disables default deny egress", func() {
+				JustBeforeEach(func() {
+					actualCluster := &capg.GCPCluster{}
+					Expect(k8sClient.Get(ctx, request.NamespacedName, actualCluster)).To(Succeed())
+					patchedCluster := actualCluster.DeepCopy()
+					patchedCluster.Annotations[firewall.AnnotationEgressDefaultDeny] = "true"
+					Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(actualCluster))).To(Succeed())
+
+					result, reconcileErr = reconciler.Reconcile(ctx, request)
+					Expect(reconcileErr).NotTo(HaveOccurred())
+
+					Expect(k8sClient.Get(ctx, request.NamespacedName, actualCluster)).To(Succeed())
+					patchedCluster = actualCluster.DeepCopy()
+					delete(patchedCluster.Annotations, firewall.AnnotationEgressDefaultDeny)
+					Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(actualCluster))).To(Succeed())
+
+					result, reconcileErr = reconciler.Reconcile(ctx, request)
+				})
+
+				It("deletes the egress firewall rules", func() {
+					Expect(reconcileErr).NotTo(HaveOccurred())
+					Expect(getDeletedRules(firewallClient)).To(HaveLen(8))
+				})
+			})
+		})
+	})
+
+	When("firewall logging is enabled on the cluster", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationFirewallLogging] = firewall.LoggingIncludeAllMetadata
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("enables logging on all firewall rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(2))
+			for i := 0; i < firewallClient.ApplyRuleCallCount(); i++ {
+				_, _, actualRule := firewallClient.ApplyRuleArgsForCall(i)
+				Expect(actualRule.Logging).To(Equal(firewall.Logging{
+					Enabled:  true,
+					Metadata: firewall.LogMetadataIncludeAll,
+				}))
+			}
+		})
+	})
+
+	When("the firewall logging annotation is invalid", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationFirewallLogging] = "everything"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("everything")))
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(0))
+		})
+	})
+
+	When("api verbose logging is enabled on the cluster", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[security.AnnotationAPIVerboseLogging] = "true"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("sets the verbose log level on the security policy", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(1))
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.LogLevel).To(Equal(security.LogLevelVerbose))
+		})
+	})
+
+	When("the api verbose logging annotation is invalid", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[security.AnnotationAPIVerboseLogging] = "very"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("very")))
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(0))
+		})
+	})
+
+	When("the allowlists contain IPv6 ranges", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionAllowListSubnets] = "128.0.0.0/24,2001:db8::/32"
+			patchedCluster.Annotations[security.AnnotationAPIAllowListSubnets] = "10.0.0.0/24,2001:db8:1::/48"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("applies separate IPv4 and IPv6 bastion rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(3))
+			_, _, ipv4Rule := firewallClient.ApplyRuleArgsForCall(0)
+			Expect(ipv4Rule.Name).To(Equal("allow-the-gcp-cluster-bastion-ssh"))
+			Expect(ipv4Rule.SourceRanges).To(Equal([]string{"128.0.0.0/24", "192.168.0.0/24", "172.158.0.0/24"}))
+
+			_, _, ipv6Rule := firewallClient.ApplyRuleArgsForCall(1)
+			Expect(ipv6Rule.Name).To(Equal("allow-the-gcp-cluster-bastion-ssh-ipv6"))
+			Expect(ipv6Rule.SourceRanges).To(Equal([]string{"2001:db8::/32"}))
+			Expect(ipv6Rule.TargetTags).To(Equal([]string{"the-gcp-cluster-bastion"}))
+		})
+
+		It("applies separate IPv4 and IPv6 security policy rules", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(1))
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.Rules).To(HaveLen(5))
+			Expect(actualPolicy.Rules[0].SourceIPRanges).To(Equal([]string{"10.0.0.0/24"}))
+			Expect(actualPolicy.Rules[0].Priority).To(Equal(int32(1)))
+			Expect(actualPolicy.Rules[4].SourceIPRanges).To(Equal([]string{"2001:db8:1::/48"}))
+			Expect(actualPolicy.Rules[4].Priority).To(Equal(security.IPv6PriorityOffset + 1))
+		})
+	})
+
+	When("the allowlists contain overlapping and adjacent ranges", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionAllowListSubnets] = "192.168.1.0/24,192.168.0.7/32,172.158.0.1/24"
+			patchedCluster.Annotations[security.AnnotationAPIAllowListSubnets] = "10.0.1.0/24,10.0.0.0/24,10.0.0.0/25"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("only deduplicates and sorts the ranges", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
+			Expect(actualRule.SourceRanges).To(Equal([]string{
+				"172.158.0.0/24",
+				"192.168.0.0/24",
+				"192.168.0.7/32",
+				"192.168.1.0/24",
+			}))
+
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.Rules[0].SourceIPRanges).To(Equal([]string{
+				"10.0.0.0/24",
+				"10.0.0.0/25",
+				"10.0.1.0/24",
+			}))
+		})
+
+		When("range aggregation is enabled", func() {
+			BeforeEach(func() {
+				aggregateRanges = true
+			})
+
+			It("merges the ranges", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+
+				_, _, actualRule := firewallClient.ApplyRuleArgsForCall(0)
+				Expect(actualRule.SourceRanges).To(Equal([]string{
+					"172.158.0.0/24",
+					"192.168.0.0/23",
+				}))
+
+				_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+				Expect(actualPolicy.Rules[0].SourceIPRanges).To(Equal([]string{"10.0.0.0/23"}))
+			})
+		})
+	})
+
+	When("a break-glass allowlist is configured", func() {
+		BeforeEach(func() {
+			breakGlassAllowList = []string{"198.51.100.0/24", "2001:db8:ffff::/48"}
+		})
+
+		It("applies the break-glass rules with the highest priority", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(1))
+			_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+			Expect(actualPolicy.Rules).To(ContainElements(
+				security.PolicyRule{
+					Action:         security.ActionAllow,
+					Description:    security.BreakGlassRuleDescription,
+					SourceIPRanges: []string{"198.51.100.0/24"},
+					Priority:       security.BreakGlassRulePriority,
+				},
+				security.PolicyRule{
+					Action:         security.ActionAllow,
+					Description:    security.BreakGlassRuleDescription,
+					SourceIPRanges: []string{"2001:db8:ffff::/48"},
+					Priority:       security.BreakGlassRulePriority + security.IPv6PriorityOffset,
+				},
+			))
+		})
+	})
+
+	When("the bastion ssh mode is invalid", func() {
+		BeforeEach(func() {
+			patchedCluster := gcpCluster.DeepCopy()
+			patchedCluster.Annotations[firewall.AnnotationBastionSSHMode] = "telnet"
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(gcpCluster))).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("telnet")))
+			Expect(firewallClient.ApplyRuleCallCount()).To(Equal(0))
+		})
+	})
+
 	When("the api allow list annotation is missing", func() {
 		BeforeEach(func() {
 			patchedCluster := gcpCluster.DeepCopy()
@@ -639,7 +1621,7 @@ var _ = Describe("GCPClusterReconciler", func() {
 
 	When("the firewall client fails", func() {
 		BeforeEach(func() {
-			firewallClient.ApplyRuleReturns(errors.New("boom"))
+			firewallClient.ApplyRuleReturns(false, errors.New("boom"))
 		})
 
 		It("returns an error", func() {
@@ -647,6 +1629,80 @@ var _ = Describe("GCPClusterReconciler", func() {
 		})
 	})
 
+	When("the GCP API is still unavailable after retrying", func() {
+		BeforeEach(func() {
+			firewallClient.ApplyRuleReturns(false, &google.Error{
+				Kind: google.ErrorKindRetryable,
+				Err:  errors.New("boom"),
+			})
+		})
+
+		It("requeues after a fixed delay", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(controllers.RequeueAfterRetryable))
+		})
+	})
+
+	When("a GCP quota is exceeded", func() {
+		BeforeEach(func() {
+			securityPolicyClient.ApplyPolicyReturns(&google.Error{
+				Kind: google.ErrorKindQuota,
+				Err:  errors.New("boom"),
+			})
+		})
+
+		It("requeues after the quota delay", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(controllers.RequeueAfterQuotaExceeded))
+		})
+	})
+
+	When("several sub-reconcilers fail", func() {
+		BeforeEach(func() {
+			firewallClient.ApplyRuleReturns(false, &google.Error{
+				Kind: google.ErrorKindRetryable,
+				Err:  errors.New("boom firewall"),
+			})
+			securityPolicyClient.ApplyPolicyReturns(errors.New("boom security policy"))
+		})
+
+		It("returns all errors", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("boom firewall")))
+			Expect(reconcileErr).To(MatchError(ContainSubstring("boom security policy")))
+		})
+	})
+
+	When("the GCP API is unavailable and a GCP quota is exceeded", func() {
+		BeforeEach(func() {
+			firewallClient.ApplyRuleReturns(false, &google.Error{
+				Kind: google.ErrorKindRetryable,
+				Err:  errors.New("boom"),
+			})
+			securityPolicyClient.ApplyPolicyReturns(&google.Error{
+				Kind: google.ErrorKindQuota,
+				Err:  errors.New("boom"),
+			})
+		})
+
+		It("requeues after the longer delay", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(controllers.RequeueAfterQuotaExceeded))
+		})
+	})
+
+	When("the operator is not permitted to call the GCP API", func() {
+		BeforeEach(func() {
+			firewallClient.ApplyRuleReturns(false, &google.Error{
+				Kind: google.ErrorKindPermissionDenied,
+				Err:  errors.New("boom"),
+			})
+		})
+
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("permission denied: boom")))
+		})
+	})
+
 	When("the IP resolver fails", func() {
 		When("getting the MCs NAT IPs", func() {
 			BeforeEach(func() {
@@ -679,6 +1735,27 @@ var _ = Describe("GCPClusterReconciler", func() {
 		})
 	})
 
+	When("the security policy keeps changing while it is updated", func() {
+		BeforeEach(func() {
+			securityPolicyClient.ApplyPolicyReturns(&google.ConflictError{
+				Resource: "security policy",
+				Attempts: google.MaxConflictAttempts,
+				Err:      errors.New("precondition failed"),
+			})
+		})
+
+		It("returns an error", func() {
+			Expect(reconcileErr).To(MatchError(ContainSubstring("modified concurrently")))
+		})
+
+		It("records a warning event", func() {
+			Expect(recorder.Events).To(Receive(SatisfyAll(
+				ContainSubstring(corev1.EventTypeWarning),
+				ContainSubstring(security.EventReasonConcurrentModification),
+			)))
+		})
+	})
+
 	When("the context has been canceled", func() {
 		BeforeEach(func() {
 			var cancel context.CancelFunc
@@ -691,3 +1768,224 @@ var _ = Describe("GCPClusterReconciler", func() {
 		})
 	})
 })
+
+func getUserRanges(securityPolicyClient *securityfakes.FakeSecurityPolicyClient) []string {
+	Expect(securityPolicyClient.ApplyPolicyCallCount()).To(Equal(1))
+	_, _, actualPolicy := securityPolicyClient.ApplyPolicyArgsForCall(0)
+	for _, rule := range actualPolicy.Rules {
+		if rule.Description == "allow user specified ips to connect to kubernetes api" {
+			return rule.SourceIPRanges
+		}
+	}
+
+	return nil
+}
+
+func getDeletedRules(firewallClient *firewallfakes.FakeFirewallsClient) []string {
+	deletedRules := []string{}
+	for i := 0; i < firewallClient.DeleteRuleCallCount(); i++ {
+		_, _, ruleName := firewallClient.DeleteRuleArgsForCall(i)
+		deletedRules = append(deletedRules, ruleName)
+	}
+
+	return deletedRules
+}
+
+var _ = Describe("IgnoreStatusAnnotationUpdates", func() {
+	var gcpCluster *capg.GCPCluster
+
+	BeforeEach(func() {
+		gcpCluster = &capg.GCPCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "the-gcp-cluster",
+				ResourceVersion: "1",
+				Annotations: map[string]string{
+					security.AnnotationAPIAllowListSubnets:                   "10.0.0.0/24",
+					controllers.GetStatusAnnotation(firewall.ReconcilerName): controllers.StatusReady,
+				},
+			},
+		}
+	})
+
+	DescribeTable("update events",
+		func(modify func(*capg.GCPCluster), expected bool) {
+			updatedCluster := gcpCluster.DeepCopy()
+			updatedCluster.ResourceVersion = "2"
+			modify(updatedCluster)
+
+			updateEvent := event.UpdateEvent{ObjectOld: gcpCluster, ObjectNew: updatedCluster}
+			Expect(controllers.IgnoreStatusAnnotationUpdates().Update(updateEvent)).To(Equal(expected))
+		},
+		Entry("only the status changed", func(c *capg.GCPCluster) {
+			c.Annotations[controllers.GetStatusAnnotation(firewall.ReconcilerName)] = controllers.StatusFailedPrefix + "boom"
+		}, false),
+		Entry("a status was added", func(c *capg.GCPCluster) {
+			c.Annotations[controllers.GetStatusAnnotation(security.ReconcilerName)] = controllers.StatusReady
+		}, false),
+		Entry("the status and an allowlist changed", func(c *capg.GCPCluster) {
+			c.Annotations[controllers.GetStatusAnnotation(firewall.ReconcilerName)] = controllers.StatusFailedPrefix + "boom"
+			c.Annotations[security.AnnotationAPIAllowListSubnets] = "10.1.0.0/24"
+		}, true),
+		Entry("the status and the spec changed", func(c *capg.GCPCluster) {
+			c.Annotations[controllers.GetStatusAnnotation(firewall.ReconcilerName)] = controllers.StatusFailedPrefix + "boom"
+			c.Spec.Project = "another-project"
+		}, true),
+		Entry("an allowlist changed", func(c *capg.GCPCluster) {
+			c.Annotations[security.AnnotationAPIAllowListSubnets] = "10.1.0.0/24"
+		}, true),
+		Entry("nothing changed", func(c *capg.GCPCluster) {}, true),
+	)
+})
+
+var _ = Describe("GCPClusterReconciler with a shard", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		firewallClient *firewallfakes.FakeFirewallsClient
+		configMap      *corev1.ConfigMap
+	)
+
+	createCluster := func(name string, labels map[string]string) *capi.Cluster {
+		cluster := &capi.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: capi.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: capg.GroupVersion.String(),
+					Kind:       "GCPCluster",
+					Name:       name,
+					Namespace:  namespace,
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+		gcpCluster := &capg.GCPCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    labels,
+				Annotations: map[string]string{
+					allowlist.GetFromAnnotation(firewall.AnnotationBastionAllowListSubnets): "configmap/the-allowlist#ranges",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: capi.GroupVersion.String(),
+						Kind:       "Cluster",
+						Name:       cluster.Name,
+						UID:        cluster.UID,
+					},
+				},
+			},
+			Spec: capg.GCPClusterSpec{
+				Project: "the-gcp-project",
+			},
+		}
+		Expect(k8sClient.Create(ctx, gcpCluster)).To(Succeed())
+
+		tests.PatchClusterStatus(k8sClient, gcpCluster, capg.GCPClusterStatus{
+			Ready: true,
+			Network: capg.Network{
+				SelfLink:                to.StringP("something"),
+				APIServerBackendService: to.StringP("something"),
+				Router:                  to.StringP("something"),
+			},
+		})
+
+		return cluster
+	}
+
+	getReconciledClusters := func() []string {
+		names := []string{}
+		for i := 0; i < firewallClient.ApplyRuleCallCount(); i++ {
+			_, gcpCluster, _ := firewallClient.ApplyRuleArgsForCall(i)
+			names = append(names, gcpCluster.Name)
+		}
+
+		return names
+	}
+
+	BeforeEach(func() {
+		logger := zap.New(zap.WriteTo(GinkgoWriter))
+		ctx, cancel = context.WithCancel(log.IntoContext(context.Background(), logger))
+
+		firewallClient = new(firewallfakes.FakeFirewallsClient)
+		egressIPResolver := new(firewallfakes.FakeClusterNATIPResolver)
+		egressIPResolver.GetIPsReturns([]string{"10.1.1.24"}, nil)
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "the-allowlist",
+				Namespace: namespace,
+			},
+			Data: map[string]string{"ranges": "10.0.0.0/24"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:             scheme.Scheme,
+			Namespace:          namespace,
+			MetricsBindAddress: "0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		firewallReconciler := firewall.NewRuleReconciler(
+			[]string{"192.168.0.0/24"},
+			[]string{"130.211.0.0/22"},
+			[]string{"199.36.153.8/30"},
+			types.NamespacedName{Name: "the-mc-name", Namespace: "the-namespace"},
+			firewall.Logging{},
+			false,
+			firewallClient,
+			egressIPResolver,
+			record.NewFakeRecorder(100),
+		)
+		registry := controllers.NewRegistry().
+			Register(firewallReconciler, true, google.CollectionFirewalls)
+
+		reconciler := controllers.NewGCPClusterReconciler(
+			k8sclient.NewGCPCluster(mgr.GetClient()),
+			allowlist.NewResolver(mgr.GetAPIReader()),
+			registry,
+			google.NewOperationTracker(),
+		)
+
+		shard, err := sharding.New(nil, "shard=the-shard")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reconciler.SetupWithManager(mgr, 1, shard.Predicate())).To(Succeed())
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(ctx)).To(Succeed())
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("does not reconcile clusters outside of the shard", func() {
+		inShard := createCluster("in-shard", map[string]string{"shard": "the-shard"})
+		outOfShard := createCluster("out-of-shard", map[string]string{"shard": "another-shard"})
+		Eventually(getReconciledClusters, "10s").Should(ContainElement("in-shard"))
+
+		By("changing the referenced allowlist")
+		reconciles := firewallClient.ApplyRuleCallCount()
+		patchedConfigMap := configMap.DeepCopy()
+		patchedConfigMap.Data["ranges"] = "10.1.0.0/24"
+		Expect(k8sClient.Patch(ctx, patchedConfigMap, client.MergeFrom(configMap))).To(Succeed())
+		Eventually(firewallClient.ApplyRuleCallCount, "10s").Should(BeNumerically(">", reconciles))
+
+		By("changing the annotations of the CAPI Clusters")
+		for _, cluster := range []*capi.Cluster{inShard, outOfShard} {
+			patchedCluster := cluster.DeepCopy()
+			patchedCluster.Annotations = map[string]string{"giantswarm.io/description": "changed"}
+			Expect(k8sClient.Patch(ctx, patchedCluster, client.MergeFrom(cluster))).To(Succeed())
+		}
+
+		Consistently(getReconciledClusters, "2s").ShouldNot(ContainElement("out-of-shard"))
+	})
+})
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultIPFeedRefreshInterval is used for feeds without refresh
+	// interval.
+	DefaultIPFeedRefreshInterval = time.Hour
+
+	// RequeueAfterFetchFailed is how long feeds that failed to fetch wait
+	// before they are fetched again, unless their refresh interval is
+	// shorter.
+	RequeueAfterFetchFailed = time.Minute
+
+	// MaxConditionMessageLength is the length the errors of failed fetches
+	// are truncated to in the Ready condition, since they can contain
+	// content of the feed.
+	MaxConditionMessageLength = 256
+)
+
+type IPFeedFetcher interface {
+	Fetch(context.Context, v1alpha1.IPFeedSpec) ([]string, error)
+}
+
+type IPFeedClient interface {
+	Get(context.Context, types.NamespacedName) (*v1alpha1.IPFeed, error)
+	UpdateStatus(context.Context, *v1alpha1.IPFeed, v1alpha1.IPFeedStatus) error
+}
+
+// IPFeedReconciler fetches IPFeeds every refresh interval and stores their
+// ranges in their status. The ranges of the last successful fetch are kept
+// when fetching fails.
+type IPFeedReconciler struct {
+	client  IPFeedClient
+	fetcher IPFeedFetcher
+}
+
+func NewIPFeedReconciler(client IPFeedClient, fetcher IPFeedFetcher) *IPFeedReconciler {
+	return &IPFeedReconciler{
+		client:  client,
+		fetcher: fetcher,
+	}
+}
+
+// SetupWithManager registers the reconciler with the manager. Status
+// updates don't trigger a fetch, only spec changes and the refresh interval
+// do.
+func (r *IPFeedReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.IPFeed{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+func (r *IPFeedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.getLogger(ctx)
+
+	feed, err := r.client.Get(ctx, req.NamespacedName)
+	if err != nil {
+		if apimachineryerrors.IsNotFound(err) {
+			logger.Info("IPFeed no longer exists")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	refreshInterval := feed.Spec.RefreshInterval.Duration
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultIPFeedRefreshInterval
+	}
+
+	untilRefresh := getUntilRefresh(feed, refreshInterval, time.Now())
+	if untilRefresh > 0 {
+		return ctrl.Result{RequeueAfter: untilRefresh}, nil
+	}
+
+	status := *feed.Status.DeepCopy()
+	ranges, err := r.fetcher.Fetch(ctx, feed.Spec)
+	if err != nil {
+		logger.Error(err, "Failed to fetch IPFeed. Keeping the ranges of the last successful fetch", "url", feed.Spec.URL)
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             v1alpha1.ReasonFetchFailed,
+			Message:            truncate(err.Error(), MaxConditionMessageLength),
+			ObservedGeneration: feed.Generation,
+		})
+
+		err = r.client.UpdateStatus(ctx, feed, status)
+		if err != nil {
+			return ctrl.Result{}, errors.WithStack(err)
+		}
+
+		return ctrl.Result{RequeueAfter: minDuration(RequeueAfterFetchFailed, refreshInterval)}, nil
+	}
+
+	now := metav1.Now()
+	status.Ranges = ranges
+	status.LastFetchTime = &now
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonFetched,
+		Message:            "Feed was fetched",
+		ObservedGeneration: feed.Generation,
+	})
+
+	err = r.client.UpdateStatus(ctx, feed, status)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	logger.Info("Fetched IPFeed", "ranges", len(ranges))
+	return ctrl.Result{RequeueAfter: refreshInterval}, nil
+}
+
+// getUntilRefresh returns how long the ranges of the feed are still fresh.
+// Feeds whose spec changed since the last successful fetch are fetched
+// right away.
+func getUntilRefresh(feed *v1alpha1.IPFeed, refreshInterval time.Duration, now time.Time) time.Duration {
+	condition := meta.FindStatusCondition(feed.Status.Conditions, v1alpha1.ConditionReady)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.ObservedGeneration != feed.Generation {
+		return 0
+	}
+
+	if feed.Status.LastFetchTime == nil {
+		return 0
+	}
+
+	return feed.Status.LastFetchTime.Add(refreshInterval).Sub(now)
+}
+
+// truncate shortens message to length bytes, marking that it was cut.
+func truncate(message string, length int) string {
+	if len(message) <= length {
+		return message
+	}
+
+	return strings.ToValidUTF8(message[:length-3], "") + "..."
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func (r *IPFeedReconciler) getLogger(ctx context.Context) logr.Logger {
+	logger := log.FromContext(ctx)
+	return logger.WithName("ipfeed-reconciler")
+}
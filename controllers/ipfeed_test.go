@@ -0,0 +1,286 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+	"github.com/giantswarm/capg-firewall-rule-operator/controllers"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/ipfeed"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/k8sclient"
+)
+
+var _ = Describe("IPFeedReconciler", func() {
+	var (
+		ctx context.Context
+
+		server   *httptest.Server
+		mutex    sync.Mutex
+		status   int
+		body     string
+		requests int
+
+		reconciler *controllers.IPFeedReconciler
+		feed       *v1alpha1.IPFeed
+		request    ctrl.Request
+
+		result       ctrl.Result
+		reconcileErr error
+	)
+
+	getFeed := func() *v1alpha1.IPFeed {
+		actualFeed := &v1alpha1.IPFeed{}
+		err := k8sClient.Get(ctx, request.NamespacedName, actualFeed)
+		Expect(err).NotTo(HaveOccurred())
+		return actualFeed
+	}
+
+	setResponse := func(newStatus int, newBody string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		status = newStatus
+		body = newBody
+	}
+
+	BeforeEach(func() {
+		logger := zap.New(zap.WriteTo(GinkgoWriter))
+		ctx = log.IntoContext(context.Background(), logger)
+
+		requests = 0
+		setResponse(http.StatusOK, "# vendor egress IPs\n10.0.0.1\n10.1.0.0/24\n\n2001:db8::/32\n")
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			requests++
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+
+		reconciler = controllers.NewIPFeedReconciler(
+			k8sclient.NewIPFeed(k8sClient),
+			ipfeed.NewFetcher(server.Client()),
+		)
+
+		feed = &v1alpha1.IPFeed{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "the-feed",
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.IPFeedSpec{
+				URL:             server.URL,
+				RefreshInterval: metav1.Duration{Duration: time.Hour},
+			},
+		}
+
+		request = ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      "the-feed",
+				Namespace: namespace,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		Expect(k8sClient.Create(ctx, feed)).To(Succeed())
+		result, reconcileErr = reconciler.Reconcile(ctx, request)
+	})
+
+	It("stores the normalized ranges of the feed", func() {
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+		actualFeed := getFeed()
+		Expect(actualFeed.Status.Ranges).To(Equal([]string{"10.0.0.1/32", "10.1.0.0/24", "2001:db8::/32"}))
+		Expect(actualFeed.Status.LastFetchTime).NotTo(BeNil())
+		Expect(meta.IsStatusConditionTrue(actualFeed.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	When("the feed is reconciled again before the refresh interval passed", func() {
+		JustBeforeEach(func() {
+			result, reconcileErr = reconciler.Reconcile(ctx, request)
+		})
+
+		It("does not fetch the feed again", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(requests).To(Equal(1))
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+		})
+	})
+
+	When("fetching the feed fails after it was fetched", func() {
+		JustBeforeEach(func() {
+			setResponse(http.StatusInternalServerError, "")
+
+			actualFeed := getFeed()
+			patchedFeed := actualFeed.DeepCopy()
+			patchedFeed.Spec.RefreshInterval = metav1.Duration{Duration: 2 * time.Hour}
+			Expect(k8sClient.Patch(ctx, patchedFeed, client.MergeFrom(actualFeed))).To(Succeed())
+
+			result, reconcileErr = reconciler.Reconcile(ctx, request)
+		})
+
+		It("keeps the ranges of the last successful fetch", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(requests).To(Equal(2))
+			Expect(result.RequeueAfter).To(Equal(controllers.RequeueAfterFetchFailed))
+
+			actualFeed := getFeed()
+			Expect(actualFeed.Status.Ranges).To(Equal([]string{"10.0.0.1/32", "10.1.0.0/24", "2001:db8::/32"}))
+
+			condition := meta.FindStatusCondition(actualFeed.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(v1alpha1.ReasonFetchFailed))
+			Expect(condition.Message).To(ContainSubstring("500"))
+		})
+	})
+
+	When("the feed contains invalid ranges", func() {
+		BeforeEach(func() {
+			setResponse(http.StatusOK, "10.0.0.0/24\nnot-an-ip\n")
+		})
+
+		It("does not store any ranges", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			actualFeed := getFeed()
+			Expect(actualFeed.Status.Ranges).To(BeEmpty())
+			Expect(meta.IsStatusConditionFalse(actualFeed.Status.Conditions, v1alpha1.ConditionReady)).To(BeTrue())
+		})
+	})
+
+	DescribeTable("when the feed contains ranges that are too broad",
+		func(content, expectedMessage string) {
+			setResponse(http.StatusOK, content)
+
+			actualFeed := getFeed()
+			patchedFeed := actualFeed.DeepCopy()
+			patchedFeed.Spec.RefreshInterval = metav1.Duration{Duration: 2 * time.Hour}
+			Expect(k8sClient.Patch(ctx, patchedFeed, client.MergeFrom(actualFeed))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			actualFeed = getFeed()
+			Expect(actualFeed.Status.Ranges).To(Equal([]string{"10.0.0.1/32", "10.1.0.0/24", "2001:db8::/32"}))
+
+			condition := meta.FindStatusCondition(actualFeed.Status.Conditions, v1alpha1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring(expectedMessage))
+		},
+		Entry("all IPv4 addresses", "10.0.0.0/24\n0.0.0.0/0\n", "allows all addresses"),
+		Entry("all IPv6 addresses", "::/0\n", "allows all addresses"),
+		Entry("a loopback range", "127.0.0.1\n", "loopback range"),
+	)
+
+	When("the feed contains too many ranges", func() {
+		BeforeEach(func() {
+			content := strings.Builder{}
+			for i := 0; i <= ipfeed.MaxFeedRanges; i++ {
+				fmt.Fprintf(&content, "10.%d.%d.0/24\n", i/256, i%256)
+			}
+			setResponse(http.StatusOK, content.String())
+		})
+
+		It("does not store any ranges", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			actualFeed := getFeed()
+			Expect(actualFeed.Status.Ranges).To(BeEmpty())
+			Expect(meta.FindStatusCondition(actualFeed.Status.Conditions, v1alpha1.ConditionReady).Message).To(ContainSubstring("more than"))
+		})
+	})
+
+	When("the feed contains a long invalid entry", func() {
+		BeforeEach(func() {
+			setResponse(http.StatusOK, strings.Repeat("x", 10000)+"\n")
+		})
+
+		It("truncates the message of the condition", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+
+			condition := meta.FindStatusCondition(getFeed().Status.Conditions, v1alpha1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(len(condition.Message)).To(BeNumerically("<=", controllers.MaxConditionMessageLength))
+			Expect(condition.Message).To(HaveSuffix("..."))
+		})
+	})
+
+	It("rejects feeds that don't use https", func() {
+		insecureFeed := &v1alpha1.IPFeed{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "the-insecure-feed",
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.IPFeedSpec{
+				URL: "http://vendor.example.com/ips.txt",
+			},
+		}
+		Expect(k8sClient.Create(ctx, insecureFeed)).NotTo(Succeed())
+	})
+
+	When("the feed is JSON", func() {
+		BeforeEach(func() {
+			setResponse(http.StatusOK, `{
+				"prefixes": [
+					{"ip_prefix": "13.0.0.0/16", "service": "CI"},
+					{"ip_prefix": "52.0.0.0/16", "service": "CI"},
+					{"ip_prefix": "2600:1f00::/40", "service": "CI"}
+				],
+				"hooks": ["13.1.0.0/16", "13.2.0.0/16"]
+			}`)
+			feed.Spec.Format = v1alpha1.IPFeedFormatJSON
+			feed.Spec.JSONPath = "{.prefixes[*].ip_prefix}"
+		})
+
+		It("stores the ranges selected by the JSONPath", func() {
+			Expect(reconcileErr).NotTo(HaveOccurred())
+			Expect(getFeed().Status.Ranges).To(Equal([]string{"13.0.0.0/16", "52.0.0.0/16", "2600:1f00::/40"}))
+		})
+
+		When("the JSONPath selects a list", func() {
+			BeforeEach(func() {
+				feed.Spec.JSONPath = ".hooks"
+			})
+
+			It("stores the ranges of the list", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(getFeed().Status.Ranges).To(Equal([]string{"13.1.0.0/16", "13.2.0.0/16"}))
+			})
+		})
+
+		When("the feed has a filter", func() {
+			BeforeEach(func() {
+				feed.Spec.Filter = &v1alpha1.IPFeedFilter{
+					IPFamily: v1alpha1.IPFamilyIPv4,
+					Pattern:  `^13\.`,
+				}
+			})
+
+			It("only stores the matching ranges", func() {
+				Expect(reconcileErr).NotTo(HaveOccurred())
+				Expect(getFeed().Status.Ranges).To(Equal([]string{"13.0.0.0/16"}))
+			})
+		})
+	})
+})
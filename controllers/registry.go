@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// SubReconciler manages a group of GCP resources of a cluster. Its name is
+// part of the finalizer guarding the resources and of the annotation with
+// its status, so it must not change.
+type SubReconciler interface {
+	Name() string
+	Reconcile(context.Context, *capg.GCPCluster) (ctrl.Result, error)
+	ReconcileDelete(context.Context, *capg.GCPCluster) error
+}
+
+// AllowListReader is implemented by sub-reconcilers that read allowlist
+// annotations. They are only skipped when one of the allowlists they read
+// can't be resolved. Sub-reconcilers that don't implement it are skipped when
+// any allowlist can't be resolved.
+type AllowListReader interface {
+	AllowListAnnotations() []string
+}
+
+// Registry is the ordered list of sub-reconcilers the GCPCluster controller
+// runs.
+type Registry struct {
+	entries []registryEntry
+}
+
+type registryEntry struct {
+	subReconciler        SubReconciler
+	enabled              bool
+	operationCollections []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a sub-reconciler after the ones registered before.
+// Disabled sub-reconcilers don't reconcile clusters, but still delete the
+// resources of clusters that have their finalizer. operationCollections are
+// the collections of the GCP resources the sub-reconciler changes, like
+// google.CollectionFirewalls. Their operations are waited for before the
+// finalizer of a deleted cluster is removed.
+func (r *Registry) Register(subReconciler SubReconciler, enabled bool, operationCollections ...string) *Registry {
+	r.entries = append(r.entries, registryEntry{
+		subReconciler:        subReconciler,
+		enabled:              enabled,
+		operationCollections: operationCollections,
+	})
+
+	return r
+}
+
+// GetFinalizer returns the finalizer guarding the resources of the
+// sub-reconciler with the given name.
+func GetFinalizer(subReconcilerName string) string {
+	return FinalizerFirewall + "/" + subReconcilerName
+}
+
+// GetStatusAnnotation returns the annotation with the status of the
+// sub-reconciler with the given name.
+func GetStatusAnnotation(subReconcilerName string) string {
+	return AnnotationStatusPrefix + "/" + subReconcilerName
+}
@@ -19,30 +19,40 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	gcpcompute "cloud.google.com/go/compute/apiv1"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/api/option"
 
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	k8sruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
 	"github.com/giantswarm/capg-firewall-rule-operator/controllers"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/allowlist"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/config"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/ipfeed"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/k8sclient"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/nat"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/readiness"
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/sharding"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -55,6 +65,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(capg.AddToScheme(scheme))
 	utilruntime.Must(capi.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 
 	// +kubebuilder:scaffold:scheme
 }
@@ -64,13 +75,15 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
-	var managementClusterName string
-	var managementClusterNamespace string
-	var defaultAPIAllowListFlag string
-	var defaultBastionHostAllowListFlag string
+	var watchNamespacesFlag string
+	var clusterSelectorFlag string
+	var breakGlassAllowListFlag string
+	var configFile string
+	var flagConfig config.Config
 
 	flag.StringVar(&gcpProject, "gcp-project", "",
-		"The gcp project id where the firewall records will be created.")
+		"The GCP project whose credentials and permissions are checked by the readiness probe. "+
+			"Defaults to the project of the management cluster")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
 		"The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081",
@@ -78,14 +91,58 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	flag.StringVar(&managementClusterName, "management-cluster-name", "",
+	flag.StringVar(&flagConfig.ManagementCluster.Name, "management-cluster-name", "",
 		"The name of the Cluster CR for the management cluster")
-	flag.StringVar(&managementClusterNamespace, "management-cluster-namespace", "",
+	flag.StringVar(&flagConfig.ManagementCluster.Namespace, "management-cluster-namespace", "",
 		"The namespace of the Cluster CR for the management cluster")
-	flag.StringVar(&defaultAPIAllowListFlag, "default-api-allow-list", "",
+	flag.StringVar(&flagConfig.Defaults.APIAllowList, "default-api-allow-list", "",
 		"Comma separated list of CIDRs that are allowed to reach the Kubernetes API")
-	flag.StringVar(&defaultBastionHostAllowListFlag, "default-bastion-host-allow-list", "",
+	flag.StringVar(&breakGlassAllowListFlag, "break-glass-allow-list", "",
+		"Comma separated list of CIDRs that are always allowed to reach the Kubernetes API. "+
+			"The break-glass rule has the highest priority and is never removed by the operator. "+
+			"It is not part of the --config file, so changes require a restart")
+	flag.StringVar(&flagConfig.Defaults.BastionHostAllowList, "default-bastion-host-allow-list", "",
 		"Comma separated list of CIDRs that are allowed to ssh to the Bastion hosts")
+	flag.StringVar(&flagConfig.Defaults.ControlPlaneAllowList, "control-plane-allow-list", "130.211.0.0/22,35.191.0.0/16",
+		"Comma separated list of CIDRs that are allowed to reach the Kubernetes API port on control plane nodes. "+
+			"Defaults to the Google load balancer and health check ranges")
+	flag.StringVar(&flagConfig.Defaults.EgressAllowList, "default-egress-allow-list",
+		"10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,199.36.153.4/30,199.36.153.8/30",
+		"Comma separated list of CIDRs that nodes of clusters with default deny egress are allowed to reach. "+
+			"Defaults to the private ranges and the private and restricted Google APIs ranges. "+
+			"Container registries outside of Google, like docker.io or quay.io, are not included")
+	flag.StringVar(&flagConfig.Defaults.FirewallLogging, "default-firewall-logging", firewall.LoggingDisabled,
+		"Logging of the managed firewall rules unless overridden per cluster. "+
+			"One of disabled, include-all-metadata or exclude-all-metadata")
+	flag.BoolVar(&flagConfig.Defaults.APIVerboseLogging, "default-api-verbose-logging", false,
+		"Enable verbose Cloud Armor logging on the Kubernetes API security policy unless overridden per cluster")
+	flag.StringVar(&flagConfig.SecurityPolicyUpdateMode, "security-policy-update-mode", security.UpdateModeInPlace,
+		"How security policies are changed. in-place updates the rules of the attached policy. "+
+			"blue-green attaches a new version of the policy and deletes the previous one")
+	flag.BoolVar(&flagConfig.Defaults.AggregateRanges, "aggregate-ranges", false,
+		"Merge overlapping and adjacent CIDRs in firewall rules and security policies")
+	flag.IntVar(&flagConfig.GCP.Retry.Attempts, "gcp-retry-attempts", google.DefaultRetryAttempts,
+		"How often GCP API calls are attempted when they fail with a transient error or rate limit")
+	flag.DurationVar(&flagConfig.GCP.Retry.InitialDelay.Duration, "gcp-retry-initial-delay", google.DefaultRetryInitialDelay,
+		"The delay before the first retry of a GCP API call. It doubles with every retry")
+	flag.DurationVar(&flagConfig.GCP.Retry.MaxDelay.Duration, "gcp-retry-max-delay", google.DefaultRetryMaxDelay,
+		"The maximum delay between retries of a GCP API call")
+	flag.BoolVar(&flagConfig.Reconcilers.FirewallRules, "enable-firewall-rules", true,
+		"Reconcile the firewall rules of clusters. When disabled, existing rules are still deleted with their cluster")
+	flag.BoolVar(&flagConfig.Reconcilers.SecurityPolicy, "enable-security-policy", true,
+		"Reconcile the security policies of clusters. When disabled, existing policies are still deleted with their cluster")
+	flag.IntVar(&flagConfig.MaxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of clusters that are reconciled at the same time")
+	flag.Float64Var(&flagConfig.GCP.RateLimit.QPS, "gcp-api-qps", google.DefaultRateLimitQPS,
+		"The number of GCP API requests per second allowed per project and API. Zero disables the limit")
+	flag.IntVar(&flagConfig.GCP.RateLimit.Burst, "gcp-api-burst", google.DefaultRateLimitBurst,
+		"The number of GCP API requests per project and API that can exceed the QPS limit in bursts")
+	flag.StringVar(&watchNamespacesFlag, "watch-namespaces", "",
+		"Comma separated list of namespaces to reconcile GCPClusters in. Empty means all namespaces")
+	flag.StringVar(&clusterSelectorFlag, "cluster-selector", "",
+		"Label selector of the GCPClusters to reconcile. Empty means all GCPClusters")
+	flag.StringVar(&configFile, "config", "",
+		"Path to an OperatorConfig file. Its values override the flags, and changes to its defaults are applied without a restart")
 
 	opts := zap.Options{
 		Development: true,
@@ -98,102 +155,254 @@ func main() {
 
 	ctrl.SetLogger(logger)
 
+	flagConfig.APIVersion = config.APIVersion
+	flagConfig.Kind = config.Kind
+	var err error
+	operatorConfig := flagConfig
+	if configFile != "" {
+		operatorConfig, err = config.Load(configFile, flagConfig)
+	} else {
+		err = operatorConfig.Validate()
+	}
+	if err != nil {
+		setupLog.Error(err, "invalid config")
+		os.Exit(1)
+	}
+
+	var watchNamespaces []string
+	if watchNamespacesFlag != "" {
+		watchNamespaces = strings.Split(watchNamespacesFlag, ",")
+	}
+
+	shard, err := sharding.New(watchNamespaces, clusterSelectorFlag)
+	if err != nil {
+		setupLog.Error(err, "failed to parse cluster selector")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "d632xu17.giantswarm.io",
+		LeaderElectionID:       shard.LeaderElectionID("d632xu17.giantswarm.io"),
+		NewCache:               shard.NewCache(),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	firewalls, err := gcpcompute.NewFirewallsRESTClient(context.Background())
+	gcpClientOptions := []option.ClientOption{}
+	if operatorConfig.GCP.RateLimit.QPS > 0 {
+		rateLimiter := google.NewRateLimiter(operatorConfig.GCP.RateLimit.QPS, operatorConfig.GCP.RateLimit.Burst)
+		httpClient, err := google.NewHTTPClient(context.Background(), rateLimiter)
+		if err != nil {
+			setupLog.Error(err, "failed to create rate limited GCP HTTP client")
+			os.Exit(1)
+		}
+		gcpClientOptions = append(gcpClientOptions, option.WithHTTPClient(httpClient))
+	}
+
+	firewalls, err := gcpcompute.NewFirewallsRESTClient(context.Background(), gcpClientOptions...)
 	if err != nil {
 		setupLog.Error(err, "failed to create Cloud Firewall Rules client")
 		os.Exit(1)
 	}
 	defer firewalls.Close()
 
-	securityPolicies, err := gcpcompute.NewSecurityPoliciesRESTClient(context.Background())
+	securityPolicies, err := gcpcompute.NewSecurityPoliciesRESTClient(context.Background(), gcpClientOptions...)
 	if err != nil {
 		setupLog.Error(err, "failed to create Cloud Security Policies client")
 		os.Exit(1)
 	}
 	defer securityPolicies.Close()
 
-	backendServices, err := gcpcompute.NewBackendServicesRESTClient(context.Background())
+	backendServices, err := gcpcompute.NewBackendServicesRESTClient(context.Background(), gcpClientOptions...)
 	if err != nil {
 		setupLog.Error(err, "failed to create Cloud Security Policies client")
 		os.Exit(1)
 	}
 	defer backendServices.Close()
 
-	addresses, err := gcpcompute.NewAddressesRESTClient(context.Background())
+	addresses, err := gcpcompute.NewAddressesRESTClient(context.Background(), gcpClientOptions...)
 	if err != nil {
 		setupLog.Error(err, "failed to create Cloud Addresses client")
 		os.Exit(1)
 	}
 	defer addresses.Close()
 
-	routers, err := gcpcompute.NewRoutersRESTClient(context.Background())
+	routers, err := gcpcompute.NewRoutersRESTClient(context.Background(), gcpClientOptions...)
 	if err != nil {
 		setupLog.Error(err, "failed to create Cloud Routers client")
 		os.Exit(1)
 	}
 	defer routers.Close()
 
+	retryConfig := operatorConfig.GetRetryConfig()
 	client := k8sclient.NewGCPCluster(mgr.GetClient())
-	firewallClient := firewall.NewClient(firewalls)
-	securityPolicyClient := security.NewClient(securityPolicies, backendServices)
-	ipResolver := nat.NewIPResolver(client, addresses, routers)
-	managementCluster := types.NamespacedName{
-		Name:      managementClusterName,
-		Namespace: managementClusterNamespace,
+	operations := google.NewOperationTracker()
+	firewallClient := firewall.NewClient(firewalls, retryConfig, operations)
+	var securityPolicyClient security.SecurityPolicyClient
+	switch operatorConfig.SecurityPolicyUpdateMode {
+	case security.UpdateModeInPlace:
+		securityPolicyClient = security.NewClient(securityPolicies, backendServices, retryConfig, operations)
+	case security.UpdateModeBlueGreen:
+		securityPolicyClient = security.NewBlueGreenClient(securityPolicies, backendServices, retryConfig, operations)
+	}
+	// The management cluster is usually not part of the shard, so it can't
+	// be read from the cache.
+	managementClusterClient := client
+	if !shard.IsDefault() {
+		uncachedClient, err := k8sruntimeclient.New(mgr.GetConfig(), k8sruntimeclient.Options{
+			Scheme: mgr.GetScheme(),
+			Mapper: mgr.GetRESTMapper(),
+		})
+		if err != nil {
+			setupLog.Error(err, "failed to create uncached client")
+			os.Exit(1)
+		}
+		managementClusterClient = k8sclient.NewGCPCluster(uncachedClient)
+	}
+	ipResolver := nat.NewIPResolver(managementClusterClient, addresses, routers, retryConfig)
+	recorder := mgr.GetEventRecorderFor("capg-firewall-rule-operator")
+	managementCluster := operatorConfig.GetManagementCluster()
+
+	breakGlassAllowList, err := cidr.ParseFromCommaSeparated(breakGlassAllowListFlag)
+	if err != nil {
+		setupLog.Error(err, "failed to parse break-glass allow list cidrs")
+		os.Exit(1)
 	}
 
-	defaultAPIAllowList, err := cidr.ParseFromCommaSeparated(defaultAPIAllowListFlag)
+	securityDefaults, err := operatorConfig.Defaults.Security()
 	if err != nil {
-		setupLog.Error(err, "failed to parse default allow list cidrs")
+		setupLog.Error(err, "invalid security policy defaults")
 		os.Exit(1)
 	}
 
 	securityPolicyReconciler := security.NewPolicyReconciler(
-		defaultAPIAllowList,
+		breakGlassAllowList,
+		securityDefaults.APIAllowList,
 		managementCluster,
+		securityDefaults.VerboseLogging,
+		securityDefaults.AggregateRanges,
 		securityPolicyClient,
 		ipResolver,
+		recorder,
 	)
 
-	defaultBastionHostAllowList, err := cidr.ParseFromCommaSeparated(defaultBastionHostAllowListFlag)
+	firewallDefaults, err := operatorConfig.Defaults.Firewall()
 	if err != nil {
-		setupLog.Error(err, "failed to parse default allow list cidrs")
+		setupLog.Error(err, "invalid firewall defaults")
 		os.Exit(1)
 	}
 
-	firewallReconciler := firewall.NewRuleReconciler(defaultBastionHostAllowList, firewallClient)
+	firewallReconciler := firewall.NewRuleReconciler(
+		firewallDefaults.BastionHostAllowList,
+		firewallDefaults.ControlPlaneAllowList,
+		firewallDefaults.EgressAllowList,
+		managementCluster,
+		firewallDefaults.Logging,
+		firewallDefaults.AggregateRanges,
+		firewallClient,
+		ipResolver,
+		recorder,
+	)
+
+	registry := controllers.NewRegistry().
+		Register(firewallReconciler, operatorConfig.Reconcilers.FirewallRules, google.CollectionFirewalls).
+		Register(securityPolicyReconciler, operatorConfig.Reconcilers.SecurityPolicy,
+			google.CollectionSecurityPolicies, google.CollectionBackendServices)
 
 	controller := controllers.NewGCPClusterReconciler(
 		client,
-		firewallReconciler,
-		securityPolicyReconciler,
+		allowlist.NewResolver(mgr.GetAPIReader()),
+		registry,
+		operations,
 	)
 
-	err = controller.SetupWithManager(mgr)
+	err = controller.SetupWithManager(mgr, operatorConfig.MaxConcurrentReconciles, shard.Predicate())
 	if err != nil {
 		setupLog.Error(err, "failed to setup controller", "controller", "GCPCluster")
 		os.Exit(1)
 	}
 
+	ipFeedReconciler := controllers.NewIPFeedReconciler(
+		k8sclient.NewIPFeed(mgr.GetClient()),
+		ipfeed.NewFetcher(&http.Client{Timeout: ipfeed.DefaultTimeout}),
+	)
+	err = ipFeedReconciler.SetupWithManager(mgr)
+	if err != nil {
+		setupLog.Error(err, "failed to setup controller", "controller", "IPFeed")
+		os.Exit(1)
+	}
+
+	if configFile != "" {
+		watcher, err := config.NewWatcher(configFile, flagConfig, operatorConfig, config.DefaultWatchInterval,
+			func(ctx context.Context, newConfig config.Config) error {
+				securityDefaults, err := newConfig.Defaults.Security()
+				if err != nil {
+					return err
+				}
+				firewallDefaults, err := newConfig.Defaults.Firewall()
+				if err != nil {
+					return err
+				}
+
+				securityPolicyReconciler.SetDefaults(securityDefaults)
+				firewallReconciler.SetDefaults(firewallDefaults)
+				return controller.ReconcileAll(ctx)
+			})
+		if err != nil {
+			setupLog.Error(err, "failed to create config watcher")
+			os.Exit(1)
+		}
+
+		err = mgr.Add(watcher)
+		if err != nil {
+			setupLog.Error(err, "failed to add config watcher")
+			os.Exit(1)
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
+	// The project of the management cluster is checked unless a project is
+	// configured, since the operator credentials usually belong to it.
+	getProject := func(ctx context.Context) (string, error) {
+		if gcpProject != "" {
+			return gcpProject, nil
+		}
+
+		gcpCluster, err := managementClusterClient.Get(ctx, managementCluster)
+		if err != nil {
+			return "", err
+		}
+
+		return gcpCluster.Spec.Project, nil
+	}
+	gcpChecker := readiness.NewGCPChecker(
+		getProject,
+		firewalls,
+		securityPolicies,
+		backendServices,
+		routers,
+		addresses,
+		readiness.DefaultCheckInterval,
+	)
+	if err := mgr.Add(gcpChecker); err != nil {
+		setupLog.Error(err, "unable to add GCP readiness check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("gcp", gcpChecker.Check); err != nil {
+		setupLog.Error(err, "unable to set up GCP ready check")
+		os.Exit(1)
+	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
@@ -0,0 +1,13 @@
+package allowlist_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAllowList(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AllowList Suite")
+}
@@ -0,0 +1,197 @@
+package allowlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+)
+
+const (
+	// AnnotationSuffixFrom is appended to an allowlist annotation for the
+	// annotation referencing ConfigMap or Secret keys or IPFeeds with more
+	// entries, like api.gcp.giantswarm.io/allowlist-from.
+	AnnotationSuffixFrom = "-from"
+
+	ReferenceKindConfigMap = "configmap"
+	ReferenceKindSecret    = "secret"
+	// ReferenceKindIPFeed references the ranges of an IPFeed. It has no key.
+	ReferenceKindIPFeed = "ipfeed"
+
+	// IndexReferences is the field index of GCPClusters and CAPI Clusters by
+	// the ConfigMaps, Secrets and IPFeeds their allowlists reference, in the
+	// <kind>/<name> format.
+	IndexReferences = "capg-firewall-rule-operator.giantswarm.io/allowlist-references"
+)
+
+// Reference is a key of a ConfigMap or Secret in the namespace of the
+// cluster, written as configmap/<name>#<key> or secret/<name>#<key>, or the
+// ranges of an IPFeed in the namespace of the cluster, written as
+// ipfeed/<name>.
+type Reference struct {
+	Kind string
+	Name string
+	Key  string
+}
+
+// GetFromAnnotation returns the annotation referencing ConfigMaps, Secrets
+// and IPFeeds for the allowlist annotation.
+func GetFromAnnotation(annotation string) string {
+	return annotation + AnnotationSuffixFrom
+}
+
+// ParseReferences parses a comma separated list of references.
+func ParseReferences(value string) ([]Reference, error) {
+	references := []Reference{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		reference, err := ParseReference(entry)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		references = append(references, reference)
+	}
+
+	return references, nil
+}
+
+func ParseReference(value string) (Reference, error) {
+	object, key, hasKey := strings.Cut(value, "#")
+	kind, name, ok := strings.Cut(object, "/")
+	if !ok || name == "" {
+		return Reference{}, fmt.Errorf("reference %q has no name, expected <kind>/<name>#<key> or ipfeed/<name>", value)
+	}
+
+	switch kind {
+	case ReferenceKindConfigMap, ReferenceKindSecret:
+		if !hasKey || key == "" {
+			return Reference{}, fmt.Errorf("reference %q has no key, expected <kind>/<name>#<key>", value)
+		}
+	case ReferenceKindIPFeed:
+		if hasKey {
+			return Reference{}, fmt.Errorf("reference %q has a key, expected ipfeed/<name>", value)
+		}
+	default:
+		return Reference{}, fmt.Errorf("reference %q has invalid kind %q, expected %q, %q or %q",
+			value, kind, ReferenceKindConfigMap, ReferenceKindSecret, ReferenceKindIPFeed)
+	}
+
+	return Reference{
+		Kind: kind,
+		Name: name,
+		Key:  key,
+	}, nil
+}
+
+func (r Reference) String() string {
+	if r.Key == "" {
+		return GetIndexValue(r.Kind, r.Name)
+	}
+
+	return fmt.Sprintf("%s/%s#%s", r.Kind, r.Name, r.Key)
+}
+
+// GetIndexValue returns the value of the IndexReferences index for the
+// object of the reference.
+func (r Reference) GetIndexValue() string {
+	return GetIndexValue(r.Kind, r.Name)
+}
+
+// GetIndexValue returns the value of the IndexReferences index for the
+// ConfigMap, Secret or IPFeed with the given kind and name.
+func GetIndexValue(kind, name string) string {
+	return kind + "/" + name
+}
+
+// IndexFieldReferences adds the IndexReferences index for GCPClusters and
+// CAPI Clusters to indexer.
+func IndexFieldReferences(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, obj := range []client.Object{&capg.GCPCluster{}, &capi.Cluster{}} {
+		err := indexer.IndexField(ctx, obj, IndexReferences, getIndexValues)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// getIndexValues returns the objects referenced by the allowlists of obj.
+// Invalid references are skipped, since they fail the reconciliation anyway.
+func getIndexValues(obj client.Object) []string {
+	values := []string{}
+	for _, annotation := range Annotations {
+		references, err := ParseReferences(obj.GetAnnotations()[GetFromAnnotation(annotation)])
+		if err != nil {
+			continue
+		}
+
+		for _, reference := range references {
+			values = append(values, reference.GetIndexValue())
+		}
+	}
+
+	return values
+}
+
+// readReference reads the key of the referenced ConfigMap or Secret, or the
+// ranges of the referenced IPFeed, in namespace. IPFeeds that were never
+// fetched successfully fail.
+func readReference(ctx context.Context, reader client.Reader, namespace string, reference Reference) (string, error) {
+	key := types.NamespacedName{
+		Name:      reference.Name,
+		Namespace: namespace,
+	}
+
+	switch reference.Kind {
+	case ReferenceKindConfigMap:
+		configMap := &corev1.ConfigMap{}
+		err := reader.Get(ctx, key, configMap)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get allowlist %s", reference)
+		}
+
+		value, ok := configMap.Data[reference.Key]
+		if !ok {
+			return "", fmt.Errorf("allowlist %s does not exist", reference)
+		}
+		return value, nil
+	case ReferenceKindSecret:
+		secret := &corev1.Secret{}
+		err := reader.Get(ctx, key, secret)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get allowlist %s", reference)
+		}
+
+		value, ok := secret.Data[reference.Key]
+		if !ok {
+			return "", fmt.Errorf("allowlist %s does not exist", reference)
+		}
+		return string(value), nil
+	case ReferenceKindIPFeed:
+		feed := &v1alpha1.IPFeed{}
+		err := reader.Get(ctx, key, feed)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get allowlist %s", reference)
+		}
+
+		if len(feed.Status.Ranges) == 0 {
+			return "", fmt.Errorf("allowlist %s has not been fetched yet", reference)
+		}
+		return strings.Join(feed.Status.Ranges, "\n"), nil
+	}
+
+	return "", fmt.Errorf("allowlist %s has invalid kind", reference)
+}
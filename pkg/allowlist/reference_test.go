@@ -0,0 +1,65 @@
+package allowlist_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/allowlist"
+)
+
+var _ = Describe("Reference", func() {
+	DescribeTable("ParseReference with valid references",
+		func(value string, expected allowlist.Reference) {
+			reference, err := allowlist.ParseReference(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reference).To(Equal(expected))
+			Expect(reference.String()).To(Equal(value))
+		},
+		Entry("ConfigMap key", "configmap/the-allowlist#api",
+			allowlist.Reference{Kind: allowlist.ReferenceKindConfigMap, Name: "the-allowlist", Key: "api"}),
+		Entry("Secret key", "secret/the-allowlist#api",
+			allowlist.Reference{Kind: allowlist.ReferenceKindSecret, Name: "the-allowlist", Key: "api"}),
+		Entry("IPFeed", "ipfeed/the-feed",
+			allowlist.Reference{Kind: allowlist.ReferenceKindIPFeed, Name: "the-feed"}),
+	)
+
+	DescribeTable("ParseReference with invalid references",
+		func(value string, expectedMessage string) {
+			_, err := allowlist.ParseReference(value)
+			Expect(err).To(MatchError(ContainSubstring(expectedMessage)))
+		},
+		Entry("no kind", "the-allowlist#api", "has no name"),
+		Entry("no name", "configmap/#api", "has no name"),
+		Entry("ConfigMap without key", "configmap/the-allowlist", "has no key"),
+		Entry("ConfigMap with empty key", "configmap/the-allowlist#", "has no key"),
+		Entry("Secret without key", "secret/the-allowlist", "has no key"),
+		Entry("IPFeed with key", "ipfeed/the-feed#api", "has a key"),
+		Entry("unknown kind", "deployment/the-allowlist#api", `has invalid kind "deployment"`),
+		Entry("uppercase kind", "ConfigMap/the-allowlist#api", `has invalid kind "ConfigMap"`),
+	)
+
+	It("parses comma separated references", func() {
+		references, err := allowlist.ParseReferences(" configmap/the-allowlist#api, ,secret/the-secret#api,ipfeed/the-feed,")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(references).To(Equal([]allowlist.Reference{
+			{Kind: allowlist.ReferenceKindConfigMap, Name: "the-allowlist", Key: "api"},
+			{Kind: allowlist.ReferenceKindSecret, Name: "the-secret", Key: "api"},
+			{Kind: allowlist.ReferenceKindIPFeed, Name: "the-feed"},
+		}))
+	})
+
+	It("fails when one of the references is invalid", func() {
+		_, err := allowlist.ParseReferences("configmap/the-allowlist#api,secret/the-secret")
+		Expect(err).To(MatchError(ContainSubstring(`reference "secret/the-secret" has no key`)))
+	})
+
+	It("returns the index value of the referenced object", func() {
+		reference := allowlist.Reference{Kind: allowlist.ReferenceKindSecret, Name: "the-secret", Key: "api"}
+		Expect(reference.GetIndexValue()).To(Equal("secret/the-secret"))
+		Expect(allowlist.GetIndexValue(allowlist.ReferenceKindSecret, "the-secret")).To(Equal("secret/the-secret"))
+	})
+
+	It("returns the from annotation", func() {
+		Expect(allowlist.GetFromAnnotation("api.gcp.giantswarm.io/allowlist")).To(Equal("api.gcp.giantswarm.io/allowlist-from"))
+	})
+})
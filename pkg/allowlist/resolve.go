@@ -0,0 +1,201 @@
+// Package allowlist resolves the allowlist annotations of GCPClusters from
+// the objects they can be set on and the ConfigMaps and Secrets they
+// reference.
+package allowlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+// VariableAllowLists is the ClusterClass topology variable with the
+// allowlists of a cluster, like
+//
+//	variables:
+//	- name: firewallAllowLists
+//	  value:
+//	    api: "10.0.0.0/24,172.158.0.0/24"
+//	    bastion: "128.0.0.0/24"
+//	    egress: "203.0.113.0/24"
+//
+// The values use the same format as the allowlist annotations.
+const VariableAllowLists = "firewallAllowLists"
+
+// Annotations are the allowlist annotations that are resolved.
+var Annotations = []string{
+	security.AnnotationAPIAllowListSubnets,
+	firewall.AnnotationBastionAllowListSubnets,
+	firewall.AnnotationEgressAllowListSubnets,
+}
+
+// Variable is the value of the VariableAllowLists topology variable.
+type Variable struct {
+	API     *string `json:"api,omitempty"`
+	Bastion *string `json:"bastion,omitempty"`
+	Egress  *string `json:"egress,omitempty"`
+}
+
+func (v Variable) get(annotation string) *string {
+	switch annotation {
+	case security.AnnotationAPIAllowListSubnets:
+		return v.API
+	case firewall.AnnotationBastionAllowListSubnets:
+		return v.Bastion
+	case firewall.AnnotationEgressAllowListSubnets:
+		return v.Egress
+	}
+
+	return nil
+}
+
+// Resolver resolves the allowlists of clusters.
+type Resolver struct {
+	reader client.Reader
+}
+
+// NewResolver creates a resolver reading referenced ConfigMaps, Secrets and
+// IPFeeds with reader. The controller only watches the metadata of
+// ConfigMaps and Secrets, so that their data isn't cached for the whole
+// cluster, and reader should read from the API server instead of the cache,
+// like the API reader of the manager.
+func NewResolver(reader client.Reader) *Resolver {
+	return &Resolver{
+		reader: reader,
+	}
+}
+
+// Resolve returns a copy of gcpCluster with the allowlist annotations taken
+// from the first of these that sets them:
+//
+//  1. the annotations on the GCPCluster
+//  2. the annotations on the owning CAPI Cluster
+//  3. the VariableAllowLists topology variable of the CAPI Cluster
+//
+// An object sets an allowlist with the allowlist annotation, like
+// api.gcp.giantswarm.io/allowlist, with the annotation referencing
+// ConfigMap and Secret keys in the namespace of the cluster, like
+// api.gcp.giantswarm.io/allowlist-from: configmap/<name>#<key>, or both.
+// The entries of both annotations are combined.
+//
+// Each allowlist is taken from one object as a whole and never merged with
+// the others, so an empty annotation on the GCPCluster clears the allowlist
+// of the CAPI Cluster. Use `!` entries to remove ranges from an allowlist.
+//
+// The allowlists are resolved independently. The ones that fail, for
+// example because a referenced Secret is missing, are left out of the copy
+// and their errors are returned by annotation, so that only the
+// sub-reconcilers reading them have to be skipped.
+func (r *Resolver) Resolve(ctx context.Context, gcpCluster *capg.GCPCluster, cluster *capi.Cluster) (*capg.GCPCluster, map[string]error) {
+	resolved := gcpCluster.DeepCopy()
+	errs := map[string]error{}
+
+	for _, annotation := range Annotations {
+		delete(resolved.Annotations, annotation)
+
+		value, ok, err := r.resolveAnnotation(ctx, gcpCluster, cluster, annotation)
+		if err != nil {
+			errs[annotation] = errors.Wrapf(err, "failed to resolve allowlist %q", annotation)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		if resolved.Annotations == nil {
+			resolved.Annotations = map[string]string{}
+		}
+		resolved.Annotations[annotation] = value
+	}
+
+	return resolved, errs
+}
+
+// resolveAnnotation returns the value of the allowlist annotation. It
+// returns false when none of the objects sets it.
+func (r *Resolver) resolveAnnotation(ctx context.Context, gcpCluster *capg.GCPCluster, cluster *capi.Cluster, annotation string) (string, bool, error) {
+	value, ok, err := r.getAnnotationValue(ctx, gcpCluster.Namespace, gcpCluster.Annotations, annotation)
+	if err != nil || ok || cluster == nil {
+		return value, ok, errors.WithStack(err)
+	}
+
+	value, ok, err = r.getAnnotationValue(ctx, gcpCluster.Namespace, cluster.Annotations, annotation)
+	if err != nil || ok {
+		return value, ok, errors.WithStack(err)
+	}
+
+	variable, err := getVariable(cluster)
+	if err != nil {
+		return "", false, errors.WithStack(err)
+	}
+
+	variableValue := variable.get(annotation)
+	if variableValue == nil {
+		return "", false, nil
+	}
+
+	return *variableValue, true, nil
+}
+
+// getAnnotationValue combines the entries of the allowlist annotation and of
+// the keys its "-from" annotation references. Referenced entries are marked
+// with their reference, see cidr.FormatSource. It returns false when neither
+// annotation is set.
+func (r *Resolver) getAnnotationValue(ctx context.Context, namespace string, annotations map[string]string, annotation string) (string, bool, error) {
+	value, ok := annotations[annotation]
+	referencesValue, hasReferences := annotations[GetFromAnnotation(annotation)]
+	if !hasReferences {
+		return value, ok, nil
+	}
+
+	references, err := ParseReferences(referencesValue)
+	if err != nil {
+		return "", false, fmt.Errorf("annotation %q is invalid: %w", GetFromAnnotation(annotation), err)
+	}
+
+	entries := []string{value}
+	for _, reference := range references {
+		referencedValue, err := readReference(ctx, r.reader, namespace, reference)
+		if err != nil {
+			return "", false, errors.WithStack(err)
+		}
+
+		// Secrets are redacted, so that their entries don't end up in
+		// errors, events and the status of the cluster.
+		redacted := reference.Kind == ReferenceKindSecret
+		entries = append(entries, cidr.FormatSource(reference.String(), redacted, referencedValue))
+	}
+
+	return strings.Join(entries, "\n"), true, nil
+}
+
+func getVariable(cluster *capi.Cluster) (Variable, error) {
+	variable := Variable{}
+	if cluster.Spec.Topology == nil {
+		return variable, nil
+	}
+
+	for _, clusterVariable := range cluster.Spec.Topology.Variables {
+		if clusterVariable.Name != VariableAllowLists {
+			continue
+		}
+
+		err := json.Unmarshal(clusterVariable.Value.Raw, &variable)
+		if err != nil {
+			return Variable{}, fmt.Errorf("topology variable %q is invalid: %w", VariableAllowLists, err)
+		}
+	}
+
+	return variable, nil
+}
@@ -0,0 +1,240 @@
+package allowlist_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/allowlist"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+var _ = Describe("Resolver", func() {
+	const namespace = "the-namespace"
+
+	var (
+		ctx context.Context
+
+		resolver   *allowlist.Resolver
+		gcpCluster *capg.GCPCluster
+		cluster    *capi.Cluster
+	)
+
+	apiAnnotation := security.AnnotationAPIAllowListSubnets
+	apiFromAnnotation := allowlist.GetFromAnnotation(apiAnnotation)
+
+	withVariable := func(value string) {
+		cluster.Spec.Topology = &capi.Topology{
+			Variables: []capi.ClusterVariable{
+				{
+					Name:  allowlist.VariableAllowLists,
+					Value: apiextensionsv1.JSON{Raw: []byte(value)},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		objects := []client.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-configmap", Namespace: namespace},
+				Data:       map[string]string{"api": "10.3.0.0/24"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-secret", Namespace: namespace},
+				Data:       map[string][]byte{"api": []byte("10.4.0.0/24\n10.4.1.0/24@2022-10-01T00:00:00Z")},
+			},
+			&v1alpha1.IPFeed{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-feed", Namespace: namespace},
+				Status:     v1alpha1.IPFeedStatus{Ranges: []string{"10.5.0.0/24", "10.5.1.0/24"}},
+			},
+			&v1alpha1.IPFeed{
+				ObjectMeta: metav1.ObjectMeta{Name: "unfetched-feed", Namespace: namespace},
+			},
+		}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		resolver = allowlist.NewResolver(k8sClient)
+
+		gcpCluster = &capg.GCPCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-cluster", Namespace: namespace},
+		}
+		cluster = &capi.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-cluster", Namespace: namespace},
+		}
+	})
+
+	DescribeTable("takes each allowlist from the first object setting it",
+		func(setUp func(), expected map[string]string) {
+			setUp()
+
+			resolved, errs := resolver.Resolve(ctx, gcpCluster, cluster)
+			Expect(errs).To(BeEmpty())
+
+			for _, annotation := range allowlist.Annotations {
+				value, ok := expected[annotation]
+				if !ok {
+					Expect(resolved.Annotations).NotTo(HaveKey(annotation))
+					continue
+				}
+
+				Expect(resolved.Annotations).To(HaveKeyWithValue(annotation, value))
+			}
+		},
+		Entry("no allowlists", func() {}, map[string]string{}),
+		Entry("GCPCluster annotation", func() {
+			gcpCluster.Annotations = map[string]string{apiAnnotation: "10.0.0.0/24"}
+			cluster.Annotations = map[string]string{apiAnnotation: "10.1.0.0/24"}
+			withVariable(`{"api": "10.2.0.0/24"}`)
+		}, map[string]string{apiAnnotation: "10.0.0.0/24"}),
+		Entry("empty GCPCluster annotation", func() {
+			gcpCluster.Annotations = map[string]string{apiAnnotation: ""}
+			cluster.Annotations = map[string]string{apiAnnotation: "10.1.0.0/24"}
+		}, map[string]string{apiAnnotation: ""}),
+		Entry("CAPI Cluster annotation", func() {
+			cluster.Annotations = map[string]string{apiAnnotation: "10.1.0.0/24"}
+			withVariable(`{"api": "10.2.0.0/24"}`)
+		}, map[string]string{apiAnnotation: "10.1.0.0/24"}),
+		Entry("topology variable", func() {
+			withVariable(`{"api": "10.2.0.0/24", "bastion": "10.2.1.0/24", "egress": "10.2.2.0/24"}`)
+		}, map[string]string{
+			apiAnnotation: "10.2.0.0/24",
+			firewall.AnnotationBastionAllowListSubnets: "10.2.1.0/24",
+			firewall.AnnotationEgressAllowListSubnets:  "10.2.2.0/24",
+		}),
+		Entry("each allowlist from another object", func() {
+			gcpCluster.Annotations = map[string]string{apiAnnotation: "10.0.0.0/24"}
+			cluster.Annotations = map[string]string{firewall.AnnotationBastionAllowListSubnets: "10.1.1.0/24"}
+			withVariable(`{"api": "10.2.0.0/24", "bastion": "10.2.1.0/24", "egress": "10.2.2.0/24"}`)
+		}, map[string]string{
+			apiAnnotation: "10.0.0.0/24",
+			firewall.AnnotationBastionAllowListSubnets: "10.1.1.0/24",
+			firewall.AnnotationEgressAllowListSubnets:  "10.2.2.0/24",
+		}),
+		Entry("GCPCluster from annotation", func() {
+			gcpCluster.Annotations = map[string]string{apiFromAnnotation: "configmap/the-configmap#api"}
+			cluster.Annotations = map[string]string{apiAnnotation: "10.1.0.0/24"}
+		}, map[string]string{
+			apiAnnotation: "\n" + cidr.FormatSource("configmap/the-configmap#api", false, "10.3.0.0/24"),
+		}),
+		Entry("CAPI Cluster from annotation", func() {
+			cluster.Annotations = map[string]string{apiFromAnnotation: "ipfeed/the-feed"}
+			withVariable(`{"api": "10.2.0.0/24"}`)
+		}, map[string]string{
+			apiAnnotation: "\n" + cidr.FormatSource("ipfeed/the-feed", false, "10.5.0.0/24\n10.5.1.0/24"),
+		}),
+		Entry("annotation combined with from annotation", func() {
+			gcpCluster.Annotations = map[string]string{
+				apiAnnotation:     "10.0.0.0/24",
+				apiFromAnnotation: "configmap/the-configmap#api,ipfeed/the-feed",
+			}
+		}, map[string]string{
+			apiAnnotation: "10.0.0.0/24\n" +
+				cidr.FormatSource("configmap/the-configmap#api", false, "10.3.0.0/24") + "\n" +
+				cidr.FormatSource("ipfeed/the-feed", false, "10.5.0.0/24\n10.5.1.0/24"),
+		}),
+	)
+
+	It("does not change the cluster", func() {
+		gcpCluster.Annotations = map[string]string{"other": "annotation"}
+		cluster.Annotations = map[string]string{apiAnnotation: "10.1.0.0/24"}
+
+		resolved, errs := resolver.Resolve(ctx, gcpCluster, cluster)
+		Expect(errs).To(BeEmpty())
+		Expect(resolved.Annotations).To(Equal(map[string]string{"other": "annotation", apiAnnotation: "10.1.0.0/24"}))
+		Expect(gcpCluster.Annotations).To(Equal(map[string]string{"other": "annotation"}))
+	})
+
+	It("only uses the GCPCluster without CAPI Cluster", func() {
+		gcpCluster.Annotations = map[string]string{firewall.AnnotationBastionAllowListSubnets: "10.0.1.0/24"}
+
+		resolved, errs := resolver.Resolve(ctx, gcpCluster, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(resolved.Annotations).To(Equal(map[string]string{firewall.AnnotationBastionAllowListSubnets: "10.0.1.0/24"}))
+	})
+
+	DescribeTable("leaves out allowlists that fail",
+		func(setUp func(), expectedMessage string) {
+			gcpCluster.Annotations = map[string]string{firewall.AnnotationBastionAllowListSubnets: "10.0.1.0/24"}
+			setUp()
+
+			resolved, errs := resolver.Resolve(ctx, gcpCluster, cluster)
+			Expect(errs).To(HaveKey(apiAnnotation))
+			Expect(errs[apiAnnotation]).To(MatchError(ContainSubstring(expectedMessage)))
+			Expect(errs).NotTo(HaveKey(firewall.AnnotationBastionAllowListSubnets))
+			Expect(resolved.Annotations).NotTo(HaveKey(apiAnnotation))
+			Expect(resolved.Annotations).To(HaveKeyWithValue(firewall.AnnotationBastionAllowListSubnets, "10.0.1.0/24"))
+		},
+		Entry("invalid from annotation", func() {
+			gcpCluster.Annotations[apiFromAnnotation] = "configmap/the-configmap"
+		}, "has no key"),
+		Entry("missing ConfigMap", func() {
+			gcpCluster.Annotations[apiFromAnnotation] = "configmap/other-configmap#api"
+		}, "failed to get allowlist configmap/other-configmap#api"),
+		Entry("missing key", func() {
+			gcpCluster.Annotations[apiFromAnnotation] = "secret/the-secret#bastion"
+		}, "allowlist secret/the-secret#bastion does not exist"),
+		Entry("IPFeed that was never fetched", func() {
+			cluster.Annotations = map[string]string{apiFromAnnotation: "ipfeed/unfetched-feed"}
+		}, "allowlist ipfeed/unfetched-feed has not been fetched yet"),
+		Entry("invalid topology variable", func() {
+			withVariable(`{"api": ["10.2.0.0/24"]}`)
+		}, `topology variable "firewallAllowLists" is invalid`),
+	)
+
+	Describe("Secret references", func() {
+		BeforeEach(func() {
+			gcpCluster.Annotations = map[string]string{
+				apiAnnotation:     "10.0.0.0/24",
+				apiFromAnnotation: "secret/the-secret#api",
+			}
+		})
+
+		It("redacts the entries of the Secret", func() {
+			resolved, errs := resolver.Resolve(ctx, gcpCluster, cluster)
+			Expect(errs).To(BeEmpty())
+
+			allowList, err := cidr.ParseAllowList(resolved.Annotations[apiAnnotation], time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allowList.Ranges).To(Equal([]string{"10.0.0.0/24", "10.4.0.0/24"}))
+			Expect(allowList.Expired).To(Equal([]string{"redacted entry on line 2 of secret/the-secret#api"}))
+			Expect(allowList.Describe("10.0.0.0/24")).To(Equal(`"10.0.0.0/24"`))
+			Expect(allowList.Describe("10.4.0.0/24")).To(Equal("a redacted range of secret/the-secret#api"))
+		})
+
+		It("leaves the entries of the Secret out of parse errors", func() {
+			scheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-secret", Namespace: namespace},
+				Data:       map[string][]byte{"api": []byte("10.4.0.0/24\n10.4.1.0/33")},
+			}
+			resolver = allowlist.NewResolver(fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build())
+
+			resolved, errs := resolver.Resolve(ctx, gcpCluster, cluster)
+			Expect(errs).To(BeEmpty())
+
+			_, err := cidr.ParseAllowList(resolved.Annotations[apiAnnotation], time.Now())
+			Expect(err).To(MatchError("allowlist entry on line 2 of secret/the-secret#api is invalid: not a valid CIDR"))
+			Expect(err.Error()).NotTo(ContainSubstring("10.4.1.0/33"))
+		})
+	})
+})
@@ -0,0 +1,364 @@
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	commentPrefix   = "#"
+	exclusionPrefix = "!"
+	expirySeparator = "@"
+
+	// sourceDirective and redactedSourceDirective start the entries of a
+	// source, see FormatSource.
+	sourceDirective         = "#@source "
+	redactedSourceDirective = "#@redacted-source "
+	directivePrefix         = "#@"
+)
+
+var (
+	privatePrefixes = []netip.Prefix{
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, 0, 0}), 8),
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{172, 16, 0, 0}), 12),
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{192, 168, 0, 0}), 16),
+		netip.PrefixFrom(netip.AddrFrom16([16]byte{0xfc}), 7),
+	}
+	loopbackPrefixes = []netip.Prefix{
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{127, 0, 0, 0}), 8),
+		netip.PrefixFrom(netip.IPv6Loopback(), 128),
+	}
+)
+
+// ParseError points at the allowlist entry that could not be parsed. Line
+// starts at 1 and counts from the start of Source, if the entry came from
+// one. The entries of redacted sources are left out.
+type ParseError struct {
+	Line     int
+	Entry    string
+	Reason   string
+	Source   string
+	Redacted bool
+}
+
+func (e *ParseError) Error() string {
+	location := fmt.Sprintf("line %d", e.Line)
+	if e.Source != "" {
+		location = fmt.Sprintf("%s of %s", location, e.Source)
+	}
+
+	if e.Redacted {
+		return fmt.Sprintf("allowlist entry on %s is invalid: %s", location, e.Reason)
+	}
+
+	return fmt.Sprintf("allowlist entry %q on %s is invalid: %s", e.Entry, location, e.Reason)
+}
+
+// AllowList is a parsed allowlist.
+type AllowList struct {
+	// Ranges are the allowed CIDRs that have not expired.
+	Ranges []string
+	// Expired are the entries that have expired and are left out of Ranges.
+	// Entries of redacted sources are replaced by their location.
+	Expired []string
+	// NextExpiry is the earliest expiry of the entries in Ranges. It is zero
+	// if none of them expire.
+	NextExpiry time.Time
+
+	// redacted maps the ranges that came from redacted sources to their
+	// source.
+	redacted map[string]string
+}
+
+// Describe returns the quoted range of the allowlist for messages, or the
+// source it came from if that source is redacted.
+func (a AllowList) Describe(value string) string {
+	source, ok := a.redacted[value]
+	if ok {
+		return fmt.Sprintf("a redacted range of %s", source)
+	}
+
+	return fmt.Sprintf("%q", value)
+}
+
+// FormatSource marks value as the entries of source, so that it can be
+// appended to an allowlist on a new line. Errors and expired entries of
+// value name the source and count lines from its start. The entries of
+// redacted sources, like Secrets, never appear in errors, expired entries
+// or Describe.
+func FormatSource(source string, redacted bool, value string) string {
+	directive := sourceDirective
+	if redacted {
+		directive = redactedSourceDirective
+	}
+
+	// Directives within value are turned into comments, so that a source
+	// can't claim to be another one.
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), directivePrefix) {
+			lines[i] = commentPrefix + " " + line
+		}
+	}
+
+	return directive + source + "\n" + strings.Join(lines, "\n")
+}
+
+// ParseFromCommaSeparated parses an allowlist and returns its CIDRs. See
+// ParseAllowList for the format. It is meant for the allowlists of the
+// operator, which are only parsed when they are loaded, so entries with an
+// expiry are rejected instead of being left in place after they expire.
+func ParseFromCommaSeparated(value string) ([]string, error) {
+	allowList, err := parseAllowList(value, time.Time{}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return allowList.Ranges, nil
+}
+
+// ParseAllowList parses an allowlist of comma or newline separated CIDRs.
+// Whitespace around entries, empty entries and comments starting with # are
+// ignored. Entries prefixed with ! are subtracted from the other entries of
+// the allowlist, splitting them when needed. Entries suffixed with
+// @<RFC3339 timestamp>, like 203.0.113.7/32@2022-11-01T18:00:00Z, are only
+// allowed until that time. Invalid entries result in a *ParseError. Values
+// of other sources can be appended with FormatSource.
+func ParseAllowList(value string, now time.Time) (AllowList, error) {
+	return parseAllowList(value, now, true)
+}
+
+func parseAllowList(value string, now time.Time, allowExpiry bool) (AllowList, error) {
+	allowList := AllowList{}
+	included := []includedEntry{}
+	excluded := []netip.Prefix{}
+
+	source := ""
+	redacted := false
+	sourceStart := 0
+	for i, line := range strings.Split(value, "\n") {
+		if strings.HasPrefix(line, sourceDirective) || strings.HasPrefix(line, redactedSourceDirective) {
+			redacted = strings.HasPrefix(line, redactedSourceDirective)
+			source = strings.TrimPrefix(strings.TrimPrefix(line, sourceDirective), redactedSourceDirective)
+			sourceStart = i + 1
+			continue
+		}
+
+		lineNumber := i + 1 - sourceStart
+		newParseError := func(entry, reason string) error {
+			parseErr := &ParseError{Line: lineNumber, Entry: entry, Reason: reason, Source: source, Redacted: redacted}
+			if redacted {
+				parseErr.Entry = ""
+			}
+			return parseErr
+		}
+
+		line, _, _ = strings.Cut(line, commentPrefix)
+
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+
+			cidr := strings.TrimPrefix(entry, exclusionPrefix)
+			isExclusion := cidr != entry
+
+			cidr, expiryValue, hasExpiry := strings.Cut(cidr, expirySeparator)
+			prefix, err := parseEntry(cidr)
+			if err != nil {
+				return AllowList{}, newParseError(entry, err.Error())
+			}
+
+			if isExclusion {
+				if hasExpiry {
+					return AllowList{}, newParseError(entry, "exclusions can not expire")
+				}
+
+				excluded = append(excluded, prefix.Masked())
+				continue
+			}
+
+			if hasExpiry && !allowExpiry {
+				return AllowList{}, newParseError(entry, "entries of this allowlist can not expire")
+			}
+
+			if hasExpiry {
+				expiry, err := time.Parse(time.RFC3339, expiryValue)
+				if err != nil {
+					return AllowList{}, newParseError(entry, "expiry is not an RFC3339 timestamp")
+				}
+
+				if !expiry.After(now) {
+					if redacted {
+						entry = fmt.Sprintf("redacted entry on line %d of %s", lineNumber, source)
+					}
+					allowList.Expired = append(allowList.Expired, entry)
+					continue
+				}
+
+				if allowList.NextExpiry.IsZero() || expiry.Before(allowList.NextExpiry) {
+					allowList.NextExpiry = expiry
+				}
+			}
+
+			included = append(included, includedEntry{cidr: cidr, prefix: prefix, source: source, redacted: redacted})
+		}
+	}
+
+	if len(included) == 0 {
+		return allowList, nil
+	}
+
+	allowList.Ranges, allowList.redacted = exclude(included, excluded)
+
+	return allowList, nil
+}
+
+// IsPrivate returns true if the CIDR or address is entirely within an
+// RFC1918 or IPv6 unique local range.
+func IsPrivate(value string) bool {
+	return isWithin(value, privatePrefixes)
+}
+
+// IsLoopback returns true if the CIDR or address is entirely within a
+// loopback range.
+func IsLoopback(value string) bool {
+	return isWithin(value, loopbackPrefixes)
+}
+
+// parseEntry parses a CIDR of an allowlist. IPv4-mapped IPv6 ranges are
+// rejected, since they would end up in IPv6 rules while matching IPv4
+// traffic.
+func parseEntry(cidr string) (netip.Prefix, error) {
+	if strings.ContainsAny(cidr, " \t") {
+		return netip.Prefix{}, fmt.Errorf("entries must be separated by commas or newlines")
+	}
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("not a valid CIDR")
+	}
+
+	if prefix.Addr().Is4In6() {
+		return netip.Prefix{}, fmt.Errorf("IPv4-mapped IPv6 ranges are not supported, use the IPv4 range")
+	}
+
+	return prefix, nil
+}
+
+func isWithin(value string, blocks []netip.Prefix) bool {
+	prefix, err := parsePrefix(value)
+	if err != nil {
+		return false
+	}
+
+	for _, block := range blocks {
+		if block.Bits() <= prefix.Bits() && block.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type includedEntry struct {
+	cidr     string
+	prefix   netip.Prefix
+	source   string
+	redacted bool
+}
+
+// exclude subtracts the excluded prefixes from the included CIDRs. Included
+// CIDRs that don't overlap any exclusion are returned unchanged. It also
+// returns the sources of the resulting ranges that came from redacted
+// entries.
+func exclude(included []includedEntry, excluded []netip.Prefix) ([]string, map[string]string) {
+	result := []string{}
+	redacted := map[string]string{}
+	for _, entry := range included {
+		prefix := entry.prefix.Masked()
+
+		remaining := []netip.Prefix{prefix}
+		for _, excludedPrefix := range excluded {
+			subtracted := []netip.Prefix{}
+			for _, remainingPrefix := range remaining {
+				subtracted = append(subtracted, subtractPrefix(remainingPrefix, excludedPrefix)...)
+			}
+			remaining = subtracted
+		}
+
+		ranges := []string{entry.cidr}
+		if len(remaining) != 1 || remaining[0] != prefix {
+			ranges = []string{}
+			for _, remainingPrefix := range remaining {
+				ranges = append(ranges, remainingPrefix.String())
+			}
+		}
+
+		for _, value := range ranges {
+			result = append(result, value)
+			if entry.redacted {
+				redacted[value] = entry.source
+			}
+		}
+	}
+
+	return result, redacted
+}
+
+// subtractPrefix halves prefix until the halves either don't overlap the
+// excluded prefix or are contained in it.
+func subtractPrefix(prefix, excluded netip.Prefix) []netip.Prefix {
+	if !prefix.Overlaps(excluded) {
+		return []netip.Prefix{prefix}
+	}
+
+	if excluded.Bits() <= prefix.Bits() {
+		return nil
+	}
+
+	bits := prefix.Bits() + 1
+	lower := netip.PrefixFrom(prefix.Addr(), bits)
+	upper := netip.PrefixFrom(setBit(prefix.Addr(), prefix.Bits()), bits)
+
+	result := subtractPrefix(lower, excluded)
+	return append(result, subtractPrefix(upper, excluded)...)
+}
+
+func setBit(addr netip.Addr, bit int) netip.Addr {
+	if addr.Is4() {
+		bytes := addr.As4()
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+		return netip.AddrFrom4(bytes)
+	}
+
+	bytes := addr.As16()
+	bytes[bit/8] |= 0x80 >> (bit % 8)
+	return netip.AddrFrom16(bytes)
+}
+
+// UntilExpiry returns the duration until shortly after the earliest of the
+// non-zero expiries, or zero if all of them are zero.
+func UntilExpiry(expiries ...time.Time) time.Duration {
+	var earliest time.Time
+	for _, expiry := range expiries {
+		if expiry.IsZero() {
+			continue
+		}
+
+		if earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+		}
+	}
+
+	if earliest.IsZero() {
+		return 0
+	}
+
+	// The margin makes sure that the entry is considered expired when the
+	// duration has passed.
+	return time.Until(earliest) + time.Second
+}
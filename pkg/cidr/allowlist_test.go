@@ -0,0 +1,159 @@
+package cidr_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+)
+
+var _ = Describe("ParseAllowList", func() {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	DescribeTable("valid allowlists",
+		func(value string, expectedRanges []string) {
+			allowList, err := cidr.ParseAllowList(value, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allowList.Ranges).To(Equal(expectedRanges))
+		},
+		Entry("comma separated", "10.0.0.0/8, 192.168.0.0/16", []string{"10.0.0.0/8", "192.168.0.0/16"}),
+		Entry("newline separated with comments", "# office\n10.0.0.0/8\n\n2001:db8::/32 # vpn\n", []string{"10.0.0.0/8", "2001:db8::/32"}),
+		Entry("exclusion splitting a range", "10.0.0.0/30,!10.0.0.1/32", []string{"10.0.0.0/32", "10.0.0.2/31"}),
+		Entry("exclusion of a non-canonical range", "10.0.0.0/24,!10.0.0.129/25", []string{"10.0.0.0/25"}),
+		Entry("exclusion not overlapping", "10.0.0.0/24,!10.1.0.0/24", []string{"10.0.0.0/24"}),
+		Entry("only exclusions", "!10.0.0.0/24", nil),
+		Entry("entry that has not expired", "10.0.0.0/24@2022-10-02T00:00:00Z", []string{"10.0.0.0/24"}),
+		Entry("entry that has expired", "10.0.0.0/24@2022-10-01T00:00:00Z,10.1.0.0/24", []string{"10.1.0.0/24"}),
+	)
+
+	DescribeTable("invalid allowlists",
+		func(value string, expectedLine int, expectedEntry string) {
+			var allowList cidr.AllowList
+			var err error
+			Expect(func() {
+				allowList, err = cidr.ParseAllowList(value, now)
+			}).NotTo(Panic())
+
+			var parseErr *cidr.ParseError
+			Expect(err).To(BeAssignableToTypeOf(parseErr))
+			parseErr = err.(*cidr.ParseError)
+			Expect(parseErr.Line).To(Equal(expectedLine))
+			Expect(parseErr.Entry).To(Equal(expectedEntry))
+			Expect(allowList.Ranges).To(BeEmpty())
+		},
+		Entry("leading zero prefix length", "10.0.0.0/08", 1, "10.0.0.0/08"),
+		Entry("exclusion with leading zero prefix length", "!10.0.0.0/08", 1, "!10.0.0.0/08"),
+		Entry("leading zero prefix length with exclusion", "10.0.0.0/08,!1.2.3.4/32", 1, "10.0.0.0/08"),
+		Entry("exclusion with leading zero prefix length on a later line", "10.0.0.0/8\n!10.0.0.0/016", 2, "!10.0.0.0/016"),
+		Entry("IPv4-mapped IPv6 range", "::ffff:10.0.0.0/104", 1, "::ffff:10.0.0.0/104"),
+		Entry("IPv4-mapped IPv6 exclusion", "10.0.0.0/8,!::ffff:10.0.0.1/128", 1, "!::ffff:10.0.0.1/128"),
+		Entry("address without prefix length", "10.0.0.1", 1, "10.0.0.1"),
+		Entry("space separated", "10.0.0.0/8 10.1.0.0/16", 1, "10.0.0.0/8 10.1.0.0/16"),
+		Entry("expiring exclusion", "10.0.0.0/8,!10.0.0.0/16@2022-10-02T00:00:00Z", 1, "!10.0.0.0/16@2022-10-02T00:00:00Z"),
+		Entry("invalid expiry", "10.0.0.0/8@tomorrow", 1, "10.0.0.0/8@tomorrow"),
+	)
+
+	It("returns the expired entries and the next expiry", func() {
+		allowList, err := cidr.ParseAllowList("10.0.0.0/24@2022-10-01T00:00:00Z\n10.1.0.0/24@2022-10-03T00:00:00Z,10.2.0.0/24@2022-10-02T00:00:00Z", now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowList.Expired).To(Equal([]string{"10.0.0.0/24@2022-10-01T00:00:00Z"}))
+		Expect(allowList.NextExpiry).To(Equal(time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)))
+	})
+})
+
+var _ = Describe("ParseFromCommaSeparated", func() {
+	It("returns the ranges", func() {
+		ranges, err := cidr.ParseFromCommaSeparated("10.0.0.0/24,10.1.0.0/24\n!10.1.0.0/25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ranges).To(Equal([]string{"10.0.0.0/24", "10.1.0.128/25"}))
+	})
+
+	DescribeTable("rejects entries with an expiry",
+		func(value string, expectedEntry string) {
+			_, err := cidr.ParseFromCommaSeparated(value)
+
+			var parseErr *cidr.ParseError
+			Expect(errors.As(err, &parseErr)).To(BeTrue())
+			Expect(parseErr.Entry).To(Equal(expectedEntry))
+			Expect(parseErr.Reason).To(Equal("entries of this allowlist can not expire"))
+		},
+		Entry("entry that has not expired", "10.0.0.0/24,10.1.0.0/24@2999-01-01T00:00:00Z", "10.1.0.0/24@2999-01-01T00:00:00Z"),
+		Entry("entry that has expired", "10.0.0.0/24@2020-01-01T00:00:00Z", "10.0.0.0/24@2020-01-01T00:00:00Z"),
+	)
+})
+
+var _ = Describe("FormatSource", func() {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	It("combines the entries of the sources", func() {
+		value := "10.0.0.0/24\n" + cidr.FormatSource("configmap/the-allowlist#api", false, "10.1.0.0/24\n!10.1.0.0/25")
+		allowList, err := cidr.ParseAllowList(value, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowList.Ranges).To(Equal([]string{"10.0.0.0/24", "10.1.0.128/25"}))
+		Expect(allowList.Describe("10.1.0.128/25")).To(Equal(`"10.1.0.128/25"`))
+	})
+
+	It("counts lines from the start of the source", func() {
+		value := "10.0.0.0/24\n\n" + cidr.FormatSource("configmap/the-allowlist#api", false, "10.1.0.0/24\n10.2.0.0/08")
+		_, err := cidr.ParseAllowList(value, now)
+		Expect(err).To(MatchError(`allowlist entry "10.2.0.0/08" on line 2 of configmap/the-allowlist#api is invalid: not a valid CIDR`))
+	})
+
+	When("the source is redacted", func() {
+		It("leaves invalid entries out of the error", func() {
+			value := "10.0.0.0/24\n" + cidr.FormatSource("secret/the-allowlist#api", true, "10.1.0.0/24\n10.2.0.0/08")
+			_, err := cidr.ParseAllowList(value, now)
+			Expect(err).To(MatchError("allowlist entry on line 2 of secret/the-allowlist#api is invalid: not a valid CIDR"))
+
+			var parseErr *cidr.ParseError
+			Expect(errors.As(err, &parseErr)).To(BeTrue())
+			Expect(parseErr.Entry).To(BeEmpty())
+		})
+
+		It("leaves expired entries out", func() {
+			value := cidr.FormatSource("secret/the-allowlist#api", true, "10.1.0.0/24@2022-10-01T00:00:00Z")
+			allowList, err := cidr.ParseAllowList(value, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allowList.Expired).To(Equal([]string{"redacted entry on line 1 of secret/the-allowlist#api"}))
+		})
+
+		It("describes its ranges by the source", func() {
+			value := "10.0.0.0/24\n" + cidr.FormatSource("secret/the-allowlist#api", true, "10.1.0.0/24\n!10.1.0.0/25")
+			allowList, err := cidr.ParseAllowList(value, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allowList.Ranges).To(Equal([]string{"10.0.0.0/24", "10.1.0.128/25"}))
+			Expect(allowList.Describe("10.0.0.0/24")).To(Equal(`"10.0.0.0/24"`))
+			Expect(allowList.Describe("10.1.0.128/25")).To(Equal("a redacted range of secret/the-allowlist#api"))
+		})
+
+		It("can not be turned into a source that is not redacted", func() {
+			value := cidr.FormatSource("secret/the-allowlist#api", true, "#@source configmap/other#api\n10.2.0.0/08")
+			_, err := cidr.ParseAllowList(value, now)
+			Expect(err).To(MatchError("allowlist entry on line 2 of secret/the-allowlist#api is invalid: not a valid CIDR"))
+		})
+	})
+})
+
+var _ = DescribeTable("IsPrivate",
+	func(value string, expected bool) {
+		Expect(cidr.IsPrivate(value)).To(Equal(expected))
+	},
+	Entry("RFC1918 range", "10.1.0.0/16", true),
+	Entry("RFC1918 address", "192.168.1.1", true),
+	Entry("range containing a private range", "10.0.0.0/7", false),
+	Entry("public range", "203.0.113.0/24", false),
+	Entry("unique local range", "fd00::/8", true),
+	Entry("invalid value", "not-a-cidr", false),
+)
+
+var _ = DescribeTable("IsLoopback",
+	func(value string, expected bool) {
+		Expect(cidr.IsLoopback(value)).To(Equal(expected))
+	},
+	Entry("IPv4 loopback", "127.0.0.1/32", true),
+	Entry("IPv6 loopback", "::1/128", true),
+	Entry("public range", "203.0.113.0/24", false),
+)
@@ -1,24 +1,156 @@
 package cidr
 
 import (
-	"errors"
 	"fmt"
-	"net"
-	"strings"
+	"net/netip"
+	"sort"
 )
 
-func ParseFromCommaSeparated(value string) ([]string, error) {
-	if value == "" {
-		return nil, nil
+// IsIPv6 returns true if the value is an IPv6 CIDR or address. Values that
+// can't be parsed are not considered IPv6. IPv4-mapped IPv6 CIDRs like
+// ::ffff:1.2.3.4/128 are IPv6, while IPv4-mapped addresses are IPv4, like
+// in the output of Normalize.
+func IsIPv6(value string) bool {
+	prefix, err := parsePrefix(value)
+	if err != nil {
+		return false
 	}
 
-	ipRanges := strings.Split(value, ",")
-	for _, cidr := range ipRanges {
-		_, _, err := net.ParseCIDR(cidr)
+	return !prefix.Addr().Is4()
+}
+
+// SplitByFamily splits CIDRs and addresses into IPv4 and IPv6 values,
+// keeping their order.
+func SplitByFamily(values []string) (ipv4 []string, ipv6 []string) {
+	for _, value := range values {
+		if IsIPv6(value) {
+			ipv6 = append(ipv6, value)
+			continue
+		}
+
+		ipv4 = append(ipv4, value)
+	}
+
+	return ipv4, ipv6
+}
+
+// Normalize canonicalizes, deduplicates and sorts CIDRs and addresses.
+// Addresses are turned into single host prefixes and host bits are cleared,
+// so 10.0.0.1/24 becomes 10.0.0.0/24. IPv4 prefixes are sorted before IPv6
+// prefixes. When aggregate is set, prefixes contained in other prefixes are
+// dropped and adjacent prefixes are merged into their common parent.
+func Normalize(values []string, aggregate bool) ([]string, error) {
+	prefixes := []netip.Prefix{}
+	for _, value := range values {
+		prefix, err := parsePrefix(value)
 		if err != nil {
-			message := fmt.Sprintf("value: %q contains invalid CIDRs", value)
-			return nil, errors.New(message)
+			return nil, err
 		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	prefixes = sortAndDeduplicate(prefixes)
+	if aggregate {
+		prefixes = aggregatePrefixes(prefixes)
+	}
+
+	normalized := []string{}
+	for _, prefix := range prefixes {
+		normalized = append(normalized, prefix.String())
+	}
+
+	return normalized, nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err == nil {
+		return prefix.Masked(), nil
+	}
+
+	addr, addrErr := netip.ParseAddr(value)
+	if addrErr != nil {
+		return netip.Prefix{}, fmt.Errorf("value: %q is not a valid CIDR or address", value)
 	}
-	return ipRanges, nil
+
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func sortAndDeduplicate(prefixes []netip.Prefix) []netip.Prefix {
+	sort.Slice(prefixes, func(i, j int) bool {
+		return comparePrefixes(prefixes[i], prefixes[j]) < 0
+	})
+
+	deduplicated := []netip.Prefix{}
+	for _, prefix := range prefixes {
+		if len(deduplicated) != 0 && deduplicated[len(deduplicated)-1] == prefix {
+			continue
+		}
+
+		deduplicated = append(deduplicated, prefix)
+	}
+
+	return deduplicated
+}
+
+// aggregatePrefixes expects sorted and deduplicated prefixes. It repeats
+// dropping contained prefixes and merging siblings until nothing changes,
+// since a merge can produce a prefix that contains or neighbours another one.
+func aggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	for {
+		aggregated := []netip.Prefix{}
+		changed := false
+
+		for _, prefix := range prefixes {
+			if len(aggregated) == 0 {
+				aggregated = append(aggregated, prefix)
+				continue
+			}
+
+			last := aggregated[len(aggregated)-1]
+			if last.Bits() <= prefix.Bits() && last.Contains(prefix.Addr()) {
+				changed = true
+				continue
+			}
+
+			parent, ok := mergeSiblings(last, prefix)
+			if ok {
+				aggregated[len(aggregated)-1] = parent
+				changed = true
+				continue
+			}
+
+			aggregated = append(aggregated, prefix)
+		}
+
+		prefixes = sortAndDeduplicate(aggregated)
+		if !changed {
+			return prefixes
+		}
+	}
+}
+
+func mergeSiblings(first, second netip.Prefix) (netip.Prefix, bool) {
+	if first.Bits() != second.Bits() || first.Bits() == 0 || first.Addr().Is4() != second.Addr().Is4() {
+		return netip.Prefix{}, false
+	}
+
+	firstParent := netip.PrefixFrom(first.Addr(), first.Bits()-1).Masked()
+	secondParent := netip.PrefixFrom(second.Addr(), second.Bits()-1).Masked()
+	if firstParent != secondParent {
+		return netip.Prefix{}, false
+	}
+
+	return firstParent, true
+}
+
+func comparePrefixes(first, second netip.Prefix) int {
+	result := first.Addr().Compare(second.Addr())
+	if result != 0 {
+		return result
+	}
+
+	return first.Bits() - second.Bits()
 }
@@ -0,0 +1,87 @@
+package cidr_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+)
+
+var _ = Describe("CIDR", func() {
+	DescribeTable("IsIPv6",
+		func(value string, expected bool) {
+			Expect(cidr.IsIPv6(value)).To(Equal(expected))
+		},
+		Entry("IPv4 CIDR", "10.0.0.0/24", false),
+		Entry("IPv4 address", "10.0.0.1", false),
+		Entry("IPv6 CIDR", "2001:db8::/32", true),
+		Entry("IPv6 address", "2001:db8::1", true),
+		Entry("IPv4-mapped IPv6 CIDR", "::ffff:1.2.3.4/128", true),
+		Entry("IPv4-mapped IPv6 address", "::ffff:1.2.3.4", false),
+		Entry("invalid value", "not-an-ip", false),
+		Entry("empty value", "", false),
+	)
+
+	It("splits values by family", func() {
+		ipv4, ipv6 := cidr.SplitByFamily([]string{"10.0.0.0/24", "2001:db8::/32", "::ffff:1.2.3.4/128", "10.0.0.1"})
+		Expect(ipv4).To(Equal([]string{"10.0.0.0/24", "10.0.0.1"}))
+		Expect(ipv6).To(Equal([]string{"2001:db8::/32", "::ffff:1.2.3.4/128"}))
+	})
+
+	DescribeTable("Normalize",
+		func(values []string, expected []string) {
+			normalized, err := cidr.Normalize(values, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(Equal(expected))
+		},
+		Entry("no values", []string{}, []string{}),
+		Entry("addresses become host prefixes", []string{"10.0.0.1", "2001:db8::1"}, []string{"10.0.0.1/32", "2001:db8::1/128"}),
+		Entry("host bits are cleared", []string{"10.0.0.1/24", "2001:db8::1/32"}, []string{"10.0.0.0/24", "2001:db8::/32"}),
+		Entry("duplicates are dropped", []string{"10.0.0.0/24", "10.0.0.1/24", "10.0.0.0/24"}, []string{"10.0.0.0/24"}),
+		Entry("values are sorted", []string{"10.1.0.0/24", "10.0.0.0/16", "10.0.0.0/24"}, []string{"10.0.0.0/16", "10.0.0.0/24", "10.1.0.0/24"}),
+		Entry("IPv4 is sorted before IPv6", []string{"2001:db8::/32", "10.0.0.0/24"}, []string{"10.0.0.0/24", "2001:db8::/32"}),
+		Entry("IPv4-mapped addresses become IPv4", []string{"::ffff:10.0.0.1"}, []string{"10.0.0.1/32"}),
+		Entry("contained prefixes are kept", []string{"10.0.0.0/16", "10.0.1.0/24"}, []string{"10.0.0.0/16", "10.0.1.0/24"}),
+		Entry("siblings are kept", []string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/25", "10.0.0.128/25"}),
+	)
+
+	DescribeTable("Normalize with aggregation",
+		func(values []string, expected []string) {
+			normalized, err := cidr.Normalize(values, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(Equal(expected))
+		},
+		Entry("no values", []string{}, []string{}),
+		Entry("duplicates are dropped", []string{"10.0.0.1/24", "10.0.0.0/24"}, []string{"10.0.0.0/24"}),
+		Entry("contained prefixes are dropped", []string{"10.0.1.0/24", "10.0.0.0/16", "10.0.2.1"}, []string{"10.0.0.0/16"}),
+		Entry("contained IPv6 prefixes are dropped", []string{"2001:db8:1::/48", "2001:db8::/32"}, []string{"2001:db8::/32"}),
+		Entry("siblings are merged", []string{"10.0.0.128/25", "10.0.0.0/25"}, []string{"10.0.0.0/24"}),
+		Entry("IPv6 siblings are merged", []string{"2001:db8::/33", "2001:db8:8000::/33"}, []string{"2001:db8::/32"}),
+		Entry("siblings are merged over several levels",
+			[]string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/25", "10.0.1.0/24"},
+			[]string{"10.0.0.0/23"}),
+		Entry("merged siblings drop contained prefixes",
+			[]string{"10.0.0.0/25", "10.0.0.128/25", "10.0.0.200/32"},
+			[]string{"10.0.0.0/24"}),
+		Entry("adjacent prefixes with different parents are not merged",
+			[]string{"10.0.0.128/25", "10.0.1.0/25"},
+			[]string{"10.0.0.128/25", "10.0.1.0/25"}),
+		Entry("prefixes of different lengths are not merged",
+			[]string{"10.0.0.0/24", "10.0.1.0/25"},
+			[]string{"10.0.0.0/24", "10.0.1.0/25"}),
+		Entry("families are not merged",
+			[]string{"0.0.0.0/1", "128.0.0.0/1", "::/1", "8000::/1"},
+			[]string{"0.0.0.0/0", "::/0"}),
+		Entry("IPv4 prefixes are not merged into IPv6 prefixes",
+			[]string{"10.0.0.0/8", "::/0"},
+			[]string{"10.0.0.0/8", "::/0"}),
+		Entry("non-canonical values are normalized first",
+			[]string{"10.0.0.1/24", "10.0.1.1/24", "10.0.0.7"},
+			[]string{"10.0.0.0/23"}),
+	)
+
+	It("fails for invalid values", func() {
+		_, err := cidr.Normalize([]string{"10.0.0.0/24", "not-a-cidr"}, true)
+		Expect(err).To(MatchError(ContainSubstring(`"not-a-cidr" is not a valid CIDR or address`)))
+	})
+})
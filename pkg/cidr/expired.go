@@ -0,0 +1,63 @@
+package cidr
+
+import (
+	"strings"
+	"sync"
+)
+
+// ExpiredEntries remembers the expired entries of allowlists, so that each
+// expired entry is only reported once and not on every reconciliation while
+// it stays in the allowlist. It is safe for concurrent use.
+type ExpiredEntries struct {
+	mutex   sync.Mutex
+	entries map[string]map[string]bool
+}
+
+func NewExpiredEntries() *ExpiredEntries {
+	return &ExpiredEntries{
+		entries: map[string]map[string]bool{},
+	}
+}
+
+// Observe records the expired entries of the allowlist identified by key and
+// returns the ones that were not expired when it was last observed. Entries
+// are compared with their expiry, so an entry that is extended and expires
+// again is returned again. Entries that were removed from the allowlist are
+// forgotten.
+func (e *ExpiredEntries) Observe(key string, expired []string) []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	previous := e.entries[key]
+	current := map[string]bool{}
+	newEntries := []string{}
+	for _, entry := range expired {
+		current[entry] = true
+		if !previous[entry] {
+			newEntries = append(newEntries, entry)
+		}
+	}
+
+	if len(current) == 0 {
+		delete(e.entries, key)
+	} else {
+		e.entries[key] = current
+	}
+
+	return newEntries
+}
+
+// Forget forgets the expired entries of the allowlist identified by key and
+// of the allowlists with keys below it, like the-namespace/the-cluster and
+// the-namespace/the-cluster/the-annotation. It is called when a cluster is
+// deleted, so that the entries don't pile up.
+func (e *ExpiredEntries) Forget(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for entriesKey := range e.entries {
+		if entriesKey == key || strings.HasPrefix(entriesKey, key+"/") {
+			delete(e.entries, entriesKey)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package cidr_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+)
+
+var _ = Describe("ExpiredEntries", func() {
+	var expiredEntries *cidr.ExpiredEntries
+
+	BeforeEach(func() {
+		expiredEntries = cidr.NewExpiredEntries()
+	})
+
+	It("returns each expired entry once", func() {
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(Equal([]string{"10.0.0.0/24@2022-10-01T00:00:00Z"}))
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(BeEmpty())
+	})
+
+	It("returns entries that expired later", func() {
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("the-cluster", []string{
+			"10.0.0.0/24@2022-10-01T00:00:00Z",
+			"10.1.0.0/24@2022-10-02T00:00:00Z",
+		})).To(Equal([]string{"10.1.0.0/24@2022-10-02T00:00:00Z"}))
+	})
+
+	It("returns an entry again when it expires with another expiry", func() {
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-02T00:00:00Z"})).To(Equal([]string{"10.0.0.0/24@2022-10-02T00:00:00Z"}))
+	})
+
+	It("returns an entry again when it was removed and added back", func() {
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("the-cluster", nil)).To(BeEmpty())
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+	})
+
+	It("forgets the entries of a key and the keys below it", func() {
+		Expect(expiredEntries.Observe("ns/the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("ns/the-cluster/annotation", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("ns/the-cluster-2", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+
+		expiredEntries.Forget("ns/the-cluster")
+
+		Expect(expiredEntries.Observe("ns/the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("ns/the-cluster/annotation", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("ns/the-cluster-2", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(BeEmpty())
+	})
+
+	It("keeps the entries of each key apart", func() {
+		Expect(expiredEntries.Observe("the-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+		Expect(expiredEntries.Observe("another-cluster", []string{"10.0.0.0/24@2022-10-01T00:00:00Z"})).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+const (
+	APIVersion = "capg-firewall-rule-operator.giantswarm.io/v1alpha1"
+	Kind       = "OperatorConfig"
+)
+
+// Config is the configuration file of the operator. Fields that are not set
+// in the file keep the value of the corresponding flag.
+//
+// Changes to Defaults are applied without a restart. All other fields are
+// only read at startup.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// Defaults are applied to clusters that don't override them with
+	// annotations.
+	Defaults Defaults `json:"defaults"`
+
+	ManagementCluster        ManagementCluster `json:"managementCluster"`
+	SecurityPolicyUpdateMode string            `json:"securityPolicyUpdateMode"`
+	MaxConcurrentReconciles  int               `json:"maxConcurrentReconciles"`
+	GCP                      GCP               `json:"gcp"`
+	Reconcilers              Reconcilers       `json:"reconcilers"`
+}
+
+// Defaults holds the default allowlists and options. Allowlists use the
+// same format as the allowlist annotations.
+type Defaults struct {
+	APIAllowList          string `json:"apiAllowList"`
+	APIVerboseLogging     bool   `json:"apiVerboseLogging"`
+	BastionHostAllowList  string `json:"bastionHostAllowList"`
+	ControlPlaneAllowList string `json:"controlPlaneAllowList"`
+	EgressAllowList       string `json:"egressAllowList"`
+	FirewallLogging       string `json:"firewallLogging"`
+	AggregateRanges       bool   `json:"aggregateRanges"`
+}
+
+// Reconcilers enables the sub-reconcilers. Disabled sub-reconcilers only
+// delete the resources of clusters they managed before.
+type Reconcilers struct {
+	FirewallRules  bool `json:"firewallRules"`
+	SecurityPolicy bool `json:"securityPolicy"`
+}
+
+type ManagementCluster struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type GCP struct {
+	Retry     Retry     `json:"retry"`
+	RateLimit RateLimit `json:"rateLimit"`
+}
+
+type Retry struct {
+	Attempts     int             `json:"attempts"`
+	InitialDelay metav1.Duration `json:"initialDelay"`
+	MaxDelay     metav1.Duration `json:"maxDelay"`
+}
+
+type RateLimit struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+// Load reads the configuration file at path on top of base and validates
+// the result.
+func Load(path string, base Config) (Config, error) {
+	content, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return Config{}, errors.WithStack(err)
+	}
+
+	return Parse(content, base)
+}
+
+// Parse parses the content of a configuration file on top of base and
+// validates the result. Unknown fields are rejected.
+func Parse(content []byte, base Config) (Config, error) {
+	config := base
+	config.APIVersion = ""
+	config.Kind = ""
+	err := yaml.UnmarshalStrict(content, &config)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "failed to parse config")
+	}
+
+	if config.APIVersion != APIVersion || config.Kind != Kind {
+		return Config{}, fmt.Errorf("unsupported config %s %s, expected %s %s", config.APIVersion, config.Kind, APIVersion, Kind)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return Config{}, errors.WithStack(err)
+	}
+
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	if c.ManagementCluster.Name == "" || c.ManagementCluster.Namespace == "" {
+		return errors.New("management cluster name and namespace are required")
+	}
+
+	switch c.SecurityPolicyUpdateMode {
+	case security.UpdateModeInPlace, security.UpdateModeBlueGreen:
+	default:
+		return fmt.Errorf("invalid security policy update mode %q", c.SecurityPolicyUpdateMode)
+	}
+
+	if c.MaxConcurrentReconciles < 1 {
+		return fmt.Errorf("max concurrent reconciles must be at least 1, got %d", c.MaxConcurrentReconciles)
+	}
+
+	if c.GCP.Retry.Attempts < 1 {
+		return fmt.Errorf("GCP retry attempts must be at least 1, got %d", c.GCP.Retry.Attempts)
+	}
+
+	if c.GCP.RateLimit.QPS < 0 || c.GCP.RateLimit.Burst < 0 {
+		return errors.New("GCP rate limit must not be negative")
+	}
+
+	_, err := c.Defaults.Firewall()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = c.Defaults.Security()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (c Config) GetManagementCluster() types.NamespacedName {
+	return types.NamespacedName{
+		Name:      c.ManagementCluster.Name,
+		Namespace: c.ManagementCluster.Namespace,
+	}
+}
+
+func (c Config) GetRetryConfig() google.RetryConfig {
+	return google.RetryConfig{
+		Attempts:     c.GCP.Retry.Attempts,
+		InitialDelay: c.GCP.Retry.InitialDelay.Duration,
+		MaxDelay:     c.GCP.Retry.MaxDelay.Duration,
+	}
+}
+
+// Firewall returns the defaults of the firewall rule reconciler.
+func (d Defaults) Firewall() (firewall.Defaults, error) {
+	bastionHostAllowList, err := cidr.ParseFromCommaSeparated(d.BastionHostAllowList)
+	if err != nil {
+		return firewall.Defaults{}, errors.Wrap(err, "failed to parse default bastion host allow list")
+	}
+
+	controlPlaneAllowList, err := cidr.ParseFromCommaSeparated(d.ControlPlaneAllowList)
+	if err != nil {
+		return firewall.Defaults{}, errors.Wrap(err, "failed to parse control plane allow list")
+	}
+
+	egressAllowList, err := cidr.ParseFromCommaSeparated(d.EgressAllowList)
+	if err != nil {
+		return firewall.Defaults{}, errors.Wrap(err, "failed to parse default egress allow list")
+	}
+
+	logging, err := firewall.ParseLogging(d.FirewallLogging)
+	if err != nil {
+		return firewall.Defaults{}, errors.Wrap(err, "failed to parse default firewall logging")
+	}
+
+	return firewall.Defaults{
+		BastionHostAllowList:  bastionHostAllowList,
+		ControlPlaneAllowList: controlPlaneAllowList,
+		EgressAllowList:       egressAllowList,
+		Logging:               logging,
+		AggregateRanges:       d.AggregateRanges,
+	}, nil
+}
+
+// Security returns the defaults of the security policy reconciler.
+func (d Defaults) Security() (security.Defaults, error) {
+	apiAllowList, err := cidr.ParseFromCommaSeparated(d.APIAllowList)
+	if err != nil {
+		return security.Defaults{}, errors.Wrap(err, "failed to parse default api allow list")
+	}
+
+	return security.Defaults{
+		APIAllowList:    apiAllowList,
+		VerboseLogging:  d.APIVerboseLogging,
+		AggregateRanges: d.AggregateRanges,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package config_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/config"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+// newBaseConfig returns a valid configuration, like the one built from the
+// flags of the operator.
+func newBaseConfig() config.Config {
+	return config.Config{
+		Defaults: config.Defaults{
+			APIAllowList:         "10.0.0.0/24",
+			BastionHostAllowList: "10.1.0.0/24",
+		},
+		ManagementCluster: config.ManagementCluster{
+			Name:      "the-mc-name",
+			Namespace: "the-namespace",
+		},
+		SecurityPolicyUpdateMode: security.UpdateModeInPlace,
+		MaxConcurrentReconciles:  1,
+		GCP: config.GCP{
+			Retry: config.Retry{
+				Attempts:     3,
+				InitialDelay: metav1.Duration{Duration: time.Second},
+				MaxDelay:     metav1.Duration{Duration: time.Minute},
+			},
+			RateLimit: config.RateLimit{
+				QPS:   10,
+				Burst: 20,
+			},
+		},
+	}
+}
+
+var _ = Describe("Parse", func() {
+	var base config.Config
+
+	BeforeEach(func() {
+		base = newBaseConfig()
+	})
+
+	It("keeps the values of base for fields that are not in the file", func() {
+		actual, err := config.Parse([]byte(`
+apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1
+kind: OperatorConfig
+defaults:
+  apiAllowList: 10.2.0.0/24
+maxConcurrentReconciles: 5
+gcp:
+  retry:
+    attempts: 7
+`), base)
+		Expect(err).NotTo(HaveOccurred())
+
+		expected := newBaseConfig()
+		expected.APIVersion = config.APIVersion
+		expected.Kind = config.Kind
+		expected.Defaults.APIAllowList = "10.2.0.0/24"
+		expected.MaxConcurrentReconciles = 5
+		expected.GCP.Retry.Attempts = 7
+		Expect(actual).To(Equal(expected))
+	})
+
+	It("does not change base", func() {
+		_, err := config.Parse([]byte(`
+apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1
+kind: OperatorConfig
+maxConcurrentReconciles: 5
+`), base)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(base).To(Equal(newBaseConfig()))
+	})
+
+	It("requires the file to set apiVersion and kind", func() {
+		base.APIVersion = config.APIVersion
+		base.Kind = config.Kind
+
+		_, err := config.Parse([]byte("maxConcurrentReconciles: 5\n"), base)
+		Expect(err).To(MatchError(ContainSubstring("unsupported config")))
+	})
+
+	DescribeTable("invalid files",
+		func(content, expectedMessage string) {
+			_, err := config.Parse([]byte(content), base)
+			Expect(err).To(MatchError(ContainSubstring(expectedMessage)))
+		},
+		Entry("unknown fields",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\nmaxConcurentReconciles: 5\n",
+			"unknown field"),
+		Entry("unknown nested fields",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\ndefaults:\n  apiAllowedList: 10.2.0.0/24\n",
+			"unknown field"),
+		Entry("an unsupported apiVersion",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1beta1\nkind: OperatorConfig\n",
+			"unsupported config"),
+		Entry("an unsupported kind",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: Config\n",
+			"unsupported config"),
+		Entry("invalid YAML",
+			"apiVersion: [\n",
+			"failed to parse config"),
+		Entry("an invalid default allowlist",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\ndefaults:\n  apiAllowList: not-a-cidr\n",
+			"failed to parse default api allow list"),
+		Entry("an expiring default allowlist entry",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\ndefaults:\n  apiAllowList: 10.0.0.0/24@2999-01-01T00:00:00Z\n",
+			"can not expire"),
+		Entry("an invalid value overriding a valid flag",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\nmaxConcurrentReconciles: 0\n",
+			"max concurrent reconciles must be at least 1"),
+		Entry("an invalid security policy update mode",
+			"apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\nsecurityPolicyUpdateMode: sideways\n",
+			"invalid security policy update mode"),
+	)
+})
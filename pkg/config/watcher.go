@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultWatchInterval is how often the configuration file is read to check
+// for changes. Mounted ConfigMaps are updated by swapping symlinks, so the
+// file is polled instead of relying on file system notifications.
+const DefaultWatchInterval = time.Second * 10
+
+// Watcher reloads the configuration file when its content changes. Invalid
+// configurations are logged and ignored, so the operator keeps running with
+// the last valid configuration. Settings that are not in the file, like the
+// --break-glass-allow-list flag, are never reloaded.
+type Watcher struct {
+	path     string
+	base     Config
+	interval time.Duration
+	onChange func(context.Context, Config) error
+
+	content []byte
+	config  Config
+}
+
+// NewWatcher creates a watcher for the configuration file at path, which was
+// loaded on top of base into config at startup. onChange is called with the
+// new configuration when its defaults changed.
+func NewWatcher(path string, base, config Config, interval time.Duration, onChange func(context.Context, Config) error) (*Watcher, error) {
+	content, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &Watcher{
+		path:     path,
+		base:     base,
+		interval: interval,
+		onChange: onChange,
+		content:  content,
+		config:   config,
+	}, nil
+}
+
+// Start polls the configuration file until the context is cancelled. It
+// implements manager.Runnable and runs on the leader only, since only the
+// leader reconciles clusters. A new leader checks the file right away.
+func (w *Watcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.reload(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) reload(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("config-watcher").WithValues("path", w.path)
+
+	content, err := os.ReadFile(w.path) // #nosec G304
+	if err != nil {
+		logger.Error(err, "Failed to read config")
+		return
+	}
+
+	if bytes.Equal(content, w.content) {
+		return
+	}
+
+	config, err := Parse(content, w.base)
+	if err != nil {
+		logger.Error(err, "Ignoring invalid config")
+		w.content = content
+		return
+	}
+
+	if !isSameStartupConfig(w.config, config) {
+		logger.Info("Config changed fields that are only read at startup. Restart the operator to apply them")
+	}
+
+	if !reflect.DeepEqual(w.config.Defaults, config.Defaults) {
+		logger.Info("Defaults changed, reconciling all clusters")
+		err = w.onChange(ctx, config)
+		if err != nil {
+			// Keep the previous content, so that the change is applied
+			// again on the next poll.
+			logger.Error(err, "Failed to apply config")
+			return
+		}
+	}
+
+	w.content = content
+	w.config = config
+}
+
+func isSameStartupConfig(a, b Config) bool {
+	a.Defaults = Defaults{}
+	b.Defaults = Defaults{}
+	return reflect.DeepEqual(a, b)
+}
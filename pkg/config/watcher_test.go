@@ -0,0 +1,120 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/config"
+)
+
+const configHeader = "apiVersion: capg-firewall-rule-operator.giantswarm.io/v1alpha1\nkind: OperatorConfig\n"
+
+var _ = Describe("Watcher", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		path   string
+
+		mutex       sync.Mutex
+		changes     []config.Config
+		onChangeErr error
+	)
+
+	writeConfig := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	}
+
+	getChanges := func() []config.Config {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]config.Config{}, changes...)
+	}
+
+	getAPIAllowLists := func() []string {
+		allowLists := []string{}
+		for _, change := range getChanges() {
+			allowLists = append(allowLists, change.Defaults.APIAllowList)
+		}
+		return allowLists
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		changes = nil
+		onChangeErr = nil
+
+		content := configHeader + "defaults:\n  apiAllowList: 10.2.0.0/24\n"
+		writeConfig(content)
+		loaded, err := config.Parse([]byte(content), newBaseConfig())
+		Expect(err).NotTo(HaveOccurred())
+
+		onChange := func(_ context.Context, changed config.Config) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			changes = append(changes, changed)
+
+			err := onChangeErr
+			onChangeErr = nil
+			return err
+		}
+
+		watcher, err := config.NewWatcher(path, newBaseConfig(), loaded, 10*time.Millisecond, onChange)
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(watcher.Start(ctx)).To(Succeed())
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("does not call onChange while the file is unchanged", func() {
+		Consistently(getChanges, "100ms").Should(BeEmpty())
+	})
+
+	It("calls onChange when the defaults change", func() {
+		writeConfig(configHeader + "defaults:\n  apiAllowList: 10.3.0.0/24\n")
+		Eventually(getAPIAllowLists).Should(Equal([]string{"10.3.0.0/24"}))
+		Consistently(getAPIAllowLists, "100ms").Should(Equal([]string{"10.3.0.0/24"}))
+	})
+
+	It("does not call onChange when only startup fields change", func() {
+		writeConfig(configHeader + "defaults:\n  apiAllowList: 10.2.0.0/24\nmaxConcurrentReconciles: 5\n")
+		Consistently(getChanges, "100ms").Should(BeEmpty())
+
+		By("keeping the startup fields of the changed file")
+		writeConfig(configHeader + "defaults:\n  apiAllowList: 10.3.0.0/24\nmaxConcurrentReconciles: 5\n")
+		Eventually(getChanges).Should(HaveLen(1))
+		Expect(getChanges()[0].MaxConcurrentReconciles).To(Equal(5))
+	})
+
+	It("ignores invalid configs", func() {
+		writeConfig(configHeader + "defaults:\n  apiAllowList: not-a-cidr\n")
+		Consistently(getChanges, "100ms").Should(BeEmpty())
+
+		By("applying the next valid config")
+		writeConfig(configHeader + "defaults:\n  apiAllowList: 10.3.0.0/24\n")
+		Eventually(getAPIAllowLists).Should(Equal([]string{"10.3.0.0/24"}))
+	})
+
+	It("calls onChange again when it failed", func() {
+		mutex.Lock()
+		onChangeErr = errors.New("boom")
+		mutex.Unlock()
+
+		writeConfig(configHeader + "defaults:\n  apiAllowList: 10.3.0.0/24\n")
+		Eventually(getAPIAllowLists).Should(Equal([]string{"10.3.0.0/24", "10.3.0.0/24"}))
+		Consistently(getAPIAllowLists, "100ms").Should(HaveLen(2))
+	})
+})
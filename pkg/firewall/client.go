@@ -2,14 +2,18 @@ package firewall
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	compute "cloud.google.com/go/compute/apiv1"
 	"github.com/giantswarm/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -17,20 +21,39 @@ import (
 )
 
 const (
-	ProtocolTCP      = "tcp"
-	ProtocolUDP      = "udp"
-	PortSSH          = uint32(22)
-	DirectionIngress = "INGRESS"
-	DirectionEgress  = "EGRESS"
+	ProtocolTCP       = "tcp"
+	ProtocolUDP       = "udp"
+	ProtocolAll       = "all"
+	PortSSH           = uint32(22)
+	PortKubernetesAPI = uint32(6443)
+	DirectionIngress  = "INGRESS"
+	DirectionEgress   = "EGRESS"
+
+	LogMetadataIncludeAll = "INCLUDE_ALL_METADATA"
+	LogMetadataExcludeAll = "EXCLUDE_ALL_METADATA"
+
+	MinPriority     = int32(0)
+	MaxPriority     = int32(65535)
+	DefaultPriority = int32(1000)
 )
 
+// Rule describes a VPC firewall rule. A nil Priority is not sent to GCP, so
+// the rule gets GCP's default priority of 1000.
 type Rule struct {
-	Allowed      []Allowed
-	Description  string
-	Direction    string
-	Name         string
-	TargetTags   []string
-	SourceRanges []string
+	Allowed               []Allowed
+	Denied                []Denied
+	Description           string
+	Direction             string
+	Disabled              bool
+	Logging               Logging
+	Name                  string
+	Priority              *int32
+	TargetTags            []string
+	TargetServiceAccounts []string
+	SourceRanges          []string
+	SourceTags            []string
+	SourceServiceAccounts []string
+	DestinationRanges     []string
 }
 
 type Allowed struct {
@@ -38,22 +61,69 @@ type Allowed struct {
 	Ports      []uint32
 }
 
+type Denied struct {
+	IPProtocol string
+	Ports      []uint32
+}
+
+// Logging configures VPC firewall rule logging. Metadata is only sent to GCP
+// when logging is enabled.
+type Logging struct {
+	Enabled  bool
+	Metadata string
+}
+
 type Client struct {
 	firewallClient *compute.FirewallsClient
+	retryConfig    google.RetryConfig
+	operations     *google.OperationTracker
 }
 
-func NewClient(firewallService *compute.FirewallsClient) *Client {
+// NewClient creates a firewall client. Operations are tracked by operations
+// instead of being waited for, unless it is nil.
+func NewClient(firewallService *compute.FirewallsClient, retryConfig google.RetryConfig, operations *google.OperationTracker) *Client {
 	return &Client{
 		firewallClient: firewallService,
+		retryConfig:    retryConfig,
+		operations:     operations,
 	}
 }
 
-func (c *Client) ApplyRule(ctx context.Context, cluster *capg.GCPCluster, rule Rule) error {
+// ApplyRule creates the rule or updates it if it changed. It returns true
+// while the operation started for the rule is still running.
+func (c *Client) ApplyRule(ctx context.Context, cluster *capg.GCPCluster, rule Rule) (bool, error) {
+	pending := false
+	err := google.Retry(ctx, c.retryConfig, func() error {
+		var err error
+		pending, err = c.applyRule(ctx, cluster, rule)
+		return err
+	})
+
+	return pending, err
+}
+
+func (c *Client) applyRule(ctx context.Context, cluster *capg.GCPCluster, rule Rule) (bool, error) {
 	logger := c.getLogger(ctx, rule.Name)
 
 	logger.Info("Creating firewall rule")
 	defer logger.Info("Done creating firewall rule")
 
+	err := validateRule(rule)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	key := getOperationKey(cluster, rule.Name)
+	pending, err := c.operations.Poll(ctx, key)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if pending {
+		logger.Info("Previous operation still running")
+		return true, nil
+	}
+
 	firewall := toGCPFirewall(cluster, rule)
 
 	req := &computepb.InsertFirewallRequest{
@@ -64,24 +134,41 @@ func (c *Client) ApplyRule(ctx context.Context, cluster *capg.GCPCluster, rule R
 
 	if google.HasHttpCode(err, http.StatusConflict) {
 		logger.Info("Firewall already exists. Updating")
-		err = c.updateFirewall(ctx, cluster, firewall)
-		return errors.WithStack(err)
+		pending, err = c.updateFirewall(ctx, cluster, key, firewall)
+		return pending, errors.WithStack(err)
 	}
 
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	pending, err = c.operations.Start(ctx, key, op)
+	return pending, errors.WithStack(err)
 }
 
 func (c *Client) DeleteRule(ctx context.Context, cluster *capg.GCPCluster, ruleName string) error {
+	return google.Retry(ctx, c.retryConfig, func() error {
+		return c.deleteRule(ctx, cluster, ruleName)
+	})
+}
+
+func (c *Client) deleteRule(ctx context.Context, cluster *capg.GCPCluster, ruleName string) error {
 	logger := c.getLogger(ctx, ruleName)
 
 	logger.Info("Deleting firewall rule")
 	defer logger.Info("Done deleting firewall rule")
 
+	key := getOperationKey(cluster, ruleName)
+	pending, err := c.operations.Poll(ctx, key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if pending {
+		logger.Info("Previous operation still running")
+		return nil
+	}
+
 	req := &computepb.DeleteFirewallRequest{
 		Project:  cluster.Spec.Project,
 		Firewall: ruleName,
@@ -92,24 +179,48 @@ func (c *Client) DeleteRule(ctx context.Context, cluster *capg.GCPCluster, ruleN
 		return nil
 	}
 
-	err = op.Wait(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
+	_, err = c.operations.Start(ctx, key, op)
 	return errors.WithStack(err)
 }
 
-func (c *Client) updateFirewall(ctx context.Context, cluster *capg.GCPCluster, firewall *computepb.Firewall) error {
-	req := &computepb.PatchFirewallRequest{
+// updateFirewall replaces the rule with the desired state. Unlike security
+// policies, VPC firewall rules don't have a fingerprint in the compute API,
+// so the update can't be made conditional on the rule being unchanged. Since
+// the whole desired rule is sent, concurrent updates converge on the next
+// reconciliation instead of mixing fields. An update is used instead of a
+// patch, because a patch keeps repeated fields that are sent empty, like
+// source tags that were removed. Rules that are already up to date are not
+// updated, so that reconciliations don't start an operation every time.
+func (c *Client) updateFirewall(ctx context.Context, cluster *capg.GCPCluster, key google.OperationKey, firewall *computepb.Firewall) (bool, error) {
+	getReq := &computepb.GetFirewallRequest{
+		Firewall: *firewall.Name,
+		Project:  cluster.Spec.Project,
+	}
+	current, err := c.firewallClient.Get(ctx, getReq)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if isSameFirewall(current, firewall) {
+		return false, nil
+	}
+
+	req := &computepb.UpdateFirewallRequest{
 		Firewall:         *firewall.Name,
 		FirewallResource: firewall,
 		Project:          cluster.Spec.Project,
 	}
-	op, err := c.firewallClient.Patch(ctx, req)
+	op, err := c.firewallClient.Update(ctx, req)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	pending, err := c.operations.Start(ctx, key, op)
+	return pending, errors.WithStack(err)
 }
 
 func (c *Client) getLogger(ctx context.Context, ruleName string) logr.Logger {
@@ -118,6 +229,13 @@ func (c *Client) getLogger(ctx context.Context, ruleName string) logr.Logger {
 	return logger.WithValues("name", ruleName)
 }
 
+func getOperationKey(cluster *capg.GCPCluster, ruleName string) google.OperationKey {
+	return google.OperationKey{
+		Cluster:  types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name},
+		Resource: google.CollectionFirewalls + "/" + ruleName,
+	}
+}
+
 func toGCPFirewall(cluster *capg.GCPCluster, rule Rule) *computepb.Firewall {
 	allowed := []*computepb.Allowed{}
 	for _, allowedPorts := range rule.Allowed {
@@ -129,15 +247,189 @@ func toGCPFirewall(cluster *capg.GCPCluster, rule Rule) *computepb.Firewall {
 		})
 	}
 
+	denied := []*computepb.Denied{}
+	for _, deniedPorts := range rule.Denied {
+		ports := convertPorts(deniedPorts.Ports)
+
+		denied = append(denied, &computepb.Denied{
+			IPProtocol: to.StringP(deniedPorts.IPProtocol),
+			Ports:      ports,
+		})
+	}
+
+	logConfig := &computepb.FirewallLogConfig{
+		Enable: to.BoolP(rule.Logging.Enabled),
+	}
+	if rule.Logging.Enabled && rule.Logging.Metadata != "" {
+		logConfig.Metadata = to.StringP(rule.Logging.Metadata)
+	}
+
 	return &computepb.Firewall{
-		Allowed:      allowed,
-		Description:  to.StringP(rule.Description),
-		Direction:    to.StringP(rule.Direction),
-		Name:         to.StringP(rule.Name),
-		Network:      cluster.Status.Network.SelfLink,
-		TargetTags:   rule.TargetTags,
-		SourceRanges: rule.SourceRanges,
+		Allowed:               allowed,
+		Denied:                denied,
+		Description:           to.StringP(rule.Description),
+		Direction:             to.StringP(rule.Direction),
+		Disabled:              to.BoolP(rule.Disabled),
+		LogConfig:             logConfig,
+		Name:                  to.StringP(rule.Name),
+		Network:               cluster.Status.Network.SelfLink,
+		Priority:              rule.Priority,
+		TargetTags:            rule.TargetTags,
+		TargetServiceAccounts: rule.TargetServiceAccounts,
+		SourceRanges:          rule.SourceRanges,
+		SourceTags:            rule.SourceTags,
+		SourceServiceAccounts: rule.SourceServiceAccounts,
+		DestinationRanges:     rule.DestinationRanges,
+	}
+}
+
+// validateRule rejects rules GCP would refuse, so that the error points at
+// the offending field instead of a generic 400 from the API.
+func validateRule(rule Rule) error {
+	if rule.Name == "" {
+		return errors.New("firewall rule name must not be empty")
 	}
+
+	if len(rule.Allowed) == 0 && len(rule.Denied) == 0 {
+		return errors.New("firewall rule must have allowed or denied protocols")
+	}
+
+	if len(rule.Allowed) != 0 && len(rule.Denied) != 0 {
+		return errors.New("firewall rule can not have both allowed and denied protocols")
+	}
+
+	if rule.Direction != "" && rule.Direction != DirectionIngress && rule.Direction != DirectionEgress {
+		return fmt.Errorf("firewall rule direction %q is invalid", rule.Direction)
+	}
+
+	if rule.Priority != nil && (*rule.Priority < MinPriority || *rule.Priority > MaxPriority) {
+		return fmt.Errorf("firewall rule priority %d is not between %d and %d", *rule.Priority, MinPriority, MaxPriority)
+	}
+
+	if len(rule.TargetTags) != 0 && len(rule.TargetServiceAccounts) != 0 {
+		return errors.New("firewall rule can not have both target tags and target service accounts")
+	}
+
+	if len(rule.SourceTags) != 0 && len(rule.SourceServiceAccounts) != 0 {
+		return errors.New("firewall rule can not have both source tags and source service accounts")
+	}
+
+	if len(rule.SourceServiceAccounts) != 0 && len(rule.TargetTags) != 0 {
+		return errors.New("firewall rule can not have both source service accounts and target tags")
+	}
+
+	if len(rule.SourceTags) != 0 && len(rule.TargetServiceAccounts) != 0 {
+		return errors.New("firewall rule can not have both source tags and target service accounts")
+	}
+
+	if rule.Direction == DirectionEgress && (len(rule.SourceTags) != 0 || len(rule.SourceServiceAccounts) != 0) {
+		return errors.New("egress firewall rule can not have source tags or source service accounts")
+	}
+
+	if rule.Logging.Metadata != "" && rule.Logging.Metadata != LogMetadataIncludeAll && rule.Logging.Metadata != LogMetadataExcludeAll {
+		return fmt.Errorf("firewall rule log metadata %q is invalid", rule.Logging.Metadata)
+	}
+
+	return nil
+}
+
+// isSameFirewall compares the fields the operator manages. GCP fills in
+// defaults for fields that weren't sent, like the priority and direction, so
+// those are compared with their defaults.
+func isSameFirewall(current, desired *computepb.Firewall) bool {
+	return current.GetDescription() == desired.GetDescription() &&
+		getDirection(current) == getDirection(desired) &&
+		current.GetDisabled() == desired.GetDisabled() &&
+		current.GetNetwork() == desired.GetNetwork() &&
+		getPriority(current) == getPriority(desired) &&
+		current.GetLogConfig().GetEnable() == desired.GetLogConfig().GetEnable() &&
+		(!desired.GetLogConfig().GetEnable() || current.GetLogConfig().GetMetadata() == getLogMetadata(desired)) &&
+		isSameProtocols(current.Allowed, desired.Allowed) &&
+		isSameDeniedProtocols(current.Denied, desired.Denied) &&
+		isSameSet(current.TargetTags, desired.TargetTags) &&
+		isSameSet(current.TargetServiceAccounts, desired.TargetServiceAccounts) &&
+		isSameSet(current.SourceRanges, desired.SourceRanges) &&
+		isSameSet(current.SourceTags, desired.SourceTags) &&
+		isSameSet(current.SourceServiceAccounts, desired.SourceServiceAccounts) &&
+		isSameSet(current.DestinationRanges, desired.DestinationRanges)
+}
+
+func getDirection(firewall *computepb.Firewall) string {
+	if firewall.GetDirection() == "" {
+		return DirectionIngress
+	}
+
+	return firewall.GetDirection()
+}
+
+func getPriority(firewall *computepb.Firewall) int32 {
+	if firewall.Priority == nil {
+		return DefaultPriority
+	}
+
+	return firewall.GetPriority()
+}
+
+func getLogMetadata(firewall *computepb.Firewall) string {
+	if firewall.GetLogConfig().GetMetadata() == "" {
+		return LogMetadataIncludeAll
+	}
+
+	return firewall.GetLogConfig().GetMetadata()
+}
+
+func isSameProtocols(current, desired []*computepb.Allowed) bool {
+	currentProtocols := []string{}
+	for _, allowed := range current {
+		currentProtocols = append(currentProtocols, formatProtocol(allowed.GetIPProtocol(), allowed.Ports))
+	}
+
+	desiredProtocols := []string{}
+	for _, allowed := range desired {
+		desiredProtocols = append(desiredProtocols, formatProtocol(allowed.GetIPProtocol(), allowed.Ports))
+	}
+
+	return isSameSet(currentProtocols, desiredProtocols)
+}
+
+func isSameDeniedProtocols(current, desired []*computepb.Denied) bool {
+	currentProtocols := []string{}
+	for _, denied := range current {
+		currentProtocols = append(currentProtocols, formatProtocol(denied.GetIPProtocol(), denied.Ports))
+	}
+
+	desiredProtocols := []string{}
+	for _, denied := range desired {
+		desiredProtocols = append(desiredProtocols, formatProtocol(denied.GetIPProtocol(), denied.Ports))
+	}
+
+	return isSameSet(currentProtocols, desiredProtocols)
+}
+
+func formatProtocol(protocol string, ports []string) string {
+	sortedPorts := append([]string{}, ports...)
+	sort.Strings(sortedPorts)
+
+	return protocol + ":" + strings.Join(sortedPorts, ",")
+}
+
+func isSameSet(current, desired []string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+
+	sortedCurrent := append([]string{}, current...)
+	sortedDesired := append([]string{}, desired...)
+	sort.Strings(sortedCurrent)
+	sort.Strings(sortedDesired)
+
+	for i := range sortedCurrent {
+		if sortedCurrent[i] != sortedDesired[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 func convertPorts(portsNums []uint32) []string {
@@ -0,0 +1,179 @@
+package firewall_test
+
+import (
+	"github.com/giantswarm/to"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+)
+
+var _ = Describe("Client", func() {
+	newRule := func(modify func(*firewall.Rule)) firewall.Rule {
+		rule := firewall.Rule{
+			Allowed: []firewall.Allowed{
+				{
+					IPProtocol: firewall.ProtocolTCP,
+					Ports:      []uint32{firewall.PortKubernetesAPI},
+				},
+			},
+			Description: "allow the kubernetes api",
+			Direction:   firewall.DirectionIngress,
+			Name:        "allow-kubernetes-api",
+			Priority:    to.Int32P(firewall.DefaultPriority),
+			TargetTags:  []string{"control-plane"},
+			SourceRanges: []string{
+				"10.0.0.0/24",
+			},
+		}
+		modify(&rule)
+
+		return rule
+	}
+
+	DescribeTable("validateRule",
+		func(modify func(*firewall.Rule), expectedError string) {
+			err := firewall.ValidateRule(newRule(modify))
+			if expectedError == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(MatchError(ContainSubstring(expectedError)))
+		},
+		Entry("valid rule", func(r *firewall.Rule) {}, ""),
+		Entry("empty name", func(r *firewall.Rule) { r.Name = "" }, "name must not be empty"),
+		Entry("no protocols", func(r *firewall.Rule) { r.Allowed = nil }, "must have allowed or denied protocols"),
+		Entry("denied protocols", func(r *firewall.Rule) {
+			r.Allowed = nil
+			r.Denied = []firewall.Denied{{IPProtocol: firewall.ProtocolAll}}
+		}, ""),
+		Entry("allowed and denied protocols", func(r *firewall.Rule) {
+			r.Denied = []firewall.Denied{{IPProtocol: firewall.ProtocolAll}}
+		}, "both allowed and denied protocols"),
+		Entry("unset direction", func(r *firewall.Rule) { r.Direction = "" }, ""),
+		Entry("invalid direction", func(r *firewall.Rule) { r.Direction = "SIDEWAYS" }, `direction "SIDEWAYS" is invalid`),
+		Entry("unset priority", func(r *firewall.Rule) { r.Priority = nil }, ""),
+		Entry("priority 0", func(r *firewall.Rule) { r.Priority = to.Int32P(0) }, ""),
+		Entry("maximum priority", func(r *firewall.Rule) { r.Priority = to.Int32P(firewall.MaxPriority) }, ""),
+		Entry("negative priority", func(r *firewall.Rule) { r.Priority = to.Int32P(-1) }, "priority -1 is not between"),
+		Entry("priority above the maximum", func(r *firewall.Rule) { r.Priority = to.Int32P(65536) }, "priority 65536 is not between"),
+		Entry("target tags and target service accounts", func(r *firewall.Rule) {
+			r.TargetServiceAccounts = []string{"nodes@project.iam.gserviceaccount.com"}
+		}, "both target tags and target service accounts"),
+		Entry("source tags and source service accounts", func(r *firewall.Rule) {
+			r.TargetTags = nil
+			r.SourceTags = []string{"bastion"}
+			r.SourceServiceAccounts = []string{"bastion@project.iam.gserviceaccount.com"}
+		}, "both source tags and source service accounts"),
+		Entry("source service accounts and target tags", func(r *firewall.Rule) {
+			r.SourceServiceAccounts = []string{"bastion@project.iam.gserviceaccount.com"}
+		}, "both source service accounts and target tags"),
+		Entry("source tags and target service accounts", func(r *firewall.Rule) {
+			r.TargetTags = nil
+			r.TargetServiceAccounts = []string{"nodes@project.iam.gserviceaccount.com"}
+			r.SourceTags = []string{"bastion"}
+		}, "both source tags and target service accounts"),
+		Entry("source tags and target tags", func(r *firewall.Rule) { r.SourceTags = []string{"bastion"} }, ""),
+		Entry("egress rule with source tags", func(r *firewall.Rule) {
+			r.Direction = firewall.DirectionEgress
+			r.SourceTags = []string{"bastion"}
+		}, "egress firewall rule can not have source tags"),
+		Entry("egress rule with destination ranges", func(r *firewall.Rule) {
+			r.Direction = firewall.DirectionEgress
+			r.SourceRanges = nil
+			r.DestinationRanges = []string{"0.0.0.0/0"}
+		}, ""),
+		Entry("log metadata", func(r *firewall.Rule) {
+			r.Logging = firewall.Logging{Enabled: true, Metadata: firewall.LogMetadataExcludeAll}
+		}, ""),
+		Entry("invalid log metadata", func(r *firewall.Rule) {
+			r.Logging = firewall.Logging{Enabled: true, Metadata: "SOME_METADATA"}
+		}, `log metadata "SOME_METADATA" is invalid`),
+	)
+
+	DescribeTable("isSameFirewall",
+		func(modifyCurrent func(*computepb.Firewall), modifyDesired func(*firewall.Rule), expected bool) {
+			cluster := &capg.GCPCluster{}
+			cluster.Status.Network.SelfLink = to.StringP("https://www.googleapis.com/compute/v1/projects/project/global/networks/network")
+
+			current := firewall.ToGCPFirewall(cluster, newRule(func(*firewall.Rule) {}))
+			modifyCurrent(current)
+			desired := firewall.ToGCPFirewall(cluster, newRule(modifyDesired))
+
+			Expect(firewall.IsSameFirewall(current, desired)).To(Equal(expected))
+		},
+		Entry("identical rules",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) {},
+			true),
+		Entry("default priority and direction filled in by GCP",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) {
+				r.Priority = nil
+				r.Direction = ""
+			},
+			true),
+		Entry("priority 0 is not the default",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) { r.Priority = to.Int32P(0) },
+			false),
+		Entry("changed description",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) { r.Description = "something else" },
+			false),
+		Entry("changed network",
+			func(f *computepb.Firewall) { f.Network = to.StringP("other-network") },
+			func(r *firewall.Rule) {},
+			false),
+		Entry("reordered source ranges",
+			func(f *computepb.Firewall) { f.SourceRanges = []string{"10.0.1.0/24", "10.0.0.0/24"} },
+			func(r *firewall.Rule) { r.SourceRanges = []string{"10.0.0.0/24", "10.0.1.0/24"} },
+			true),
+		Entry("emptied source tags",
+			func(f *computepb.Firewall) { f.SourceTags = []string{"bastion"} },
+			func(r *firewall.Rule) {},
+			false),
+		Entry("reordered ports",
+			func(f *computepb.Firewall) { f.Allowed[0].Ports = []string{"6443", "22"} },
+			func(r *firewall.Rule) { r.Allowed[0].Ports = []uint32{firewall.PortSSH, firewall.PortKubernetesAPI} },
+			true),
+		Entry("changed protocol",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) { r.Allowed[0].IPProtocol = firewall.ProtocolUDP },
+			false),
+		Entry("allowed changed to denied",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) {
+				r.Denied = []firewall.Denied{{IPProtocol: r.Allowed[0].IPProtocol, Ports: r.Allowed[0].Ports}}
+				r.Allowed = nil
+			},
+			false),
+		Entry("enabled logging",
+			func(f *computepb.Firewall) {},
+			func(r *firewall.Rule) { r.Logging.Enabled = true },
+			false),
+		Entry("default log metadata filled in by GCP",
+			func(f *computepb.Firewall) {
+				f.LogConfig.Enable = to.BoolP(true)
+				f.LogConfig.Metadata = to.StringP(firewall.LogMetadataIncludeAll)
+			},
+			func(r *firewall.Rule) { r.Logging.Enabled = true },
+			true),
+		Entry("changed log metadata",
+			func(f *computepb.Firewall) {
+				f.LogConfig.Enable = to.BoolP(true)
+				f.LogConfig.Metadata = to.StringP(firewall.LogMetadataIncludeAll)
+			},
+			func(r *firewall.Rule) {
+				r.Logging = firewall.Logging{Enabled: true, Metadata: firewall.LogMetadataExcludeAll}
+			},
+			false),
+		Entry("log metadata of disabled logging is ignored",
+			func(f *computepb.Firewall) { f.LogConfig.Metadata = to.StringP(firewall.LogMetadataExcludeAll) },
+			func(r *firewall.Rule) {},
+			true),
+	)
+})
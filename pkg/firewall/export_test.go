@@ -0,0 +1,7 @@
+package firewall
+
+var (
+	ValidateRule   = validateRule
+	IsSameFirewall = isSameFirewall
+	ToGCPFirewall  = toGCPFirewall
+)
@@ -11,7 +11,7 @@ import (
 )
 
 type FakeFirewallsClient struct {
-	ApplyRuleStub        func(context.Context, *v1beta1.GCPCluster, firewall.Rule) error
+	ApplyRuleStub        func(context.Context, *v1beta1.GCPCluster, firewall.Rule) (bool, error)
 	applyRuleMutex       sync.RWMutex
 	applyRuleArgsForCall []struct {
 		arg1 context.Context
@@ -19,10 +19,12 @@ type FakeFirewallsClient struct {
 		arg3 firewall.Rule
 	}
 	applyRuleReturns struct {
-		result1 error
+		result1 bool
+		result2 error
 	}
 	applyRuleReturnsOnCall map[int]struct {
-		result1 error
+		result1 bool
+		result2 error
 	}
 	DeleteRuleStub        func(context.Context, *v1beta1.GCPCluster, string) error
 	deleteRuleMutex       sync.RWMutex
@@ -41,7 +43,7 @@ type FakeFirewallsClient struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeFirewallsClient) ApplyRule(arg1 context.Context, arg2 *v1beta1.GCPCluster, arg3 firewall.Rule) error {
+func (fake *FakeFirewallsClient) ApplyRule(arg1 context.Context, arg2 *v1beta1.GCPCluster, arg3 firewall.Rule) (bool, error) {
 	fake.applyRuleMutex.Lock()
 	ret, specificReturn := fake.applyRuleReturnsOnCall[len(fake.applyRuleArgsForCall)]
 	fake.applyRuleArgsForCall = append(fake.applyRuleArgsForCall, struct {
@@ -57,9 +59,9 @@ func (fake *FakeFirewallsClient) ApplyRule(arg1 context.Context, arg2 *v1beta1.G
 		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fakeReturns.result1
+	return fakeReturns.result1, fakeReturns.result2
 }
 
 func (fake *FakeFirewallsClient) ApplyRuleCallCount() int {
@@ -68,7 +70,7 @@ func (fake *FakeFirewallsClient) ApplyRuleCallCount() int {
 	return len(fake.applyRuleArgsForCall)
 }
 
-func (fake *FakeFirewallsClient) ApplyRuleCalls(stub func(context.Context, *v1beta1.GCPCluster, firewall.Rule) error) {
+func (fake *FakeFirewallsClient) ApplyRuleCalls(stub func(context.Context, *v1beta1.GCPCluster, firewall.Rule) (bool, error)) {
 	fake.applyRuleMutex.Lock()
 	defer fake.applyRuleMutex.Unlock()
 	fake.ApplyRuleStub = stub
@@ -81,27 +83,30 @@ func (fake *FakeFirewallsClient) ApplyRuleArgsForCall(i int) (context.Context, *
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
-func (fake *FakeFirewallsClient) ApplyRuleReturns(result1 error) {
+func (fake *FakeFirewallsClient) ApplyRuleReturns(result1 bool, result2 error) {
 	fake.applyRuleMutex.Lock()
 	defer fake.applyRuleMutex.Unlock()
 	fake.ApplyRuleStub = nil
 	fake.applyRuleReturns = struct {
-		result1 error
-	}{result1}
+		result1 bool
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeFirewallsClient) ApplyRuleReturnsOnCall(i int, result1 error) {
+func (fake *FakeFirewallsClient) ApplyRuleReturnsOnCall(i int, result1 bool, result2 error) {
 	fake.applyRuleMutex.Lock()
 	defer fake.applyRuleMutex.Unlock()
 	fake.ApplyRuleStub = nil
 	if fake.applyRuleReturnsOnCall == nil {
 		fake.applyRuleReturnsOnCall = make(map[int]struct {
-			result1 error
+			result1 bool
+			result2 error
 		})
 	}
 	fake.applyRuleReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
+		result1 bool
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *FakeFirewallsClient) DeleteRule(arg1 context.Context, arg2 *v1beta1.GCPCluster, arg3 string) error {
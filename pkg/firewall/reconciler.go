@@ -3,49 +3,236 @@ package firewall
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/giantswarm/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
 )
 
-const AnnotationBastionAllowListSubnets = "bastion.gcp.giantswarm.io/allowlist"
+const (
+	// ReconcilerName identifies the reconciler of the firewall rules in the
+	// GCPCluster controller.
+	ReconcilerName = "firewall-rules"
+
+	AnnotationBastionAllowListSubnets = "bastion.gcp.giantswarm.io/allowlist"
+	AnnotationBastionSSHMode          = "bastion.gcp.giantswarm.io/ssh-mode"
+	AnnotationEgressDefaultDeny       = "egress.gcp.giantswarm.io/default-deny"
+	AnnotationEgressAllowListSubnets  = "egress.gcp.giantswarm.io/allowlist"
+	AnnotationFirewallLogging         = "firewall.gcp.giantswarm.io/logging"
+
+	// LoggingDisabled, LoggingIncludeAllMetadata and LoggingExcludeAllMetadata
+	// are the accepted values of the logging annotation and flag.
+	LoggingDisabled           = "disabled"
+	LoggingIncludeAllMetadata = "include-all-metadata"
+	LoggingExcludeAllMetadata = "exclude-all-metadata"
+
+	// SSHModePublic allows SSH from the default and annotation allowlists.
+	SSHModePublic = "public"
+	// SSHModeIAP only allows SSH tunneled through Identity-Aware Proxy.
+	SSHModeIAP = "iap"
+	// SSHModeBoth allows SSH from the allowlists and through Identity-Aware Proxy.
+	SSHModeBoth = "both"
+	// SSHModeNone disables SSH access and removes the bastion firewall rule.
+	SSHModeNone = "none"
+
+	// IAPSourceRange is the range used by Identity-Aware Proxy for TCP
+	// forwarding. See https://cloud.google.com/iap/docs/using-tcp-forwarding
+	IAPSourceRange = "35.235.240.0/20"
+
+	// EgressDenyPriority is the lowest priority a firewall rule can have, so
+	// that any allow rule overrides the default deny egress rule.
+	EgressDenyPriority = int32(65535)
+	// EgressAllowPriority is higher than EgressDenyPriority but leaves room
+	// for user managed rules with the default priority to take precedence.
+	EgressAllowPriority = int32(65000)
+
+	AllIPv4Ranges = "0.0.0.0/0"
+	AllIPv6Ranges = "::/0"
+
+	// IPv6RuleNameSuffix is appended to the name of the IPv6 variant of a
+	// rule, because GCP firewall rules can not mix IP families.
+	IPv6RuleNameSuffix = "-ipv6"
+
+	// RequeueAfterEgressAllowRulePending is how long to wait before
+	// applying the egress deny rule again while the operation of the egress
+	// allow rule is still running.
+	RequeueAfterEgressAllowRulePending = time.Second * 5
+
+	// EventReasonAllowListEntryExpired is the reason of the event recorded
+	// when an expiring allowlist entry is left out of a rule.
+	EventReasonAllowListEntryExpired = "AllowListEntryExpired"
+)
 
 //counterfeiter:generate . FirewallsClient
 type FirewallsClient interface {
-	ApplyRule(context.Context, *capg.GCPCluster, Rule) error
+	// ApplyRule returns true while the operation started for the rule is
+	// still running.
+	ApplyRule(context.Context, *capg.GCPCluster, Rule) (bool, error)
 	DeleteRule(context.Context, *capg.GCPCluster, string) error
 }
 
+//counterfeiter:generate . ClusterNATIPResolver
+type ClusterNATIPResolver interface {
+	GetIPs(context.Context, types.NamespacedName) ([]string, error)
+}
+
 func NewRuleReconciler(
 	defaultBastionHostAllowList []string,
+	controlPlaneAllowList []string,
+	defaultEgressAllowList []string,
+	managementCluster types.NamespacedName,
+	defaultLogging Logging,
+	aggregateRanges bool,
 	firewallClient FirewallsClient,
+	ipResolver ClusterNATIPResolver,
+	recorder record.EventRecorder,
 ) *RuleReconciler {
 	return &RuleReconciler{
-		defaultBastionHostAllowList: defaultBastionHostAllowList,
-		firewallClient:              firewallClient,
+		defaults: Defaults{
+			BastionHostAllowList:  defaultBastionHostAllowList,
+			ControlPlaneAllowList: controlPlaneAllowList,
+			EgressAllowList:       defaultEgressAllowList,
+			Logging:               defaultLogging,
+			AggregateRanges:       aggregateRanges,
+		},
+		managementCluster: managementCluster,
+		firewallClient:    firewallClient,
+		ipResolver:        ipResolver,
+		recorder:          recorder,
+		expiredEntries:    cidr.NewExpiredEntries(),
+		egressRulesAbsent: map[types.NamespacedName]bool{},
 	}
 }
 
+// Defaults are the operator defaults for clusters that don't override them
+// with annotations.
+type Defaults struct {
+	BastionHostAllowList  []string
+	ControlPlaneAllowList []string
+	EgressAllowList       []string
+	Logging               Logging
+	AggregateRanges       bool
+}
+
 type RuleReconciler struct {
-	defaultBastionHostAllowList []string
+	defaultsMutex     sync.RWMutex
+	defaults          Defaults
+	managementCluster types.NamespacedName
 
 	firewallClient FirewallsClient
+	ipResolver     ClusterNATIPResolver
+	recorder       record.EventRecorder
+	expiredEntries *cidr.ExpiredEntries
+
+	// egressRulesAbsent holds the clusters whose egress rules were deleted
+	// since the operator started, so that clusters without default deny
+	// egress don't delete them on every reconciliation.
+	egressRulesMutex  sync.Mutex
+	egressRulesAbsent map[types.NamespacedName]bool
+}
+
+func (r *RuleReconciler) Name() string {
+	return ReconcilerName
 }
 
-func (r *RuleReconciler) Reconcile(ctx context.Context, cluster *capg.GCPCluster) error {
+// AllowListAnnotations returns the allowlist annotations the reconciler
+// reads.
+func (r *RuleReconciler) AllowListAnnotations() []string {
+	return []string{AnnotationBastionAllowListSubnets, AnnotationEgressAllowListSubnets}
+}
+
+// Reconcile applies the firewall rules of the cluster. The result requeues
+// the cluster when the first expiring allowlist entry expires.
+func (r *RuleReconciler) Reconcile(ctx context.Context, cluster *capg.GCPCluster) (ctrl.Result, error) {
+	logging, err := r.getLogging(cluster)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	bastionExpiry, err := r.reconcileBastionRule(ctx, cluster, logging)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	err = r.reconcileControlPlaneRule(ctx, cluster, logging)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	egressExpiry, egressPending, err := r.reconcileEgressRules(ctx, cluster, logging)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	if egressPending {
+		return ctrl.Result{RequeueAfter: RequeueAfterEgressAllowRulePending}, nil
+	}
+
+	return ctrl.Result{RequeueAfter: cidr.UntilExpiry(bastionExpiry, egressExpiry)}, nil
+}
+
+func (r *RuleReconciler) ReconcileDelete(ctx context.Context, cluster *capg.GCPCluster) error {
+	r.setEgressRulesAbsent(cluster, false)
+	r.expiredEntries.Forget(fmt.Sprintf("%s/%s", cluster.Namespace, cluster.Name))
+
+	ruleNames := []string{
+		getBastionFirewallRuleName(cluster.Name),
+		getControlPlaneFirewallRuleName(cluster.Name),
+		getEgressDenyFirewallRuleName(cluster.Name),
+		getEgressAllowFirewallRuleName(cluster.Name),
+	}
+
+	for _, ruleName := range ruleNames {
+		err := r.deletePerFamily(ctx, cluster, ruleName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *RuleReconciler) reconcileBastionRule(ctx context.Context, cluster *capg.GCPCluster, logging Logging) (time.Time, error) {
 	logger := r.getLogger(ctx)
 
 	ruleName := getBastionFirewallRuleName(cluster.Name)
 	tagName := getBastionFirewallRuleTag(cluster.Name)
-	sourceIPRanges, err := getIPRangesFromAnnotation(logger, cluster)
+
+	sshMode, err := getSSHMode(cluster)
 	if err != nil {
-		return errors.WithStack(err)
+		return time.Time{}, errors.WithStack(err)
+	}
+
+	if sshMode == SSHModeNone {
+		logger.Info("Bastion SSH access is disabled. Removing firewall rule")
+		return time.Time{}, r.deletePerFamily(ctx, cluster, ruleName)
+	}
+
+	sourceIPRanges := []string{}
+	nextExpiry := time.Time{}
+	if sshMode == SSHModePublic || sshMode == SSHModeBoth {
+		userAllowList, err := r.getAllowListFromAnnotation(logger, cluster)
+		if err != nil {
+			return time.Time{}, errors.WithStack(err)
+		}
+		sourceIPRanges = append(sourceIPRanges, userAllowList.Ranges...)
+		sourceIPRanges = append(sourceIPRanges, r.getDefaults().BastionHostAllowList...)
+		nextExpiry = userAllowList.NextExpiry
+	}
+
+	if sshMode == SSHModeIAP || sshMode == SSHModeBoth {
+		sourceIPRanges = append(sourceIPRanges, IAPSourceRange)
 	}
-	sourceIPRanges = append(sourceIPRanges, r.defaultBastionHostAllowList...)
 
 	rule := Rule{
 		Allowed: []Allowed{
@@ -56,17 +243,242 @@ func (r *RuleReconciler) Reconcile(ctx context.Context, cluster *capg.GCPCluster
 		},
 		Description:  "allow port 22 for SSH",
 		Direction:    DirectionIngress,
+		Logging:      logging,
 		Name:         ruleName,
 		TargetTags:   []string{tagName},
 		SourceRanges: sourceIPRanges,
 	}
 
-	return r.firewallClient.ApplyRule(ctx, cluster, rule)
+	_, err = r.applyPerFamily(ctx, cluster, rule)
+	return nextExpiry, errors.WithStack(err)
 }
 
-func (r *RuleReconciler) ReconcileDelete(ctx context.Context, cluster *capg.GCPCluster) error {
-	ruleName := getBastionFirewallRuleName(cluster.Name)
-	return r.firewallClient.DeleteRule(ctx, cluster, ruleName)
+// reconcileControlPlaneRule restricts access to the kubernetes api port on
+// the control plane nodes to the Google load balancer and health check ranges
+// and to other nodes of the same cluster.
+func (r *RuleReconciler) reconcileControlPlaneRule(ctx context.Context, cluster *capg.GCPCluster, logging Logging) error {
+	rule := Rule{
+		Allowed: []Allowed{
+			{
+				IPProtocol: ProtocolTCP,
+				Ports:      []uint32{PortKubernetesAPI},
+			},
+		},
+		Description:  "allow kubernetes api from load balancers and cluster nodes",
+		Direction:    DirectionIngress,
+		Logging:      logging,
+		Name:         getControlPlaneFirewallRuleName(cluster.Name),
+		TargetTags:   []string{getControlPlaneTag(cluster.Name)},
+		SourceRanges: r.getDefaults().ControlPlaneAllowList,
+		SourceTags:   []string{getClusterTag(cluster.Name)},
+	}
+
+	_, err := r.applyPerFamily(ctx, cluster, rule)
+	return errors.WithStack(err)
+}
+
+// reconcileEgressRules restricts outbound traffic from the cluster nodes when
+// the cluster opts in with the default-deny annotation. All egress is denied
+// with the lowest priority and a single allow rule opens the default, MC NAT
+// and user specified destinations. The deny rule is only applied once the
+// operations of the allow rule have finished, so that there is no window in
+// which all egress traffic is denied. Until then true is returned.
+func (r *RuleReconciler) reconcileEgressRules(ctx context.Context, cluster *capg.GCPCluster, logging Logging) (time.Time, bool, error) {
+	logger := r.getLogger(ctx)
+
+	allowRuleName := getEgressAllowFirewallRuleName(cluster.Name)
+	denyRuleName := getEgressDenyFirewallRuleName(cluster.Name)
+
+	if !isEgressDefaultDeny(cluster) {
+		if r.isEgressRulesAbsent(cluster) {
+			return time.Time{}, false, nil
+		}
+
+		err := r.deletePerFamily(ctx, cluster, denyRuleName)
+		if err != nil {
+			return time.Time{}, false, errors.WithStack(err)
+		}
+
+		err = r.deletePerFamily(ctx, cluster, allowRuleName)
+		if err != nil {
+			return time.Time{}, false, errors.WithStack(err)
+		}
+
+		r.setEgressRulesAbsent(cluster, true)
+		return time.Time{}, false, nil
+	}
+
+	logger.Info("Cluster egress is default deny")
+	r.setEgressRulesAbsent(cluster, false)
+
+	userAllowList, err := r.parseAllowList(cluster, AnnotationEgressAllowListSubnets)
+	if err != nil {
+		return time.Time{}, false, errors.WithStack(err)
+	}
+
+	mcNATIPs, err := r.ipResolver.GetIPs(ctx, r.managementCluster)
+	if err != nil {
+		return time.Time{}, false, errors.WithStack(err)
+	}
+
+	destinationRanges := []string{}
+	destinationRanges = append(destinationRanges, r.getDefaults().EgressAllowList...)
+	destinationRanges = append(destinationRanges, mcNATIPs...)
+	destinationRanges = append(destinationRanges, userAllowList.Ranges...)
+
+	tagName := getClusterTag(cluster.Name)
+
+	allowRule := Rule{
+		Allowed: []Allowed{
+			{
+				IPProtocol: ProtocolAll,
+			},
+		},
+		Description:       "allow egress to required destinations",
+		Direction:         DirectionEgress,
+		Logging:           logging,
+		Name:              allowRuleName,
+		Priority:          to.Int32P(EgressAllowPriority),
+		TargetTags:        []string{tagName},
+		DestinationRanges: destinationRanges,
+	}
+
+	allowPending, err := r.applyPerFamily(ctx, cluster, allowRule)
+	if err != nil {
+		return time.Time{}, false, errors.WithStack(err)
+	}
+
+	if allowPending {
+		logger.Info("Waiting for the egress allow rule before applying the deny rule")
+		return userAllowList.NextExpiry, true, nil
+	}
+
+	denyRule := Rule{
+		Denied: []Denied{
+			{
+				IPProtocol: ProtocolAll,
+			},
+		},
+		Description:       "deny all egress",
+		Direction:         DirectionEgress,
+		Logging:           logging,
+		Name:              denyRuleName,
+		Priority:          to.Int32P(EgressDenyPriority),
+		TargetTags:        []string{tagName},
+		DestinationRanges: []string{AllIPv4Ranges, AllIPv6Ranges},
+	}
+
+	_, err = r.applyPerFamily(ctx, cluster, denyRule)
+	return userAllowList.NextExpiry, false, errors.WithStack(err)
+}
+
+// applyPerFamily applies the IPv4 and IPv6 variants of the rule. A variant
+// without any sources, or destinations for egress rules, is removed instead,
+// since GCP treats empty ranges as matching all addresses. It returns true
+// while the operation of either variant is still running.
+func (r *RuleReconciler) applyPerFamily(ctx context.Context, cluster *capg.GCPCluster, rule Rule) (bool, error) {
+	rule, err := r.normalizeRanges(rule)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	ipv4Rule, ipv6Rule := splitRuleByFamily(rule)
+
+	pending := false
+	for _, familyRule := range []Rule{ipv4Rule, ipv6Rule} {
+		if !hasAddresses(familyRule) {
+			err = r.firewallClient.DeleteRule(ctx, cluster, familyRule.Name)
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+			continue
+		}
+
+		familyPending, err := r.firewallClient.ApplyRule(ctx, cluster, familyRule)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		pending = pending || familyPending
+	}
+
+	return pending, nil
+}
+
+// normalizeRanges canonicalizes, deduplicates and sorts the ranges of the
+// rule, so that the same allowlists always result in the same rule.
+func (r *RuleReconciler) normalizeRanges(rule Rule) (Rule, error) {
+	var err error
+	if len(rule.SourceRanges) != 0 {
+		rule.SourceRanges, err = cidr.Normalize(rule.SourceRanges, r.getDefaults().AggregateRanges)
+		if err != nil {
+			return Rule{}, errors.WithStack(err)
+		}
+	}
+
+	if len(rule.DestinationRanges) != 0 {
+		rule.DestinationRanges, err = cidr.Normalize(rule.DestinationRanges, r.getDefaults().AggregateRanges)
+		if err != nil {
+			return Rule{}, errors.WithStack(err)
+		}
+	}
+
+	return rule, nil
+}
+
+func (r *RuleReconciler) deletePerFamily(ctx context.Context, cluster *capg.GCPCluster, ruleName string) error {
+	err := r.firewallClient.DeleteRule(ctx, cluster, ruleName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return r.firewallClient.DeleteRule(ctx, cluster, ruleName+IPv6RuleNameSuffix)
+}
+
+// isEgressRulesAbsent returns true if the egress rules of the cluster were
+// already deleted since the operator started.
+func (r *RuleReconciler) isEgressRulesAbsent(cluster *capg.GCPCluster) bool {
+	r.egressRulesMutex.Lock()
+	defer r.egressRulesMutex.Unlock()
+
+	return r.egressRulesAbsent[toNamespacedName(cluster)]
+}
+
+func (r *RuleReconciler) setEgressRulesAbsent(cluster *capg.GCPCluster, absent bool) {
+	r.egressRulesMutex.Lock()
+	defer r.egressRulesMutex.Unlock()
+
+	if absent {
+		r.egressRulesAbsent[toNamespacedName(cluster)] = true
+		return
+	}
+	delete(r.egressRulesAbsent, toNamespacedName(cluster))
+}
+
+// getLogging returns the logging configuration from the cluster annotation,
+// falling back to the operator default.
+func (r *RuleReconciler) getLogging(cluster *capg.GCPCluster) (Logging, error) {
+	value, ok := cluster.Annotations[AnnotationFirewallLogging]
+	if !ok || value == "" {
+		return r.getDefaults().Logging, nil
+	}
+
+	return ParseLogging(value)
+}
+
+// SetDefaults replaces the operator defaults. They are used from the next
+// reconciliation on.
+func (r *RuleReconciler) SetDefaults(defaults Defaults) {
+	r.defaultsMutex.Lock()
+	defer r.defaultsMutex.Unlock()
+
+	r.defaults = defaults
+}
+
+func (r *RuleReconciler) getDefaults() Defaults {
+	r.defaultsMutex.RLock()
+	defer r.defaultsMutex.RUnlock()
+
+	return r.defaults
 }
 
 func (r *RuleReconciler) getLogger(ctx context.Context) logr.Logger {
@@ -74,6 +486,46 @@ func (r *RuleReconciler) getLogger(ctx context.Context) logr.Logger {
 	return logger.WithName("firewall-rule-reconciler")
 }
 
+// splitRuleByFamily returns a copy of the rule with only IPv4 ranges and one
+// with only IPv6 ranges. Source tags and service accounts only apply to the
+// IPv4 rule.
+func splitRuleByFamily(rule Rule) (Rule, Rule) {
+	ipv4Rule := rule
+	ipv6Rule := rule
+
+	ipv4Rule.SourceRanges, ipv6Rule.SourceRanges = cidr.SplitByFamily(rule.SourceRanges)
+	ipv4Rule.DestinationRanges, ipv6Rule.DestinationRanges = cidr.SplitByFamily(rule.DestinationRanges)
+
+	ipv6Rule.Name = rule.Name + IPv6RuleNameSuffix
+	ipv6Rule.SourceTags = nil
+	ipv6Rule.SourceServiceAccounts = nil
+
+	return ipv4Rule, ipv6Rule
+}
+
+func hasAddresses(rule Rule) bool {
+	if rule.Direction == DirectionEgress {
+		return len(rule.DestinationRanges) != 0
+	}
+
+	return len(rule.SourceRanges) != 0 || len(rule.SourceTags) != 0 || len(rule.SourceServiceAccounts) != 0
+}
+
+// ParseLogging converts one of the logging annotation or flag values into the
+// firewall rule logging configuration.
+func ParseLogging(value string) (Logging, error) {
+	switch value {
+	case LoggingDisabled, "":
+		return Logging{}, nil
+	case LoggingIncludeAllMetadata:
+		return Logging{Enabled: true, Metadata: LogMetadataIncludeAll}, nil
+	case LoggingExcludeAllMetadata:
+		return Logging{Enabled: true, Metadata: LogMetadataExcludeAll}, nil
+	}
+
+	return Logging{}, fmt.Errorf("firewall logging value %q is invalid", value)
+}
+
 func getBastionFirewallRuleName(clusterName string) string {
 	return fmt.Sprintf("allow-%s-bastion-ssh", clusterName)
 }
@@ -82,12 +534,78 @@ func getBastionFirewallRuleTag(clusterName string) string {
 	return fmt.Sprintf("%s-bastion", clusterName)
 }
 
-func getIPRangesFromAnnotation(logger logr.Logger, gcpCluster *capg.GCPCluster) ([]string, error) {
-	annotation, ok := gcpCluster.Annotations[AnnotationBastionAllowListSubnets]
+func getControlPlaneFirewallRuleName(clusterName string) string {
+	return fmt.Sprintf("allow-%s-control-plane-api", clusterName)
+}
+
+// getControlPlaneTag returns the network tag CAPG sets on control plane
+// instances.
+func getControlPlaneTag(clusterName string) string {
+	return fmt.Sprintf("%s-control-plane", clusterName)
+}
+
+// getClusterTag returns the network tag CAPG sets on all instances of a
+// cluster.
+func getClusterTag(clusterName string) string {
+	return clusterName
+}
+
+func getEgressAllowFirewallRuleName(clusterName string) string {
+	return fmt.Sprintf("allow-%s-egress", clusterName)
+}
+
+func getEgressDenyFirewallRuleName(clusterName string) string {
+	return fmt.Sprintf("deny-%s-egress", clusterName)
+}
+
+func isEgressDefaultDeny(gcpCluster *capg.GCPCluster) bool {
+	return gcpCluster.Annotations[AnnotationEgressDefaultDeny] == "true"
+}
+
+func getSSHMode(gcpCluster *capg.GCPCluster) (string, error) {
+	mode, ok := gcpCluster.Annotations[AnnotationBastionSSHMode]
+	if !ok || mode == "" {
+		return SSHModePublic, nil
+	}
+
+	switch mode {
+	case SSHModePublic, SSHModeIAP, SSHModeBoth, SSHModeNone:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("annotation %q has invalid value %q", AnnotationBastionSSHMode, mode)
+}
+
+func (r *RuleReconciler) getAllowListFromAnnotation(logger logr.Logger, gcpCluster *capg.GCPCluster) (cidr.AllowList, error) {
+	_, ok := gcpCluster.Annotations[AnnotationBastionAllowListSubnets]
 	if !ok {
 		logger.Info(fmt.Sprintf("Cluster does not have %q annotation. Using cloud default.", AnnotationBastionAllowListSubnets))
-		return nil, nil
+		return cidr.AllowList{}, nil
+	}
+
+	return r.parseAllowList(gcpCluster, AnnotationBastionAllowListSubnets)
+}
+
+// parseAllowList parses the allowlist annotation and records an event for
+// each entry that has expired since the annotation was last parsed.
+func (r *RuleReconciler) parseAllowList(gcpCluster *capg.GCPCluster, annotation string) (cidr.AllowList, error) {
+	allowList, err := cidr.ParseAllowList(gcpCluster.Annotations[annotation], time.Now())
+	if err != nil {
+		return cidr.AllowList{}, errors.WithStack(err)
 	}
 
-	return cidr.ParseFromCommaSeparated(annotation)
+	key := fmt.Sprintf("%s/%s/%s", gcpCluster.Namespace, gcpCluster.Name, annotation)
+	for _, entry := range r.expiredEntries.Observe(key, allowList.Expired) {
+		r.recorder.Eventf(gcpCluster, corev1.EventTypeNormal, EventReasonAllowListEntryExpired,
+			"Access from %q in annotation %q expired and was revoked", entry, annotation)
+	}
+
+	return allowList, nil
+}
+
+func toNamespacedName(cluster *capg.GCPCluster) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      cluster.Name,
+		Namespace: cluster.Namespace,
+	}
 }
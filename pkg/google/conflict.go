@@ -0,0 +1,57 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// MaxConflictAttempts is how often an update is computed and sent again
+// after GCP rejected it because the resource changed since it was read.
+const MaxConflictAttempts = 3
+
+// ConflictError is returned when a resource kept changing while it was being
+// updated, for example because a second operator replica or a human in the
+// console was editing it at the same time.
+type ConflictError struct {
+	Resource string
+	Attempts int
+	Err      error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s was modified concurrently %d times in a row: %v", e.Resource, e.Attempts, e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func IsConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+func IsPreconditionFailed(err error) bool {
+	return HasHttpCode(err, http.StatusPreconditionFailed)
+}
+
+// RetryOnPreconditionFailed calls update until it doesn't fail with
+// 412 Precondition Failed. update must read the resource again on every call,
+// so that the fingerprint it sends and the changes it computes are current.
+// A ConflictError is returned when all attempts conflicted.
+func RetryOnPreconditionFailed(resource string, attempts int, update func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		err = update()
+		if !IsPreconditionFailed(err) {
+			return err
+		}
+	}
+
+	return &ConflictError{
+		Resource: resource,
+		Attempts: attempts,
+		Err:      err,
+	}
+}
@@ -0,0 +1,152 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorKind is the class of a failed GCP API call. It decides whether the
+// call is retried and how long the controller waits before requeueing.
+type ErrorKind string
+
+const (
+	ErrorKindUnknown          ErrorKind = "Unknown"
+	ErrorKindRetryable        ErrorKind = "Retryable"
+	ErrorKindConflict         ErrorKind = "Conflict"
+	ErrorKindNotFound         ErrorKind = "NotFound"
+	ErrorKindQuota            ErrorKind = "Quota"
+	ErrorKindPermissionDenied ErrorKind = "PermissionDenied"
+	ErrorKindInvalid          ErrorKind = "Invalid"
+
+	reasonResourceNotReady      = "resourceNotReady"
+	reasonQuotaExceeded         = "quotaExceeded"
+	reasonRateLimitExceeded     = "rateLimitExceeded"
+	reasonUserRateLimitExceeded = "userRateLimitExceeded"
+)
+
+var errorKindDescriptions = map[ErrorKind]string{
+	ErrorKindRetryable:        "GCP API temporarily unavailable",
+	ErrorKindConflict:         "conflict",
+	ErrorKindNotFound:         "not found",
+	ErrorKindQuota:            "quota exceeded",
+	ErrorKindPermissionDenied: "permission denied",
+	ErrorKindInvalid:          "invalid request",
+}
+
+// Error is a classified GCP API error.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", errorKindDescriptions[e.Kind], e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the kind of a GCP API error. Both HTTP and gRPC errors
+// are classified. Errors that don't come from the GCP API, like validation
+// errors or canceled contexts, are ErrorKindUnknown.
+func Classify(err error) ErrorKind {
+	var classifiedErr *Error
+	if errors.As(err, &classifiedErr) {
+		return classifiedErr.Kind
+	}
+
+	if IsConflict(err) {
+		return ErrorKindConflict
+	}
+
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return classifyHTTPError(googleErr)
+	}
+
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) && grpcErr.GRPCStatus() != nil {
+		return classifyGRPCCode(grpcErr.GRPCStatus().Code())
+	}
+
+	return ErrorKindUnknown
+}
+
+func classifyHTTPError(googleErr *googleapi.Error) ErrorKind {
+	for _, item := range googleErr.Errors {
+		switch item.Reason {
+		case reasonQuotaExceeded, reasonRateLimitExceeded, reasonUserRateLimitExceeded:
+			return ErrorKindQuota
+		case reasonResourceNotReady:
+			return ErrorKindRetryable
+		}
+	}
+
+	switch googleErr.Code {
+	case http.StatusTooManyRequests:
+		return ErrorKindQuota
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrorKindRetryable
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return ErrorKindConflict
+	case http.StatusNotFound:
+		return ErrorKindNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrorKindPermissionDenied
+	case http.StatusBadRequest:
+		return ErrorKindInvalid
+	}
+
+	return ErrorKindUnknown
+}
+
+func classifyGRPCCode(code codes.Code) ErrorKind {
+	switch code {
+	case codes.ResourceExhausted:
+		return ErrorKindQuota
+	case codes.Unavailable, codes.Internal, codes.DeadlineExceeded:
+		return ErrorKindRetryable
+	case codes.Aborted, codes.AlreadyExists, codes.FailedPrecondition:
+		return ErrorKindConflict
+	case codes.NotFound:
+		return ErrorKindNotFound
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return ErrorKindPermissionDenied
+	case codes.InvalidArgument, codes.OutOfRange:
+		return ErrorKindInvalid
+	}
+
+	return ErrorKindUnknown
+}
+
+// IsRetryable returns true for errors that are expected to go away by
+// themselves, like unavailable backends and exhausted rate limits.
+func IsRetryable(err error) bool {
+	kind := Classify(err)
+	return kind == ErrorKindRetryable || kind == ErrorKindQuota
+}
+
+// ClassifyError wraps GCP API errors in an Error. Other errors, and errors
+// that are already typed, are returned unchanged.
+func ClassifyError(err error) error {
+	var classifiedErr *Error
+	if err == nil || errors.As(err, &classifiedErr) || IsConflict(err) {
+		return err
+	}
+
+	kind := Classify(err)
+	if kind == ErrorKindUnknown {
+		return err
+	}
+
+	return &Error{
+		Kind: kind,
+		Err:  err,
+	}
+}
@@ -0,0 +1,146 @@
+package google_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+)
+
+func newHTTPError(code int, reasons ...string) error {
+	items := []googleapi.ErrorItem{}
+	for _, reason := range reasons {
+		items = append(items, googleapi.ErrorItem{Reason: reason})
+	}
+
+	return &googleapi.Error{
+		Code:    code,
+		Message: http.StatusText(code),
+		Errors:  items,
+	}
+}
+
+var _ = Describe("Errors", func() {
+	DescribeTable("Classify",
+		func(err error, expected google.ErrorKind) {
+			Expect(google.Classify(err)).To(Equal(expected))
+		},
+		Entry("nil", nil, google.ErrorKindUnknown),
+		Entry("error not from the API", errors.New("boom"), google.ErrorKindUnknown),
+		Entry("canceled context", context.Canceled, google.ErrorKindUnknown),
+
+		Entry("400", newHTTPError(http.StatusBadRequest), google.ErrorKindInvalid),
+		Entry("401", newHTTPError(http.StatusUnauthorized), google.ErrorKindPermissionDenied),
+		Entry("403", newHTTPError(http.StatusForbidden), google.ErrorKindPermissionDenied),
+		Entry("404", newHTTPError(http.StatusNotFound), google.ErrorKindNotFound),
+		Entry("409", newHTTPError(http.StatusConflict), google.ErrorKindConflict),
+		Entry("412", newHTTPError(http.StatusPreconditionFailed), google.ErrorKindConflict),
+		Entry("429", newHTTPError(http.StatusTooManyRequests), google.ErrorKindQuota),
+		Entry("500", newHTTPError(http.StatusInternalServerError), google.ErrorKindRetryable),
+		Entry("502", newHTTPError(http.StatusBadGateway), google.ErrorKindRetryable),
+		Entry("503", newHTTPError(http.StatusServiceUnavailable), google.ErrorKindRetryable),
+		Entry("504", newHTTPError(http.StatusGatewayTimeout), google.ErrorKindRetryable),
+		Entry("501", newHTTPError(http.StatusNotImplemented), google.ErrorKindUnknown),
+
+		Entry("403 with reason quotaExceeded", newHTTPError(http.StatusForbidden, "quotaExceeded"), google.ErrorKindQuota),
+		Entry("403 with reason rateLimitExceeded", newHTTPError(http.StatusForbidden, "rateLimitExceeded"), google.ErrorKindQuota),
+		Entry("403 with reason userRateLimitExceeded", newHTTPError(http.StatusForbidden, "userRateLimitExceeded"), google.ErrorKindQuota),
+		Entry("400 with reason resourceNotReady", newHTTPError(http.StatusBadRequest, "resourceNotReady"), google.ErrorKindRetryable),
+		Entry("403 with other reason", newHTTPError(http.StatusForbidden, "forbidden"), google.ErrorKindPermissionDenied),
+		Entry("quota reason after other reason", newHTTPError(http.StatusForbidden, "forbidden", "quotaExceeded"), google.ErrorKindQuota),
+
+		Entry("wrapped HTTP error", fmt.Errorf("failed: %w", newHTTPError(http.StatusNotFound)), google.ErrorKindNotFound),
+
+		Entry("gRPC ResourceExhausted", status.Error(codes.ResourceExhausted, "quota"), google.ErrorKindQuota),
+		Entry("gRPC Unavailable", status.Error(codes.Unavailable, "unavailable"), google.ErrorKindRetryable),
+		Entry("gRPC Internal", status.Error(codes.Internal, "internal"), google.ErrorKindRetryable),
+		Entry("gRPC DeadlineExceeded", status.Error(codes.DeadlineExceeded, "deadline"), google.ErrorKindRetryable),
+		Entry("gRPC Aborted", status.Error(codes.Aborted, "aborted"), google.ErrorKindConflict),
+		Entry("gRPC AlreadyExists", status.Error(codes.AlreadyExists, "exists"), google.ErrorKindConflict),
+		Entry("gRPC FailedPrecondition", status.Error(codes.FailedPrecondition, "precondition"), google.ErrorKindConflict),
+		Entry("gRPC NotFound", status.Error(codes.NotFound, "not found"), google.ErrorKindNotFound),
+		Entry("gRPC PermissionDenied", status.Error(codes.PermissionDenied, "denied"), google.ErrorKindPermissionDenied),
+		Entry("gRPC Unauthenticated", status.Error(codes.Unauthenticated, "unauthenticated"), google.ErrorKindPermissionDenied),
+		Entry("gRPC InvalidArgument", status.Error(codes.InvalidArgument, "invalid"), google.ErrorKindInvalid),
+		Entry("gRPC Unknown", status.Error(codes.Unknown, "unknown"), google.ErrorKindUnknown),
+		Entry("wrapped gRPC error", fmt.Errorf("failed: %w", status.Error(codes.NotFound, "not found")), google.ErrorKindNotFound),
+
+		Entry("API error wrapping an HTTP error", newAPIError(newHTTPError(http.StatusServiceUnavailable)), google.ErrorKindRetryable),
+		Entry("API error wrapping a gRPC error", newAPIError(status.Error(codes.ResourceExhausted, "quota")), google.ErrorKindQuota),
+
+		Entry("classified error", &google.Error{Kind: google.ErrorKindQuota, Err: errors.New("boom")}, google.ErrorKindQuota),
+		Entry("conflict error", &google.ConflictError{Resource: "policy", Attempts: 3, Err: newHTTPError(http.StatusPreconditionFailed)}, google.ErrorKindConflict),
+	)
+
+	DescribeTable("IsRetryable",
+		func(err error, expected bool) {
+			Expect(google.IsRetryable(err)).To(Equal(expected))
+		},
+		Entry("nil", nil, false),
+		Entry("error not from the API", errors.New("boom"), false),
+		Entry("503", newHTTPError(http.StatusServiceUnavailable), true),
+		Entry("quota", newHTTPError(http.StatusForbidden, "quotaExceeded"), true),
+		Entry("gRPC Unavailable", status.Error(codes.Unavailable, "unavailable"), true),
+		Entry("404", newHTTPError(http.StatusNotFound), false),
+		Entry("412", newHTTPError(http.StatusPreconditionFailed), false),
+		Entry("400", newHTTPError(http.StatusBadRequest), false),
+	)
+
+	Describe("ClassifyError", func() {
+		It("returns nil for nil", func() {
+			Expect(google.ClassifyError(nil)).To(BeNil())
+		})
+
+		It("returns errors not from the API unchanged", func() {
+			err := errors.New("boom")
+			Expect(google.ClassifyError(err)).To(BeIdenticalTo(err))
+		})
+
+		It("returns conflict errors unchanged", func() {
+			err := &google.ConflictError{Resource: "policy", Attempts: 3, Err: newHTTPError(http.StatusPreconditionFailed)}
+			Expect(google.ClassifyError(err)).To(BeIdenticalTo(err))
+		})
+
+		It("returns classified errors unchanged", func() {
+			err := fmt.Errorf("failed: %w", &google.Error{Kind: google.ErrorKindQuota, Err: errors.New("boom")})
+			Expect(google.ClassifyError(err)).To(BeIdenticalTo(err))
+		})
+
+		It("wraps API errors", func() {
+			apiErr := newHTTPError(http.StatusForbidden)
+			err := google.ClassifyError(apiErr)
+
+			var classifiedErr *google.Error
+			Expect(errors.As(err, &classifiedErr)).To(BeTrue())
+			Expect(classifiedErr.Kind).To(Equal(google.ErrorKindPermissionDenied))
+			Expect(errors.Is(err, apiErr)).To(BeTrue())
+			Expect(google.HasHttpCode(err, http.StatusForbidden)).To(BeTrue())
+			Expect(err.Error()).To(HavePrefix("permission denied: "))
+		})
+
+		It("wraps gRPC errors", func() {
+			err := google.ClassifyError(status.Error(codes.NotFound, "not found"))
+
+			var classifiedErr *google.Error
+			Expect(errors.As(err, &classifiedErr)).To(BeTrue())
+			Expect(classifiedErr.Kind).To(Equal(google.ErrorKindNotFound))
+			Expect(status.Code(errors.Unwrap(err))).To(Equal(codes.NotFound))
+		})
+	})
+})
+
+func newAPIError(err error) error {
+	apiErr, ok := apierror.FromError(err)
+	Expect(ok).To(BeTrue())
+
+	return apiErr
+}
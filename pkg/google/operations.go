@@ -0,0 +1,164 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Collections of the resources GCP operations are tracked for. The Resource
+// of an OperationKey is the collection followed by / and the resource name.
+const (
+	CollectionFirewalls        = "firewalls"
+	CollectionSecurityPolicies = "securityPolicies"
+	CollectionBackendServices  = "backendServices"
+)
+
+// OperationKey identifies the resource a GCP operation changes and the
+// cluster it belongs to.
+type OperationKey struct {
+	Cluster  types.NamespacedName
+	Resource string
+}
+
+// OperationTracker keeps the GCP operations that were started but haven't
+// been seen to finish yet, so that reconciliations don't block a worker while
+// GCP applies a change. Clients poll the operation of a resource before
+// changing it again and the controller requeues clusters with running
+// operations.
+//
+// A nil OperationTracker waits for every operation to finish instead.
+type OperationTracker struct {
+	mu         sync.Mutex
+	operations map[OperationKey]*compute.Operation
+}
+
+func NewOperationTracker() *OperationTracker {
+	return &OperationTracker{
+		operations: map[OperationKey]*compute.Operation{},
+	}
+}
+
+// IsAsync returns false if operations are waited for when they are started.
+func (t *OperationTracker) IsAsync() bool {
+	return t != nil
+}
+
+// Start records a started operation. It returns true while the operation is
+// still running. A nil tracker waits for the operation and returns its error.
+func (t *OperationTracker) Start(ctx context.Context, key OperationKey, op *compute.Operation) (bool, error) {
+	if op == nil {
+		return false, nil
+	}
+
+	if t == nil {
+		err := op.Wait(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		return false, operationError(op)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.operations[key] = op
+	return true, nil
+}
+
+// Poll returns true while the operation started for the resource is still
+// running. When the operation has finished it is forgotten, and its error is
+// returned if it failed.
+func (t *OperationTracker) Poll(ctx context.Context, key OperationKey) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+
+	t.mu.Lock()
+	op, ok := t.operations[key]
+	t.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	err := op.Poll(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !op.Done() {
+		return true, nil
+	}
+
+	t.mu.Lock()
+	delete(t.operations, key)
+	t.mu.Unlock()
+
+	return false, operationError(op)
+}
+
+// HasPending returns true if operations of the cluster haven't been seen to
+// finish yet. When collections are given, like CollectionFirewalls, only
+// operations on resources of these collections are considered.
+func (t *OperationTracker) HasPending(cluster types.NamespacedName, collections ...string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.operations {
+		if key.Cluster == cluster && key.inCollections(collections) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k OperationKey) inCollections(collections []string) bool {
+	if len(collections) == 0 {
+		return true
+	}
+
+	for _, collection := range collections {
+		if strings.HasPrefix(k.Resource, collection+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// operationError turns the errors of a finished operation into a
+// googleapi.Error, so that it can be classified like the errors of API calls.
+func operationError(op *compute.Operation) error {
+	proto := op.Proto()
+	if proto.GetError() == nil || len(proto.GetError().GetErrors()) == 0 {
+		return nil
+	}
+
+	items := []googleapi.ErrorItem{}
+	messages := []string{}
+	for _, operationErr := range proto.GetError().GetErrors() {
+		items = append(items, googleapi.ErrorItem{
+			Reason:  operationErr.GetCode(),
+			Message: operationErr.GetMessage(),
+		})
+		messages = append(messages, operationErr.GetMessage())
+	}
+
+	return &googleapi.Error{
+		Code:    int(proto.GetHttpErrorStatusCode()),
+		Message: fmt.Sprintf("operation %s failed: %s", proto.GetName(), strings.Join(messages, ", ")),
+		Errors:  items,
+	}
+}
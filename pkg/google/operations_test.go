@@ -0,0 +1,277 @@
+package google_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+)
+
+const (
+	operationRunning = `{"name": "%s", "status": "RUNNING"}`
+	operationDone    = `{"name": "%s", "status": "DONE"}`
+	operationFailed  = `{
+		"name": "%s",
+		"status": "DONE",
+		"httpErrorStatusCode": 400,
+		"error": {"errors": [{"code": "INVALID_FIELD_VALUE", "message": "invalid source range"}]}
+	}`
+)
+
+// operationServer serves the compute API calls needed to start firewall
+// operations and poll them. Operations respond with the body set for their
+// name, which is formatted with the name.
+type operationServer struct {
+	*httptest.Server
+
+	mutex      sync.Mutex
+	operations map[string]string
+	polls      map[string]int
+}
+
+func newOperationServer() *operationServer {
+	server := &operationServer{
+		operations: map[string]string{},
+		polls:      map[string]int{},
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+
+	return server
+}
+
+func (s *operationServer) handle(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	if req.Method == http.MethodGet {
+		s.polls[name]++
+	}
+	if req.Method == http.MethodDelete {
+		name = "delete-" + name
+	}
+
+	body, ok := s.operations[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": {"code": 404, "message": "operation %s not found"}}`, name)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, body, name)
+}
+
+func (s *operationServer) setOperation(name, body string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.operations[name] = body
+}
+
+func (s *operationServer) removeOperation(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.operations, name)
+}
+
+func (s *operationServer) getPolls(name string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.polls[name]
+}
+
+var _ = Describe("OperationTracker", func() {
+	var (
+		ctx context.Context
+
+		server    *operationServer
+		firewalls *compute.FirewallsClient
+		tracker   *google.OperationTracker
+
+		cluster types.NamespacedName
+		key     google.OperationKey
+	)
+
+	// startOperation starts the deletion of a firewall rule, which the server
+	// answers with the operation delete-<rule>.
+	startOperation := func(rule string) *compute.Operation {
+		op, err := firewalls.Delete(ctx, &computepb.DeleteFirewallRequest{
+			Firewall: rule,
+			Project:  "the-project",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		return op
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		server = newOperationServer()
+		DeferCleanup(server.Close)
+
+		var err error
+		firewalls, err = compute.NewFirewallsRESTClient(ctx, option.WithEndpoint(server.URL), option.WithoutAuthentication())
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(firewalls.Close)
+
+		tracker = google.NewOperationTracker()
+
+		cluster = types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}
+		key = google.OperationKey{Cluster: cluster, Resource: google.CollectionFirewalls + "/the-rule"}
+
+		server.setOperation("delete-the-rule", operationRunning)
+	})
+
+	It("is async", func() {
+		Expect(tracker.IsAsync()).To(BeTrue())
+	})
+
+	It("ignores operations that weren't started", func() {
+		pending, err := tracker.Start(ctx, key, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeFalse())
+		Expect(tracker.HasPending(cluster)).To(BeFalse())
+	})
+
+	It("tracks started operations until they are seen to finish", func() {
+		pending, err := tracker.Start(ctx, key, startOperation("the-rule"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeTrue())
+		Expect(tracker.HasPending(cluster)).To(BeTrue())
+		Expect(server.getPolls("delete-the-rule")).To(Equal(0))
+
+		By("polling the running operation")
+		pending, err = tracker.Poll(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeTrue())
+		Expect(tracker.HasPending(cluster)).To(BeTrue())
+		Expect(server.getPolls("delete-the-rule")).To(Equal(1))
+
+		By("polling the finished operation")
+		server.setOperation("delete-the-rule", operationDone)
+		pending, err = tracker.Poll(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeFalse())
+		Expect(tracker.HasPending(cluster)).To(BeFalse())
+
+		By("polling again after the operation was forgotten")
+		pending, err = tracker.Poll(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeFalse())
+		Expect(server.getPolls("delete-the-rule")).To(Equal(2))
+	})
+
+	It("returns the error of failed operations once", func() {
+		_, err := tracker.Start(ctx, key, startOperation("the-rule"))
+		Expect(err).NotTo(HaveOccurred())
+
+		server.setOperation("delete-the-rule", operationFailed)
+		pending, err := tracker.Poll(ctx, key)
+		Expect(pending).To(BeFalse())
+		Expect(err).To(MatchError(ContainSubstring("invalid source range")))
+		Expect(google.HasHttpCode(err, http.StatusBadRequest)).To(BeTrue())
+		Expect(google.Classify(err)).To(Equal(google.ErrorKindInvalid))
+
+		var googleErr *googleapi.Error
+		Expect(errors.As(err, &googleErr)).To(BeTrue())
+		Expect(googleErr.Errors).To(ConsistOf(googleapi.ErrorItem{Reason: "INVALID_FIELD_VALUE", Message: "invalid source range"}))
+		Expect(tracker.HasPending(cluster)).To(BeFalse())
+
+		pending, err = tracker.Poll(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeFalse())
+	})
+
+	It("keeps operations that can't be polled", func() {
+		_, err := tracker.Start(ctx, key, startOperation("the-rule"))
+		Expect(err).NotTo(HaveOccurred())
+
+		server.removeOperation("delete-the-rule")
+
+		pending, err := tracker.Poll(ctx, key)
+		Expect(pending).To(BeFalse())
+		Expect(google.HasHttpCode(err, http.StatusNotFound)).To(BeTrue())
+		Expect(tracker.HasPending(cluster)).To(BeTrue())
+	})
+
+	Describe("HasPending", func() {
+		BeforeEach(func() {
+			server.setOperation("delete-other-rule", operationRunning)
+
+			_, err := tracker.Start(ctx, key, startOperation("the-rule"))
+			Expect(err).NotTo(HaveOccurred())
+
+			otherKey := google.OperationKey{
+				Cluster:  types.NamespacedName{Namespace: "the-namespace", Name: "other-cluster"},
+				Resource: google.CollectionBackendServices + "/other-rule",
+			}
+			_, err = tracker.Start(ctx, otherKey, startOperation("other-rule"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		DescribeTable("considers the operations of the cluster and collections",
+			func(cluster types.NamespacedName, collections []string, expected bool) {
+				Expect(tracker.HasPending(cluster, collections...)).To(Equal(expected))
+			},
+			Entry("any collection", types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}, nil, true),
+			Entry("collection of the operation", types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}, []string{google.CollectionFirewalls}, true),
+			Entry("several collections", types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}, []string{google.CollectionSecurityPolicies, google.CollectionFirewalls}, true),
+			Entry("other collection", types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}, []string{google.CollectionBackendServices}, false),
+			Entry("collection that is a prefix", types.NamespacedName{Namespace: "the-namespace", Name: "the-cluster"}, []string{"fire"}, false),
+			Entry("other cluster", types.NamespacedName{Namespace: "the-namespace", Name: "other-cluster"}, []string{google.CollectionBackendServices}, true),
+			Entry("cluster without operations", types.NamespacedName{Namespace: "the-namespace", Name: "idle-cluster"}, nil, false),
+		)
+	})
+
+	When("the tracker is nil", func() {
+		BeforeEach(func() {
+			tracker = nil
+		})
+
+		It("is not async", func() {
+			Expect(tracker.IsAsync()).To(BeFalse())
+		})
+
+		It("waits for the operation", func() {
+			server.setOperation("delete-the-rule", operationDone)
+
+			pending, err := tracker.Start(ctx, key, startOperation("the-rule"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(BeFalse())
+			Expect(server.getPolls("delete-the-rule")).To(Equal(1))
+			Expect(tracker.HasPending(cluster)).To(BeFalse())
+		})
+
+		It("returns the error of a failed operation", func() {
+			server.setOperation("delete-the-rule", operationFailed)
+
+			pending, err := tracker.Start(ctx, key, startOperation("the-rule"))
+			Expect(pending).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("invalid source range")))
+			Expect(google.HasHttpCode(err, http.StatusBadRequest)).To(BeTrue())
+		})
+
+		It("does not poll", func() {
+			pending, err := tracker.Poll(ctx, key)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(BeFalse())
+		})
+	})
+})
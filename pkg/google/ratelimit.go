@@ -0,0 +1,160 @@
+package google
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/option"
+	httptransport "google.golang.org/api/transport/http"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	DefaultRateLimitQPS   = 10
+	DefaultRateLimitBurst = 20
+
+	unknownLabelValue = "unknown"
+)
+
+var rateLimiterWaitSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "capg_firewall_rule_operator",
+		Subsystem: "gcp_rate_limiter",
+		Name:      "wait_seconds",
+		Help:      "Time GCP API requests waited for the client-side rate limiter.",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	},
+	[]string{"project", "api"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimiterWaitSeconds)
+}
+
+type rateLimitKey struct {
+	project string
+	api     string
+}
+
+// RateLimiter is a token bucket limiter for GCP API requests with a separate
+// bucket per project and API, like firewalls or securityPolicies, so that
+// clusters in one project don't use up the requests of another. It is shared
+// by all GCP clients of the operator.
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[rateLimitKey]*rate.Limiter
+}
+
+// NewRateLimiter creates a limiter allowing qps requests per second with
+// bursts of up to burst requests for every project and API.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limit:    rate.Limit(qps),
+		burst:    burst,
+		limiters: map[rateLimitKey]*rate.Limiter{},
+	}
+}
+
+// Transport returns a RoundTripper that waits for the limiter before sending
+// requests with next.
+func (l *RateLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	return &rateLimitedTransport{
+		limiter: l,
+		next:    next,
+	}
+}
+
+func (l *RateLimiter) getLimiter(key rateLimitKey) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[key] = limiter
+	}
+
+	return limiter
+}
+
+type rateLimitedTransport struct {
+	limiter *RateLimiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := getRateLimitKey(req.URL.Path)
+
+	start := time.Now()
+	err := t.limiter.getLimiter(key).Wait(req.Context())
+	rateLimiterWaitSeconds.WithLabelValues(key.project, key.api).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// getRateLimitKey gets the project and the resource collection from compute
+// API paths like /compute/v1/projects/<project>/global/firewalls/<name> or
+// /compute/v1/projects/<project>/regions/<region>/addresses.
+func getRateLimitKey(path string) rateLimitKey {
+	key := rateLimitKey{
+		project: unknownLabelValue,
+		api:     unknownLabelValue,
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	found := false
+	for i := 0; i < len(segments)-1; i++ {
+		if segments[i] == "projects" {
+			key.project = segments[i+1]
+			segments = segments[i+2:]
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return key
+	}
+
+	if len(segments) > 0 && segments[0] == "global" {
+		segments = segments[1:]
+	} else if len(segments) > 1 && (segments[0] == "regions" || segments[0] == "zones") {
+		segments = segments[2:]
+	}
+
+	if len(segments) > 0 && segments[0] != "" {
+		key.api = segments[0]
+	}
+
+	return key
+}
+
+// NewHTTPClient returns an authenticated HTTP client for the compute API that
+// waits for the limiter before every request, including operation polls.
+func NewHTTPClient(ctx context.Context, limiter *RateLimiter) (*http.Client, error) {
+	client, _, err := httptransport.NewClient(ctx, option.WithScopes(compute.DefaultAuthScopes()...))
+	if err != nil {
+		return nil, err
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &http.Client{
+		Transport: limiter.Transport(transport),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package google_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// getWaitHistogram returns the wait histogram of the rate limiter for the
+// project and API, or nil if nothing was observed for them.
+func getWaitHistogram(project, api string) *dto.Histogram {
+	families, err := metrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() != "capg_firewall_rule_operator_gcp_rate_limiter_wait_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["project"] == project && labels["api"] == api {
+				return metric.GetHistogram()
+			}
+		}
+	}
+
+	return nil
+}
+
+var _ = Describe("RateLimiter", func() {
+	var (
+		requests  int
+		transport http.RoundTripper
+	)
+
+	send := func(path string) {
+		req, err := http.NewRequest(http.MethodGet, "https://compute.googleapis.com"+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = transport.RoundTrip(req)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		requests = 0
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+		transport = google.NewRateLimiter(10, 1).Transport(next)
+	})
+
+	DescribeTable("records the wait per project and API",
+		func(path, expectedProject, expectedAPI string) {
+			before := uint64(0)
+			histogram := getWaitHistogram(expectedProject, expectedAPI)
+			if histogram != nil {
+				before = histogram.GetSampleCount()
+			}
+
+			send(path)
+
+			Expect(requests).To(Equal(1))
+			histogram = getWaitHistogram(expectedProject, expectedAPI)
+			Expect(histogram).NotTo(BeNil())
+			Expect(histogram.GetSampleCount()).To(Equal(before + 1))
+		},
+		Entry("global resources", "/compute/v1/projects/global-project/global/firewalls/the-rule", "global-project", "firewalls"),
+		Entry("global collections", "/compute/v1/projects/global-project/global/securityPolicies", "global-project", "securityPolicies"),
+		Entry("regional resources", "/compute/v1/projects/regional-project/regions/europe-west3/addresses/the-address", "regional-project", "addresses"),
+		Entry("zonal resources", "/compute/v1/projects/zonal-project/zones/europe-west3-a/operations/the-operation", "zonal-project", "operations"),
+		Entry("project resources", "/compute/v1/projects/the-project", "the-project", "unknown"),
+		Entry("paths without project", "/compute/v1/global/firewalls", "unknown", "unknown"),
+	)
+
+	It("waits for the limiter of the project and API", func() {
+		send("/compute/v1/projects/limited-project/global/firewalls")
+
+		start := time.Now()
+		send("/compute/v1/projects/limited-project/global/firewalls")
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+
+		histogram := getWaitHistogram("limited-project", "firewalls")
+		Expect(histogram).NotTo(BeNil())
+		Expect(histogram.GetSampleCount()).To(Equal(uint64(2)))
+		Expect(histogram.GetSampleSum()).To(BeNumerically(">=", 0.05))
+	})
+
+	It("does not wait for the limiters of other projects and APIs", func() {
+		send("/compute/v1/projects/first-project/global/firewalls")
+
+		start := time.Now()
+		send("/compute/v1/projects/second-project/global/firewalls")
+		send("/compute/v1/projects/first-project/global/securityPolicies")
+		Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+		Expect(requests).To(Equal(3))
+	})
+
+	It("fails when the request is canceled while waiting", func() {
+		send("/compute/v1/projects/canceled-project/global/firewalls")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://compute.googleapis.com/compute/v1/projects/canceled-project/global/firewalls", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = transport.RoundTrip(req)
+		Expect(err).To(HaveOccurred())
+		Expect(requests).To(Equal(1))
+	})
+})
@@ -0,0 +1,60 @@
+package google
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultRetryAttempts     = 5
+	DefaultRetryInitialDelay = time.Second
+	DefaultRetryMaxDelay     = time.Second * 30
+)
+
+// RetryConfig bounds the retries of GCP API calls. Attempts below one are
+// treated as a single attempt.
+type RetryConfig struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// Retry calls call until it succeeds, fails with an error that isn't
+// retryable or runs out of attempts. The delay doubles after every attempt
+// up to MaxDelay, and a random jitter of up to half the delay is taken off,
+// so that replicas and clusters failing at the same time don't retry in
+// lockstep. The returned error is classified with ClassifyError.
+func Retry(ctx context.Context, config RetryConfig, call func() error) error {
+	delay := config.InitialDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if err == nil || !IsRetryable(err) || attempt >= config.Attempts {
+			return ClassifyError(err)
+		}
+
+		timer := time.NewTimer(withJitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ClassifyError(err)
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > config.MaxDelay {
+			delay = config.MaxDelay
+		}
+	}
+}
+
+func withJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+
+	// #nosec G404 -- the jitter only spreads out retries
+	return delay - time.Duration(rand.Int63n(int64(delay)/2+1))
+}
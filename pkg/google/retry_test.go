@@ -0,0 +1,109 @@
+package google_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+)
+
+var _ = Describe("Retry", func() {
+	var (
+		ctx    context.Context
+		config google.RetryConfig
+		calls  int
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		config = google.RetryConfig{
+			Attempts:     3,
+			InitialDelay: time.Millisecond,
+			MaxDelay:     time.Millisecond * 2,
+		}
+		calls = 0
+	})
+
+	failWith := func(errs ...error) func() error {
+		return func() error {
+			calls++
+			if calls > len(errs) {
+				return nil
+			}
+
+			return errs[calls-1]
+		}
+	}
+
+	It("calls once when the call succeeds", func() {
+		Expect(google.Retry(ctx, config, failWith())).To(Succeed())
+		Expect(calls).To(Equal(1))
+	})
+
+	DescribeTable("retries retryable errors",
+		func(err error) {
+			Expect(google.Retry(ctx, config, failWith(err, err))).To(Succeed())
+			Expect(calls).To(Equal(3))
+		},
+		Entry("503", newHTTPError(http.StatusServiceUnavailable)),
+		Entry("429", newHTTPError(http.StatusTooManyRequests)),
+		Entry("quota reason", newHTTPError(http.StatusForbidden, "rateLimitExceeded")),
+		Entry("resource not ready", newHTTPError(http.StatusBadRequest, "resourceNotReady")),
+	)
+
+	DescribeTable("does not retry other errors",
+		func(err error, expectedKind google.ErrorKind) {
+			actualErr := google.Retry(ctx, config, failWith(err))
+			Expect(actualErr).To(HaveOccurred())
+			Expect(errors.Is(actualErr, err)).To(BeTrue())
+			Expect(google.Classify(actualErr)).To(Equal(expectedKind))
+			Expect(calls).To(Equal(1))
+		},
+		Entry("error not from the API", errors.New("boom"), google.ErrorKindUnknown),
+		Entry("400", newHTTPError(http.StatusBadRequest), google.ErrorKindInvalid),
+		Entry("403", newHTTPError(http.StatusForbidden), google.ErrorKindPermissionDenied),
+		Entry("404", newHTTPError(http.StatusNotFound), google.ErrorKindNotFound),
+		Entry("412", newHTTPError(http.StatusPreconditionFailed), google.ErrorKindConflict),
+	)
+
+	It("returns the classified error when it runs out of attempts", func() {
+		err := newHTTPError(http.StatusServiceUnavailable)
+		actualErr := google.Retry(ctx, config, failWith(err, err, err, err))
+
+		var classifiedErr *google.Error
+		Expect(errors.As(actualErr, &classifiedErr)).To(BeTrue())
+		Expect(classifiedErr.Kind).To(Equal(google.ErrorKindRetryable))
+		Expect(errors.Is(actualErr, err)).To(BeTrue())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("calls once when attempts are below one", func() {
+		config.Attempts = 0
+		err := newHTTPError(http.StatusServiceUnavailable)
+
+		Expect(google.Retry(ctx, config, failWith(err))).To(HaveOccurred())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("stops retrying when the context is canceled", func() {
+		config.InitialDelay = time.Hour
+		config.MaxDelay = time.Hour
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		err := newHTTPError(http.StatusServiceUnavailable)
+
+		actualErr := google.Retry(ctx, config, func() error {
+			calls++
+			cancel()
+			return err
+		})
+		Expect(errors.Is(actualErr, err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+	})
+})
@@ -0,0 +1,260 @@
+// Package ipfeed fetches the IP ranges of IPFeeds.
+package ipfeed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+)
+
+const (
+	// MaxFeedSize is the largest feed that is read. Larger feeds fail, so
+	// that a broken feed can't use up the memory of the operator.
+	MaxFeedSize = 10 * 1024 * 1024
+
+	// MaxFeedRanges is the largest number of ranges a feed may have after
+	// filtering. Larger feeds fail, since they would not fit into firewall
+	// rules and security policies anyway.
+	MaxFeedRanges = 5000
+
+	// DefaultTimeout is the timeout of the HTTP client fetching feeds.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Fetcher downloads feeds and parses their ranges.
+type Fetcher struct {
+	client *http.Client
+}
+
+func NewFetcher(client *http.Client) *Fetcher {
+	return &Fetcher{
+		client: client,
+	}
+}
+
+// Fetch downloads the feed and returns its normalized ranges. It fails when
+// the feed can't be downloaded or parsed, contains invalid ranges or has no
+// ranges left after filtering, so that the ranges of the last successful
+// fetch can be kept.
+func (f *Fetcher) Fetch(ctx context.Context, spec v1alpha1.IPFeedSpec) ([]string, error) {
+	content, err := f.download(ctx, spec.URL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var entries []string
+	switch spec.Format {
+	case v1alpha1.IPFeedFormatJSON:
+		entries, err = parseJSON(content, spec.JSONPath)
+	case v1alpha1.IPFeedFormatText, "":
+		entries, err = parseText(content)
+	default:
+		err = fmt.Errorf("unsupported format %q", spec.Format)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	entries, err = filter(entries, spec.Filter)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ranges, err := cidr.Normalize(entries, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "feed contains invalid ranges")
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("feed contains no ranges")
+	}
+
+	if len(ranges) > MaxFeedRanges {
+		return nil, fmt.Errorf("feed contains %d ranges, more than %d", len(ranges), MaxFeedRanges)
+	}
+
+	err = checkRanges(ranges)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return ranges, nil
+}
+
+// checkRanges rejects normalized ranges that allow all addresses of a family
+// and loopback ranges, so that a broken or compromised feed can't open the
+// allowlists referencing it.
+func checkRanges(ranges []string) error {
+	for _, value := range ranges {
+		if strings.HasSuffix(value, "/0") {
+			return fmt.Errorf("feed contains range %q, which allows all addresses", value)
+		}
+
+		if cidr.IsLoopback(value) {
+			return fmt.Errorf("feed contains loopback range %q", value)
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) download(ctx context.Context, url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("feed url must use https")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", response.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, MaxFeedSize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(content) > MaxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", MaxFeedSize)
+	}
+
+	return content, nil
+}
+
+// parseText returns the lines of the feed. Whitespace, empty lines and
+// comments starting with # are skipped.
+func parseText(content []byte) ([]string, error) {
+	entries := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	return entries, errors.WithStack(scanner.Err())
+}
+
+// parseJSON returns the strings selected by the JSONPath expression. Lists
+// of strings are flattened.
+func parseJSON(content []byte, expression string) ([]string, error) {
+	if expression == "" {
+		return nil, errors.New("json feeds require a JSONPath")
+	}
+
+	var document interface{}
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, errors.Wrap(err, "feed is not valid JSON")
+	}
+
+	if !strings.HasPrefix(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+
+	path := jsonpath.New("ipfeed")
+	err = path.Parse(expression)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid JSONPath %q", expression)
+	}
+
+	results, err := path.FindResults(document)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate JSONPath %q", expression)
+	}
+
+	entries := []string{}
+	for _, result := range results {
+		for _, value := range result {
+			values, err := toStrings(value.Interface())
+			if err != nil {
+				return nil, errors.Wrapf(err, "JSONPath %q", expression)
+			}
+			entries = append(entries, values...)
+		}
+	}
+
+	return entries, nil
+}
+
+func toStrings(value interface{}) ([]string, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return []string{typedValue}, nil
+	case []interface{}:
+		values := []string{}
+		for _, item := range typedValue {
+			itemValues, err := toStrings(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("selected value %v is not a string", value)
+}
+
+func filter(entries []string, feedFilter *v1alpha1.IPFeedFilter) ([]string, error) {
+	if feedFilter == nil {
+		return entries, nil
+	}
+
+	var pattern *regexp.Regexp
+	if feedFilter.Pattern != "" {
+		var err error
+		pattern, err = regexp.Compile(feedFilter.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid filter pattern %q", feedFilter.Pattern)
+		}
+	}
+
+	filtered := []string{}
+	for _, entry := range entries {
+		if pattern != nil && !pattern.MatchString(entry) {
+			continue
+		}
+
+		switch feedFilter.IPFamily {
+		case v1alpha1.IPFamilyIPv4:
+			if cidr.IsIPv6(entry) {
+				continue
+			}
+		case v1alpha1.IPFamilyIPv6:
+			if !cidr.IsIPv6(entry) {
+				continue
+			}
+		}
+
+		filtered = append(filtered, entry)
+	}
+
+	return filtered, nil
+}
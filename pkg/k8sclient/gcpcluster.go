@@ -32,6 +32,16 @@ func (g *GCPCluster) Get(ctx context.Context, namespacedName types.NamespacedNam
 	return gcpCluster, errors.WithStack(err)
 }
 
+func (g *GCPCluster) List(ctx context.Context) ([]capg.GCPCluster, error) {
+	gcpClusters := &capg.GCPClusterList{}
+	err := g.client.List(ctx, gcpClusters)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return gcpClusters.Items, nil
+}
+
 func (g *GCPCluster) GetOwner(ctx context.Context, capgCluster *capg.GCPCluster) (*capi.Cluster, error) {
 	cluster, err := util.GetOwnerCluster(ctx, g.client, capgCluster.ObjectMeta)
 	if err != nil {
@@ -41,14 +51,36 @@ func (g *GCPCluster) GetOwner(ctx context.Context, capgCluster *capg.GCPCluster)
 	return cluster, nil
 }
 
-func (g *GCPCluster) AddFinalizer(ctx context.Context, capgCluster *capg.GCPCluster, finalizer string) error {
+func (g *GCPCluster) AddFinalizer(ctx context.Context, capgCluster *capg.GCPCluster, finalizers ...string) error {
+	originalCluster := capgCluster.DeepCopy()
+	for _, finalizer := range finalizers {
+		controllerutil.AddFinalizer(capgCluster, finalizer)
+	}
+	return g.client.Patch(ctx, capgCluster, client.MergeFrom(originalCluster))
+}
+
+func (g *GCPCluster) RemoveFinalizer(ctx context.Context, capgCluster *capg.GCPCluster, finalizers ...string) error {
 	originalCluster := capgCluster.DeepCopy()
-	controllerutil.AddFinalizer(capgCluster, finalizer)
+	for _, finalizer := range finalizers {
+		controllerutil.RemoveFinalizer(capgCluster, finalizer)
+	}
 	return g.client.Patch(ctx, capgCluster, client.MergeFrom(originalCluster))
 }
 
-func (g *GCPCluster) RemoveFinalizer(ctx context.Context, capgCluster *capg.GCPCluster, finalizer string) error {
+// SetAnnotations sets the annotations to their values. Empty values remove
+// the annotation.
+func (g *GCPCluster) SetAnnotations(ctx context.Context, capgCluster *capg.GCPCluster, annotations map[string]string) error {
 	originalCluster := capgCluster.DeepCopy()
-	controllerutil.RemoveFinalizer(capgCluster, finalizer)
+	for key, value := range annotations {
+		if value == "" {
+			delete(capgCluster.Annotations, key)
+			continue
+		}
+
+		if capgCluster.Annotations == nil {
+			capgCluster.Annotations = map[string]string{}
+		}
+		capgCluster.Annotations[key] = value
+	}
 	return g.client.Patch(ctx, capgCluster, client.MergeFrom(originalCluster))
 }
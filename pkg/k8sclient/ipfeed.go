@@ -0,0 +1,38 @@
+package k8sclient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/api/v1alpha1"
+)
+
+type IPFeed struct {
+	client client.Client
+}
+
+func NewIPFeed(client client.Client) *IPFeed {
+	return &IPFeed{
+		client: client,
+	}
+}
+
+func (f *IPFeed) Get(ctx context.Context, namespacedName types.NamespacedName) (*v1alpha1.IPFeed, error) {
+	feed := &v1alpha1.IPFeed{}
+	err := f.client.Get(ctx, namespacedName, feed)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return feed, nil
+}
+
+// UpdateStatus patches the status of feed to status.
+func (f *IPFeed) UpdateStatus(ctx context.Context, feed *v1alpha1.IPFeed, status v1alpha1.IPFeedStatus) error {
+	originalFeed := feed.DeepCopy()
+	feed.Status = status
+	return errors.WithStack(f.client.Status().Patch(ctx, feed, client.MergeFrom(originalFeed)))
+}
@@ -8,6 +8,7 @@ import (
 	"google.golang.org/api/iterator"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
 	"k8s.io/apimachinery/pkg/types"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 
 	"github.com/pkg/errors"
 
@@ -15,11 +16,14 @@ import (
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/k8sclient"
 )
 
-func NewIPResolver(gcpClusters *k8sclient.GCPCluster, addresses *compute.AddressesClient, routers *compute.RoutersClient) *IPResolver {
+const IPVersionIPv6 = "IPV6"
+
+func NewIPResolver(gcpClusters *k8sclient.GCPCluster, addresses *compute.AddressesClient, routers *compute.RoutersClient, retryConfig google.RetryConfig) *IPResolver {
 	return &IPResolver{
 		gcpClusters: gcpClusters,
 		addresses:   addresses,
 		routers:     routers,
+		retryConfig: retryConfig,
 	}
 }
 
@@ -27,6 +31,7 @@ type IPResolver struct {
 	gcpClusters *k8sclient.GCPCluster
 	addresses   *compute.AddressesClient
 	routers     *compute.RoutersClient
+	retryConfig google.RetryConfig
 }
 
 func (r *IPResolver) GetIPs(ctx context.Context, managementCluster types.NamespacedName) ([]string, error) {
@@ -39,6 +44,23 @@ func (r *IPResolver) GetIPs(ctx context.Context, managementCluster types.Namespa
 		return nil, fmt.Errorf("cluster %s/%s does not have router yet", managementCluster.Namespace, managementCluster.Name)
 	}
 
+	var ips []string
+	err = google.Retry(ctx, r.retryConfig, func() error {
+		ips, err = r.getNATIPs(ctx, cluster)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("cluster %s/%s has no NAT IPs yet", cluster.Namespace, cluster.Name)
+	}
+
+	return ips, nil
+}
+
+func (r *IPResolver) getNATIPs(ctx context.Context, cluster *capg.GCPCluster) ([]string, error) {
 	getRouterReq := &computepb.GetRouterRequest{
 		Project: cluster.Spec.Project,
 		Region:  cluster.Spec.Region,
@@ -70,15 +92,21 @@ func (r *IPResolver) GetIPs(ctx context.Context, managementCluster types.Namespa
 		}
 
 		if contains(address.Users, *router.SelfLink) {
-			ips = append(ips, *address.Address)
+			ips = append(ips, formatAddress(address))
 		}
 	}
 
-	if len(ips) == 0 {
-		return nil, fmt.Errorf("cluster %s/%s has no NAT IPs yet", cluster.Namespace, cluster.Name)
+	return ips, nil
+}
+
+// formatAddress returns the address as a CIDR for IPv6 addresses, which GCP
+// reserves as a range, and the plain address for IPv4.
+func formatAddress(address *computepb.Address) string {
+	if address.GetIpVersion() == IPVersionIPv6 && address.PrefixLength != nil {
+		return fmt.Sprintf("%s/%d", address.GetAddress(), address.GetPrefixLength())
 	}
 
-	return ips, nil
+	return address.GetAddress()
 }
 
 func contains(slice []string, element string) bool {
@@ -0,0 +1,194 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/iterator"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DefaultCheckInterval is how often the GCP credentials and permissions
+	// are checked. The readiness probe returns the cached result.
+	DefaultCheckInterval = time.Minute
+
+	checkTimeout = time.Second * 30
+)
+
+var (
+	checkSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "capg_firewall_rule_operator",
+			Subsystem: "gcp_permission_check",
+			Name:      "success",
+			Help:      "Whether the operator could list the GCP resource in the project during the last check.",
+		},
+		[]string{"project", "resource"},
+	)
+	checkTimestampSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "capg_firewall_rule_operator",
+			Subsystem: "gcp_permission_check",
+			Name:      "timestamp_seconds",
+			Help:      "Time of the last check of the GCP credentials and permissions.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(checkSuccess, checkTimestampSeconds)
+}
+
+// ProjectFunc returns the GCP project the permissions are checked in.
+type ProjectFunc func(context.Context) (string, error)
+
+type resourceCheck func(ctx context.Context, project string) error
+
+// GCPChecker periodically checks that the operator credentials can reach the
+// Compute API and list the resources it manages or reads: firewalls,
+// security policies, backend services, routers and addresses. Listing is the
+// cheapest call that fails on missing permissions or expired credentials.
+// It does not prove write permissions, but catches a missing or wrong role.
+type GCPChecker struct {
+	getProject ProjectFunc
+	checks     map[string]resourceCheck
+	interval   time.Duration
+
+	mutex   sync.RWMutex
+	checked bool
+	err     error
+}
+
+func NewGCPChecker(
+	getProject ProjectFunc,
+	firewalls *compute.FirewallsClient,
+	securityPolicies *compute.SecurityPoliciesClient,
+	backendServices *compute.BackendServicesClient,
+	routers *compute.RoutersClient,
+	addresses *compute.AddressesClient,
+	interval time.Duration,
+) *GCPChecker {
+	return &GCPChecker{
+		getProject: getProject,
+		interval:   interval,
+		checks: map[string]resourceCheck{
+			"firewalls": func(ctx context.Context, project string) error {
+				_, err := firewalls.List(ctx, &computepb.ListFirewallsRequest{Project: project, MaxResults: maxResults()}).Next()
+				return err
+			},
+			"securityPolicies": func(ctx context.Context, project string) error {
+				_, err := securityPolicies.List(ctx, &computepb.ListSecurityPoliciesRequest{Project: project, MaxResults: maxResults()}).Next()
+				return err
+			},
+			"backendServices": func(ctx context.Context, project string) error {
+				_, err := backendServices.List(ctx, &computepb.ListBackendServicesRequest{Project: project, MaxResults: maxResults()}).Next()
+				return err
+			},
+			"routers": func(ctx context.Context, project string) error {
+				_, err := routers.AggregatedList(ctx, &computepb.AggregatedListRoutersRequest{Project: project, MaxResults: maxResults()}).Next()
+				return err
+			},
+			"addresses": func(ctx context.Context, project string) error {
+				_, err := addresses.AggregatedList(ctx, &computepb.AggregatedListAddressesRequest{Project: project, MaxResults: maxResults()}).Next()
+				return err
+			},
+		},
+	}
+}
+
+// Check returns the result of the last check. It implements healthz.Checker.
+func (c *GCPChecker) Check(_ *http.Request) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if !c.checked {
+		return errors.New("GCP credentials and permissions have not been checked yet")
+	}
+
+	return c.err
+}
+
+// Start checks the credentials and permissions until the context is
+// cancelled. It implements manager.Runnable.
+func (c *GCPChecker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.check(ctx)
+
+		c.mutex.Lock()
+		c.checked = true
+		c.err = err
+		c.mutex.Unlock()
+
+		if err != nil {
+			log.FromContext(ctx).WithName("gcp-readiness-check").Error(err, "GCP credentials or permissions check failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false, so that replicas waiting for the leader
+// lease report their readiness too.
+func (c *GCPChecker) NeedLeaderElection() bool {
+	return false
+}
+
+func (c *GCPChecker) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	defer checkTimestampSeconds.SetToCurrentTime()
+
+	project, err := c.getProject(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get GCP project")
+	}
+
+	failed := []string{}
+	for resource, check := range c.checks {
+		err := check(ctx, project)
+		if err == iterator.Done {
+			err = nil
+		}
+
+		if err != nil {
+			checkSuccess.WithLabelValues(project, resource).Set(0)
+			failed = append(failed, fmt.Sprintf("%s: %s", resource, err))
+			continue
+		}
+
+		checkSuccess.WithLabelValues(project, resource).Set(1)
+	}
+
+	if len(failed) != 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to list GCP resources in project %s: %s", project, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// maxResults limits the list calls to a single result, since only their
+// success matters.
+func maxResults() *uint32 {
+	maxResults := uint32(1)
+	return &maxResults
+}
@@ -0,0 +1,390 @@
+package security
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/giantswarm/to"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+)
+
+const (
+	// UpdateModeInPlace updates the rules of the attached security policy.
+	UpdateModeInPlace = "in-place"
+	// UpdateModeBlueGreen replaces the attached security policy with a new
+	// version.
+	UpdateModeBlueGreen = "blue-green"
+
+	versionHashLength = 8
+)
+
+// BlueGreenClient replaces security policies instead of updating their
+// rules one at a time. Each version of a policy is named after the hash of
+// its content. The new version is attached to the backend service before the
+// previous version is deleted, so the backend service always has a complete
+// policy attached. Break-glass rules of the attached version are copied to
+// the new version when the policy doesn't have them anymore.
+//
+// When operations are tracked, the attachments that are still running are
+// kept in memory, so that a failed attachment can be rolled back once it is
+// polled. Previous versions are only swept once after the operator starts and
+// after each attachment.
+type BlueGreenClient struct {
+	client   *Client
+	policies SecurityPolicyGetter
+
+	mutex       sync.Mutex
+	attachments map[types.NamespacedName]attachment
+	swept       map[types.NamespacedName]bool
+}
+
+//counterfeiter:generate . SecurityPolicyGetter
+type SecurityPolicyGetter interface {
+	GetPolicy(context.Context, *capg.GCPCluster, string) (*computepb.SecurityPolicy, error)
+}
+
+// attachment is a version of a policy that is being attached to the backend
+// service of a cluster in place of the previously attached policy.
+type attachment struct {
+	version          string
+	previousSelfLink *string
+}
+
+func NewBlueGreenClient(securityPolicies *compute.SecurityPoliciesClient, backendServices *compute.BackendServicesClient, retryConfig google.RetryConfig, operations *google.OperationTracker) *BlueGreenClient {
+	client := NewClient(securityPolicies, backendServices, retryConfig, operations)
+
+	return &BlueGreenClient{
+		client:      client,
+		policies:    client,
+		attachments: map[types.NamespacedName]attachment{},
+		swept:       map[types.NamespacedName]bool{},
+	}
+}
+
+func (c *BlueGreenClient) ApplyPolicy(ctx context.Context, cluster *capg.GCPCluster, policy Policy) error {
+	return google.Retry(ctx, c.client.retryConfig, func() error {
+		return c.applyPolicy(ctx, cluster, policy)
+	})
+}
+
+func (c *BlueGreenClient) applyPolicy(ctx context.Context, cluster *capg.GCPCluster, policy Policy) error {
+	logger := c.client.getLogger(ctx, policy.Name)
+
+	logger.Info("Applying versioned security policy")
+	defer logger.Info("Done applying versioned security policy")
+
+	if google.IsNilOrEmpty(cluster.Status.Network.APIServerBackendService) {
+		return errors.New("cluster does not have backend service")
+	}
+
+	err := validatePolicy(policy)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	previousSelfLink, err := c.client.getAttachedPolicy(ctx, cluster)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	versionedPolicy, err := c.keepBreakGlassRules(ctx, cluster, policy, previousSelfLink)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	versionedPolicy.Name, err = getVersionedPolicyName(versionedPolicy)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	pending, err := c.client.pollOperations(ctx, getPolicyOperationKey(cluster, versionedPolicy.Name))
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	pending, err = c.pollAttachment(ctx, logger, cluster)
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	if google.IsNilOrEmpty(previousSelfLink) || google.GetResourceName(*previousSelfLink) != versionedPolicy.Name {
+		pending, err = c.attachVersion(ctx, logger, cluster, versionedPolicy, previousSelfLink)
+		if err != nil || pending {
+			return errors.WithStack(err)
+		}
+	}
+
+	if c.isSwept(toNamespacedName(cluster)) {
+		return nil
+	}
+
+	pending, err = c.deleteVersions(ctx, cluster, policy.Name, versionedPolicy.Name)
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	c.setSwept(toNamespacedName(cluster), true)
+	return nil
+}
+
+// DeletePolicy detaches the policy from the backend service and deletes all
+// versions of it.
+func (c *BlueGreenClient) DeletePolicy(ctx context.Context, cluster *capg.GCPCluster, name string) error {
+	return google.Retry(ctx, c.client.retryConfig, func() error {
+		return c.deletePolicy(ctx, cluster, name)
+	})
+}
+
+func (c *BlueGreenClient) deletePolicy(ctx context.Context, cluster *capg.GCPCluster, name string) error {
+	logger := c.client.getLogger(ctx, name)
+
+	logger.Info("Deleting security policy versions")
+	defer logger.Info("Done deleting security policy versions")
+
+	pending, err := c.client.detachSecurityPolicy(ctx, cluster, func(attachedName string) bool {
+		return isPolicyVersion(attachedName, name)
+	})
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	c.takeAttachment(toNamespacedName(cluster))
+	c.setSwept(toNamespacedName(cluster), false)
+
+	_, err = c.deleteVersions(ctx, cluster, name, "")
+	return errors.WithStack(err)
+}
+
+// attachVersion creates the versioned policy and attaches it to the backend
+// service. It returns true while either is still in progress. If attaching
+// fails the previous policy is attached again and the new version is
+// deleted. When the attachment is still running, this happens once
+// pollAttachment sees it fail.
+func (c *BlueGreenClient) attachVersion(ctx context.Context, logger logr.Logger, cluster *capg.GCPCluster, policy Policy, previousSelfLink *string) (bool, error) {
+	securityPolicy, pending, err := c.client.createSecurityPolicy(ctx, cluster, toGCPSecurityPolicy(cluster, policy))
+	if google.HasHttpCode(err, http.StatusConflict) {
+		logger.Info("Security policy version already exists")
+		securityPolicy, err = c.client.getSecurityPolicy(ctx, cluster, policy.Name)
+	}
+	if err != nil || pending {
+		return pending, errors.WithStack(err)
+	}
+
+	newAttachment := attachment{
+		version:          policy.Name,
+		previousSelfLink: previousSelfLink,
+	}
+
+	pending, err = c.client.setSecurityPolicy(ctx, cluster, securityPolicy.SelfLink)
+	if err != nil {
+		return false, c.rollBack(ctx, logger, cluster, newAttachment, err)
+	}
+
+	if pending {
+		c.mutex.Lock()
+		c.attachments[toNamespacedName(cluster)] = newAttachment
+		c.mutex.Unlock()
+		return true, nil
+	}
+
+	c.setSwept(toNamespacedName(cluster), false)
+	return false, nil
+}
+
+// pollAttachment returns true while the operation on the backend service is
+// still running. When it attached a version of the policy and failed, the
+// attachment is rolled back.
+func (c *BlueGreenClient) pollAttachment(ctx context.Context, logger logr.Logger, cluster *capg.GCPCluster) (bool, error) {
+	pending, err := c.client.pollOperations(ctx, getBackendServiceOperationKey(cluster))
+	if pending {
+		return true, nil
+	}
+
+	// The operation is still tracked when it couldn't be polled, in which
+	// case it may still succeed.
+	if c.client.operations.HasPending(toNamespacedName(cluster), google.CollectionBackendServices) {
+		return false, errors.WithStack(err)
+	}
+
+	runningAttachment, ok := c.takeAttachment(toNamespacedName(cluster))
+	if !ok {
+		return false, errors.WithStack(err)
+	}
+
+	if err != nil {
+		return false, c.rollBack(ctx, logger, cluster, runningAttachment, err)
+	}
+
+	c.setSwept(toNamespacedName(cluster), false)
+	return false, nil
+}
+
+// takeAttachment returns the running attachment of the cluster and forgets
+// it.
+func (c *BlueGreenClient) takeAttachment(cluster types.NamespacedName) (attachment, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	runningAttachment, ok := c.attachments[cluster]
+	delete(c.attachments, cluster)
+	return runningAttachment, ok
+}
+
+func (c *BlueGreenClient) isSwept(cluster types.NamespacedName) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.swept[cluster]
+}
+
+func (c *BlueGreenClient) setSwept(cluster types.NamespacedName, swept bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if swept {
+		c.swept[cluster] = true
+		return
+	}
+
+	delete(c.swept, cluster)
+}
+
+// rollBack attaches the previous policy again and deletes the version that
+// failed to be attached. The error of the attachment is returned.
+func (c *BlueGreenClient) rollBack(ctx context.Context, logger logr.Logger, cluster *capg.GCPCluster, failedAttachment attachment, err error) error {
+	logger.Error(err, "Failed to attach security policy version. Rolling back")
+
+	_, rollbackErr := c.client.setSecurityPolicy(ctx, cluster, failedAttachment.previousSelfLink)
+	if rollbackErr != nil {
+		return errors.Wrapf(err, "failed to roll back to previous security policy: %s", rollbackErr)
+	}
+
+	_, rollbackErr = c.client.deleteSecurityPolicy(ctx, cluster, failedAttachment.version)
+	if rollbackErr != nil {
+		return errors.Wrapf(err, "failed to delete security policy version: %s", rollbackErr)
+	}
+
+	return errors.WithStack(err)
+}
+
+// keepBreakGlassRules adds the break-glass rules of the attached version of
+// the policy that are missing from the policy, so that like in-place updates,
+// new versions never remove them. The policy is returned unchanged when no
+// version of it is attached.
+func (c *BlueGreenClient) keepBreakGlassRules(ctx context.Context, cluster *capg.GCPCluster, policy Policy, attachedSelfLink *string) (Policy, error) {
+	if google.IsNilOrEmpty(attachedSelfLink) || !isPolicyVersion(google.GetResourceName(*attachedSelfLink), policy.Name) {
+		return policy, nil
+	}
+
+	attachedPolicy, err := c.policies.GetPolicy(ctx, cluster, google.GetResourceName(*attachedSelfLink))
+	if err != nil {
+		return Policy{}, errors.WithStack(err)
+	}
+
+	priorities := map[int32]bool{}
+	for _, rule := range policy.Rules {
+		priorities[rule.Priority] = true
+	}
+
+	rules := append([]PolicyRule{}, policy.Rules...)
+	for _, rule := range attachedPolicy.Rules {
+		if !isBreakGlassRule(rule) || priorities[rule.GetPriority()] {
+			continue
+		}
+
+		rules = append(rules, PolicyRule{
+			Action:         rule.GetAction(),
+			Description:    rule.GetDescription(),
+			SourceIPRanges: rule.GetMatch().GetConfig().GetSrcIpRanges(),
+			Priority:       rule.GetPriority(),
+		})
+	}
+
+	policy.Rules = rules
+	return policy, nil
+}
+
+// deleteVersions deletes the unversioned policy and all versions of it,
+// except for the version to keep. It returns true while a deletion is still
+// running.
+func (c *BlueGreenClient) deleteVersions(ctx context.Context, cluster *capg.GCPCluster, name, keep string) (bool, error) {
+	req := &computepb.ListSecurityPoliciesRequest{
+		Filter:  to.StringP(getPolicyVersionsFilter(name)),
+		Project: cluster.Spec.Project,
+	}
+	policies := c.client.securityPolicies.List(ctx, req)
+
+	anyPending := false
+	for {
+		policy, err := policies.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		policyName := policy.GetName()
+		if policyName == keep || !isPolicyVersion(policyName, name) {
+			continue
+		}
+
+		pending, err := c.client.deleteSecurityPolicy(ctx, cluster, policyName)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		anyPending = anyPending || pending
+	}
+
+	return anyPending, nil
+}
+
+// getPolicyVersionsFilter returns the list filter for the unversioned policy
+// and all versions of it. GCP matches the value of eq as a regular
+// expression against the whole name.
+func getPolicyVersionsFilter(name string) string {
+	return fmt.Sprintf(`name eq "%s(-[0-9a-f]{%d})?"`, regexp.QuoteMeta(name), versionHashLength)
+}
+
+// getVersionedPolicyName appends the hash of the policy content to its name,
+// so that any change to the policy results in a new version.
+func getVersionedPolicyName(policy Policy) (string, error) {
+	content, err := json.Marshal(policy)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	hash := sha256.Sum256(content)
+	return fmt.Sprintf("%s-%s", policy.Name, hex.EncodeToString(hash[:])[:versionHashLength]), nil
+}
+
+func isPolicyVersion(policyName, name string) bool {
+	if policyName == name {
+		return true
+	}
+
+	hash := strings.TrimPrefix(policyName, name+"-")
+	if hash == policyName || len(hash) != versionHashLength {
+		return false
+	}
+
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,221 @@
+package security_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/giantswarm/to"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security/securityfakes"
+)
+
+var _ = Describe("BlueGreenClient", func() {
+	const name = "allow-the-cluster-apiserver"
+
+	newPolicy := func() security.Policy {
+		return security.Policy{
+			Name:          name,
+			Description:   "allow IPs to connect to kubernetes api",
+			DefaultAction: security.ActionDeny403,
+			Rules: []security.PolicyRule{
+				{
+					Action:         security.ActionAllow,
+					Description:    "allow user specified ips to connect to kubernetes api",
+					SourceIPRanges: []string{"10.0.0.0/24"},
+					Priority:       1,
+				},
+			},
+		}
+	}
+
+	Describe("getVersionedPolicyName", func() {
+		It("appends a hash to the name", func() {
+			versionedName, err := security.GetVersionedPolicyName(newPolicy())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versionedName).To(MatchRegexp(`^allow-the-cluster-apiserver-[0-9a-f]{8}$`))
+			Expect(security.IsPolicyVersion(versionedName, name)).To(BeTrue())
+		})
+
+		It("returns the same name for the same policy", func() {
+			first, err := security.GetVersionedPolicyName(newPolicy())
+			Expect(err).NotTo(HaveOccurred())
+
+			second, err := security.GetVersionedPolicyName(newPolicy())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first))
+		})
+
+		DescribeTable("returns a new name when the policy changes",
+			func(modify func(*security.Policy)) {
+				original, err := security.GetVersionedPolicyName(newPolicy())
+				Expect(err).NotTo(HaveOccurred())
+
+				policy := newPolicy()
+				modify(&policy)
+				changed, err := security.GetVersionedPolicyName(policy)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(changed).NotTo(Equal(original))
+			},
+			Entry("changed ranges", func(p *security.Policy) { p.Rules[0].SourceIPRanges = []string{"10.0.1.0/24"} }),
+			Entry("changed priority", func(p *security.Policy) { p.Rules[0].Priority = 2 }),
+			Entry("added rule", func(p *security.Policy) {
+				p.Rules = append(p.Rules, security.PolicyRule{Action: security.ActionAllow, SourceIPRanges: []string{"10.0.1.0/24"}, Priority: 2})
+			}),
+			Entry("changed default action", func(p *security.Policy) { p.DefaultAction = security.ActionAllow }),
+			Entry("changed log level", func(p *security.Policy) { p.LogLevel = security.LogLevelVerbose }),
+		)
+	})
+
+	DescribeTable("isPolicyVersion",
+		func(policyName string, expected bool) {
+			Expect(security.IsPolicyVersion(policyName, name)).To(Equal(expected))
+		},
+		Entry("unversioned policy", name, true),
+		Entry("version", name+"-0123abcd", true),
+		Entry("other policy", "allow-other-cluster-apiserver", false),
+		Entry("version of other policy", "allow-other-cluster-apiserver-0123abcd", false),
+		Entry("policy of a cluster with a longer name", name+"-2", false),
+		Entry("hash that is too short", name+"-0123abc", false),
+		Entry("hash that is too long", name+"-0123abcde", false),
+		Entry("hash that isn't hex", name+"-0123abcx", false),
+		Entry("prefix of the name", "allow-the-cluster", false),
+	)
+
+	DescribeTable("getPolicyVersionsFilter",
+		func(policyName string, expected bool) {
+			filter := security.GetPolicyVersionsFilter(name)
+			Expect(filter).To(HavePrefix(`name eq "`))
+
+			expression := regexp.MustCompile("^" + filter[len(`name eq "`):len(filter)-1] + "$")
+			Expect(expression.MatchString(policyName)).To(Equal(expected))
+		},
+		Entry("unversioned policy", name, true),
+		Entry("version", name+"-0123abcd", true),
+		Entry("other policy", "allow-other-cluster-apiserver", false),
+		Entry("policy of a cluster with a longer name", name+"-2", false),
+		Entry("hash that is too long", name+"-0123abcde", false),
+	)
+
+	Describe("keepBreakGlassRules", func() {
+		var (
+			ctx context.Context
+
+			policies *securityfakes.FakeSecurityPolicyGetter
+			client   *security.BlueGreenClient
+			cluster  *capg.GCPCluster
+			policy   security.Policy
+
+			attachedName     string
+			attachedSelfLink *string
+		)
+
+		breakGlassRule := &computepb.SecurityPolicyRule{
+			Action:      to.StringP(security.ActionAllow),
+			Description: to.StringP(security.BreakGlassRuleDescription),
+			Match: &computepb.SecurityPolicyRuleMatcher{
+				Config: &computepb.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"192.0.2.0/24"}},
+			},
+			Priority: to.Int32P(security.BreakGlassRulePriority),
+		}
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			policies = new(securityfakes.FakeSecurityPolicyGetter)
+			client = security.NewBlueGreenClientWithGetter(policies)
+			cluster = &capg.GCPCluster{}
+			policy = newPolicy()
+
+			attachedName = name + "-0123abcd"
+			attachedSelfLink = to.StringP("https://www.googleapis.com/compute/v1/projects/project/global/securityPolicies/" + attachedName)
+
+			policies.GetPolicyReturns(&computepb.SecurityPolicy{
+				Name: to.StringP(attachedName),
+				Rules: []*computepb.SecurityPolicyRule{
+					breakGlassRule,
+					{
+						Action:      to.StringP(security.ActionAllow),
+						Description: to.StringP("allow user specified ips to connect to kubernetes api"),
+						Match: &computepb.SecurityPolicyRuleMatcher{
+							Config: &computepb.SecurityPolicyRuleMatcherConfig{SrcIpRanges: []string{"10.1.0.0/24"}},
+						},
+						Priority: to.Int32P(1),
+					},
+				},
+			}, nil)
+		})
+
+		It("adds the break-glass rules of the attached version", func() {
+			actualPolicy, err := client.KeepBreakGlassRules(ctx, cluster, policy, attachedSelfLink)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(policies.GetPolicyCallCount()).To(Equal(1))
+			_, actualCluster, actualName := policies.GetPolicyArgsForCall(0)
+			Expect(actualCluster).To(Equal(cluster))
+			Expect(actualName).To(Equal(attachedName))
+
+			Expect(actualPolicy.Rules).To(Equal(append(newPolicy().Rules, security.PolicyRule{
+				Action:         security.ActionAllow,
+				Description:    security.BreakGlassRuleDescription,
+				SourceIPRanges: []string{"192.0.2.0/24"},
+				Priority:       security.BreakGlassRulePriority,
+			})))
+		})
+
+		When("the policy has a rule with the priority of the break-glass rule", func() {
+			BeforeEach(func() {
+				policy.Rules = append(policy.Rules, security.PolicyRule{
+					Action:         security.ActionAllow,
+					Description:    security.BreakGlassRuleDescription,
+					SourceIPRanges: []string{"198.51.100.0/24"},
+					Priority:       security.BreakGlassRulePriority,
+				})
+			})
+
+			It("keeps the rule of the policy", func() {
+				actualPolicy, err := client.KeepBreakGlassRules(ctx, cluster, policy, attachedSelfLink)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualPolicy).To(Equal(policy))
+			})
+		})
+
+		When("no policy is attached", func() {
+			It("returns the policy unchanged", func() {
+				actualPolicy, err := client.KeepBreakGlassRules(ctx, cluster, policy, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualPolicy).To(Equal(policy))
+				Expect(policies.GetPolicyCallCount()).To(Equal(0))
+			})
+		})
+
+		When("another policy is attached", func() {
+			BeforeEach(func() {
+				attachedSelfLink = to.StringP("https://www.googleapis.com/compute/v1/projects/project/global/securityPolicies/other-policy")
+			})
+
+			It("returns the policy unchanged", func() {
+				actualPolicy, err := client.KeepBreakGlassRules(ctx, cluster, policy, attachedSelfLink)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualPolicy).To(Equal(policy))
+				Expect(policies.GetPolicyCallCount()).To(Equal(0))
+			})
+		})
+
+		When("getting the attached version fails", func() {
+			BeforeEach(func() {
+				policies.GetPolicyReturns(nil, errors.New("boom"))
+			})
+
+			It("returns an error", func() {
+				_, err := client.KeepBreakGlassRules(ctx, cluster, policy, attachedSelfLink)
+				Expect(err).To(MatchError(ContainSubstring("boom")))
+			})
+		})
+	})
+})
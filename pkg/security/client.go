@@ -2,6 +2,7 @@ package security
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"net/http"
 
@@ -9,7 +10,9 @@ import (
 	"github.com/giantswarm/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -25,13 +28,34 @@ const (
 	DefaultRuleDescription = "Default rule, higher priority overrides it"
 	DefaultRuleIPRanges    = "*"
 	DefaultRulePriority    = int32(math.MaxInt32)
+
+	// BreakGlassRulePriority is the highest priority, so that the break-glass
+	// rule is evaluated before any other rule.
+	BreakGlassRulePriority    = int32(0)
+	BreakGlassRuleDescription = "Break-glass access, never removed by the operator"
+
+	// The NAT rules allow the management and workload clusters to reach the
+	// kubernetes api. They don't count as access for anyone else.
+	MCNATRuleDescription = "allow MC NAT IPs"
+	WCNATRuleDescription = "allow WC NAT IPs"
+
+	// TemporaryRulePriorityOffset is added to the priority of a changed allow
+	// rule to get the priority of its copy while the policy is updated.
+	TemporaryRulePriorityOffset = int32(1000000)
+	TemporaryRuleDescription    = "Temporary copy while the policy is updated"
+
+	LogLevelNormal  = "NORMAL"
+	LogLevelVerbose = "VERBOSE"
 )
 
 type Policy struct {
 	Name          string
 	Description   string
 	DefaultAction string
-	Rules         []PolicyRule
+	// LogLevel sets the Cloud Armor log level. It is left unchanged in GCP
+	// when empty.
+	LogLevel string
+	Rules    []PolicyRule
 }
 
 type PolicyRule struct {
@@ -44,16 +68,34 @@ type PolicyRule struct {
 type Client struct {
 	securityPolicies *compute.SecurityPoliciesClient
 	backendServices  *compute.BackendServicesClient
+	retryConfig      google.RetryConfig
+	operations       *google.OperationTracker
 }
 
-func NewClient(securityPolicies *compute.SecurityPoliciesClient, backendServices *compute.BackendServicesClient) *Client {
+// NewClient creates a security policy client. Operations are tracked by
+// operations instead of being waited for, unless it is nil. A policy update
+// then makes one change per call and the next call continues from the state
+// GCP reports.
+func NewClient(securityPolicies *compute.SecurityPoliciesClient, backendServices *compute.BackendServicesClient, retryConfig google.RetryConfig, operations *google.OperationTracker) *Client {
 	return &Client{
 		securityPolicies: securityPolicies,
 		backendServices:  backendServices,
+		retryConfig:      retryConfig,
+		operations:       operations,
 	}
 }
 
+// startOperation starts a GCP operation and returns the operation or an
+// error from the API call.
+type startOperation func() (*compute.Operation, error)
+
 func (c *Client) ApplyPolicy(ctx context.Context, cluster *capg.GCPCluster, policy Policy) error {
+	return google.Retry(ctx, c.retryConfig, func() error {
+		return c.applyPolicy(ctx, cluster, policy)
+	})
+}
+
+func (c *Client) applyPolicy(ctx context.Context, cluster *capg.GCPCluster, policy Policy) error {
 	logger := c.getLogger(ctx, policy.Name)
 
 	logger.Info("Applying security policy")
@@ -63,56 +105,195 @@ func (c *Client) ApplyPolicy(ctx context.Context, cluster *capg.GCPCluster, poli
 		return errors.New("cluster does not have backend service")
 	}
 
-	securityPolicy, err := c.applySecurityPolicy(ctx, logger, cluster, policy)
+	err := validatePolicy(policy)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return c.setSecurityPolicy(ctx, cluster, securityPolicy)
+	pending, err := c.pollOperations(ctx, getPolicyOperationKey(cluster, policy.Name), getBackendServiceOperationKey(cluster))
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	securityPolicy, pending, err := c.applySecurityPolicy(ctx, logger, cluster, policy)
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	attachedSelfLink, err := c.getAttachedPolicy(ctx, cluster)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !google.IsNilOrEmpty(attachedSelfLink) && *attachedSelfLink == *securityPolicy.SelfLink {
+		return nil
+	}
+
+	_, err = c.setSecurityPolicy(ctx, cluster, securityPolicy.SelfLink)
+	return errors.WithStack(err)
+}
+
+// pollOperations returns true if an operation of any of the resources is
+// still running.
+func (c *Client) pollOperations(ctx context.Context, keys ...google.OperationKey) (bool, error) {
+	for _, key := range keys {
+		pending, err := c.operations.Poll(ctx, key)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		if pending {
+			c.getLogger(ctx, key.Resource).Info("Previous operation still running")
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
 
-func (c *Client) setSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy) error {
+// runOperations starts the operations one after the other. It returns true
+// when an operation is still running, in which case the remaining operations
+// are not started.
+func (c *Client) runOperations(ctx context.Context, key google.OperationKey, operations []startOperation) (bool, error) {
+	for _, start := range operations {
+		op, err := start()
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		pending, err := c.operations.Start(ctx, key, op)
+		if err != nil || pending {
+			return pending, errors.WithStack(err)
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Client) getAttachedPolicy(ctx context.Context, cluster *capg.GCPCluster) (*string, error) {
+	req := &computepb.GetBackendServiceRequest{
+		BackendService: google.GetResourceName(*cluster.Status.Network.APIServerBackendService),
+		Project:        cluster.Spec.Project,
+	}
+	backendService, err := c.backendServices.Get(ctx, req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return backendService.SecurityPolicy, nil
+}
+
+func (c *Client) setSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policySelfLink *string) (bool, error) {
 	req := &computepb.SetSecurityPolicyBackendServiceRequest{
 		BackendService: google.GetResourceName(*cluster.Status.Network.APIServerBackendService),
 		Project:        cluster.Spec.Project,
 		SecurityPolicyReferenceResource: &computepb.SecurityPolicyReference{
-			SecurityPolicy: policy.SelfLink,
+			SecurityPolicy: policySelfLink,
 		},
 	}
 
 	op, err := c.backendServices.SetSecurityPolicy(ctx, req)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	pending, err := c.operations.Start(ctx, getBackendServiceOperationKey(cluster), op)
+	return pending, errors.WithStack(err)
 }
 
-func (c *Client) applySecurityPolicy(ctx context.Context, logger logr.Logger, cluster *capg.GCPCluster, policy Policy) (*computepb.SecurityPolicy, error) {
+// applySecurityPolicy returns true when an operation on the policy is still
+// running. The returned policy is only set otherwise.
+func (c *Client) applySecurityPolicy(ctx context.Context, logger logr.Logger, cluster *capg.GCPCluster, policy Policy) (*computepb.SecurityPolicy, bool, error) {
 	securityPolicy := toGCPSecurityPolicy(cluster, policy)
 
-	gcpPolicy, err := c.createSecurityPolicy(ctx, cluster, securityPolicy)
+	gcpPolicy, pending, err := c.createSecurityPolicy(ctx, cluster, securityPolicy)
 	if google.HasHttpCode(err, http.StatusConflict) {
 		logger.Info("securityPolicy already exists. Updating")
-		return c.updateSecurityPolicy(ctx, cluster, securityPolicy)
+		resource := fmt.Sprintf("security policy %q", policy.Name)
+		err = google.RetryOnPreconditionFailed(resource, google.MaxConflictAttempts, func() error {
+			var updateErr error
+			gcpPolicy, pending, updateErr = c.updateSecurityPolicy(ctx, cluster, securityPolicy)
+			if google.IsPreconditionFailed(updateErr) {
+				logger.Info("securityPolicy changed while updating. Retrying")
+			}
+			return updateErr
+		})
+		return gcpPolicy, pending, errors.WithStack(err)
 	}
 
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
-	return gcpPolicy, nil
+	return gcpPolicy, pending, nil
 }
 
+// DeletePolicy detaches the policy from the backend service of the cluster
+// and deletes it.
 func (c *Client) DeletePolicy(ctx context.Context, cluster *capg.GCPCluster, name string) error {
+	return google.Retry(ctx, c.retryConfig, func() error {
+		return c.deletePolicy(ctx, cluster, name)
+	})
+}
+
+func (c *Client) deletePolicy(ctx context.Context, cluster *capg.GCPCluster, name string) error {
 	logger := c.getLogger(ctx, name)
 
 	logger.Info("Deleting security policy")
 	defer logger.Info("Done deleting security policy")
 
-	if cluster.Status.Network.APIServerBackendService != nil {
-		return errors.New("cluster backend service not deleted yet")
+	pending, err := c.detachSecurityPolicy(ctx, cluster, func(attachedName string) bool {
+		return attachedName == name
+	})
+	if err != nil || pending {
+		return errors.WithStack(err)
+	}
+
+	_, err = c.deleteSecurityPolicy(ctx, cluster, name)
+	return errors.WithStack(err)
+}
+
+// detachSecurityPolicy removes the security policy from the backend service
+// of the cluster, if the attached policy matches, so that it can be deleted
+// without waiting for CAPG to delete the backend service. It returns true
+// while the backend service is still being updated.
+func (c *Client) detachSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, isPolicy func(name string) bool) (bool, error) {
+	if google.IsNilOrEmpty(cluster.Status.Network.APIServerBackendService) {
+		return false, nil
+	}
+
+	pending, err := c.pollOperations(ctx, getBackendServiceOperationKey(cluster))
+	if err != nil || pending {
+		return pending, errors.WithStack(err)
+	}
+
+	attachedSelfLink, err := c.getAttachedPolicy(ctx, cluster)
+	if google.HasHttpCode(err, http.StatusNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if google.IsNilOrEmpty(attachedSelfLink) || !isPolicy(google.GetResourceName(*attachedSelfLink)) {
+		return false, nil
+	}
+
+	c.getLogger(ctx, google.GetResourceName(*attachedSelfLink)).Info("Detaching security policy from backend service")
+	pending, err = c.setSecurityPolicy(ctx, cluster, nil)
+	return pending, errors.WithStack(err)
+}
+
+// deleteSecurityPolicy deletes the security policy. It must not be attached
+// to a backend service anymore. It returns true while the deletion, or a
+// previous operation on the policy, is still running.
+func (c *Client) deleteSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, name string) (bool, error) {
+	logger := c.getLogger(ctx, name)
+
+	key := getPolicyOperationKey(cluster, name)
+	pending, err := c.pollOperations(ctx, key)
+	if err != nil || pending {
+		return pending, errors.WithStack(err)
 	}
 
 	req := &computepb.DeleteSecurityPolicyRequest{
@@ -122,18 +303,20 @@ func (c *Client) DeletePolicy(ctx context.Context, cluster *capg.GCPCluster, nam
 	op, err := c.securityPolicies.Delete(ctx, req)
 	if google.HasHttpCode(err, http.StatusNotFound) {
 		logger.Info("Firewall already deleted")
-		return nil
+		return false, nil
 	}
 
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	pending, err = c.operations.Start(ctx, key, op)
+	return pending, errors.WithStack(err)
 }
 
-func (c *Client) createSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy) (*computepb.SecurityPolicy, error) {
+// createSecurityPolicy returns true while the policy is being created. The
+// returned policy is only set otherwise.
+func (c *Client) createSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy) (*computepb.SecurityPolicy, bool, error) {
 	req := &computepb.InsertSecurityPolicyRequest{
 		Project:                cluster.Spec.Project,
 		SecurityPolicyResource: policy,
@@ -141,16 +324,23 @@ func (c *Client) createSecurityPolicy(ctx context.Context, cluster *capg.GCPClus
 
 	op, err := c.securityPolicies.Insert(ctx, req)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
-	err = op.Wait(ctx)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	pending, err := c.operations.Start(ctx, getPolicyOperationKey(cluster, *policy.Name), op)
+	if err != nil || pending {
+		return nil, pending, errors.WithStack(err)
 	}
 
 	// Getting the policy is necessary to populate the SelfLink.
-	return c.getSecurityPolicy(ctx, cluster, *policy.Name)
+	gcpPolicy, err := c.getSecurityPolicy(ctx, cluster, *policy.Name)
+	return gcpPolicy, false, errors.WithStack(err)
+}
+
+// GetPolicy returns the security policy with the name.
+func (c *Client) GetPolicy(ctx context.Context, cluster *capg.GCPCluster, name string) (*computepb.SecurityPolicy, error) {
+	policy, err := c.getSecurityPolicy(ctx, cluster, name)
+	return policy, errors.WithStack(err)
 }
 
 func (c *Client) getSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, name string) (*computepb.SecurityPolicy, error) {
@@ -161,80 +351,204 @@ func (c *Client) getSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster
 	return c.securityPolicies.Get(ctx, req)
 }
 
-func (c *Client) updateSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy) (*computepb.SecurityPolicy, error) {
+// updateSecurityPolicy changes the rules of the current policy in three
+// steps, so that the allowed ranges never shrink before the new rules are in
+// place:
+//  1. New allow rules are created. Changed allow rules get a temporary copy
+//     at their priority offset by TemporaryRulePriorityOffset.
+//  2. Changed rules are patched and new deny rules are created.
+//  3. The temporary copies and the rules that are no longer wanted are
+//     removed.
+//
+// When operations are tracked, only the first change is started and true is
+// returned. The next call computes the changes again from the current policy,
+// which holds the temporary copies until the rules are patched, so the steps
+// keep their order across calls.
+//
+// Rule operations can't carry a fingerprint, so before any rule is touched
+// the policy is patched with the fingerprint it was read with. GCP rejects
+// that patch with 412 Precondition Failed if the policy changed in the
+// meantime, since the changes were then computed from a stale policy. When
+// operations are tracked, waiting for that patch would block the
+// reconciliation, so instead the policy is read again right before each
+// rule operation and the operation fails with 412 Precondition Failed as
+// well if the fingerprint changed.
+func (c *Client) updateSecurityPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy) (*computepb.SecurityPolicy, bool, error) {
 	currentPolicy, err := c.getSecurityPolicy(ctx, cluster, *policy.Name)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
-	// There are three groups of rules - new rules, rules we want to update and
-	// rules that we want to delete. Rules that are in the current policy, but
-	// not in the new one should be deleted.
-	// We start of by marking all the rules in the current policy for deletion.
-	rulesToDelete := constructRulePriorityMap(currentPolicy.Rules)
+	currentRules := constructRulePriorityMap(currentPolicy.Rules)
+	desiredPriorities := map[int32]struct{}{}
+	allowRulesToCreate := []*computepb.SecurityPolicyRule{}
+	temporaryRules := []*computepb.SecurityPolicyRule{}
+	temporaryPriorities := []int32{}
+	rulesToPatch := []*computepb.SecurityPolicyRule{}
+	rulesToCreate := []*computepb.SecurityPolicyRule{}
+
 	for _, rule := range policy.Rules {
 		priority := *rule.Priority
+		desiredPriorities[priority] = struct{}{}
 
-		// If both the new and old policy contain a rule with the same
-		// priority, then patch that rule and remove it from the rules that
-		// need to be deleted.
-		// If a rule is only in the new policy then it needs to be created.
-		// NOTE: We can't check for 404 when patching the rule. GCP will always
-		// return 400 if a rule doesn't exist. This also applies to `GetRule`
-		_, ok := rulesToDelete[priority]
-		if ok {
-			delete(rulesToDelete, priority)
-			err = c.patchRule(ctx, cluster, policy, rule)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
+		currentRule, ok := currentRules[priority]
+		if ok && isSameRule(currentRule, rule) {
+			continue
+		}
 
+		if !ok && !isAllowRule(rule) {
+			rulesToCreate = append(rulesToCreate, rule)
 			continue
 		}
 
-		err = c.createRule(ctx, cluster, policy, rule)
-		if err != nil {
-			return nil, errors.WithStack(err)
+		if !ok {
+			allowRulesToCreate = append(allowRulesToCreate, rule)
+			continue
 		}
-	}
 
-	for rulePriority := range rulesToDelete {
-		// The default rule cannot be deleted
-		if rulePriority == DefaultRulePriority {
+		rulesToPatch = append(rulesToPatch, rule)
+		if !isAllowRule(rule) || priority == DefaultRulePriority {
 			continue
 		}
 
-		err = c.deleteRule(ctx, cluster, policy, rulePriority)
-		if err != nil {
-			return nil, errors.WithStack(err)
+		temporaryRule := toTemporaryRule(rule)
+		temporaryPriorities = append(temporaryPriorities, *temporaryRule.Priority)
+
+		currentTemporaryRule, ok := currentRules[*temporaryRule.Priority]
+		if ok && isSameRule(currentTemporaryRule, temporaryRule) {
+			continue
 		}
+		temporaryRules = append(temporaryRules, temporaryRule)
 	}
 
+	prioritiesToDelete := getPrioritiesToDelete(currentRules, desiredPriorities, temporaryPriorities)
+	updateAdvancedOptions := policy.AdvancedOptionsConfig != nil && !hasSameLogLevel(currentPolicy, policy)
+	hasRuleChanges := len(allowRulesToCreate) != 0 || len(temporaryRules) != 0 || len(rulesToPatch) != 0 || len(rulesToCreate) != 0 || len(prioritiesToDelete) != 0
+
 	selfLink := *currentPolicy.SelfLink
 	policy.SelfLink = &selfLink
-	return policy, nil
+
+	operations := []startOperation{}
+	if updateAdvancedOptions || (hasRuleChanges && !c.operations.IsAsync()) {
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.patchPolicy(ctx, cluster, policy, currentPolicy.Fingerprint, updateAdvancedOptions)
+		})
+	}
+
+	for _, rule := range allowRulesToCreate {
+		rule := rule
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.createRule(ctx, cluster, policy, rule)
+		})
+	}
+
+	for _, rule := range temporaryRules {
+		rule := rule
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.createOrPatchRule(ctx, cluster, policy, currentRules, rule)
+		})
+	}
+
+	for _, rule := range rulesToPatch {
+		rule := rule
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.patchRule(ctx, cluster, policy, rule)
+		})
+	}
+
+	for _, rule := range rulesToCreate {
+		rule := rule
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.createRule(ctx, cluster, policy, rule)
+		})
+	}
+
+	for _, priority := range prioritiesToDelete {
+		priority := priority
+		operations = append(operations, func() (*compute.Operation, error) {
+			return c.deleteRule(ctx, cluster, policy, priority)
+		})
+	}
+
+	if c.operations.IsAsync() {
+		for i, start := range operations {
+			operations[i] = c.withFingerprintCheck(ctx, cluster, currentPolicy, start)
+		}
+	}
+
+	pending, err := c.runOperations(ctx, getPolicyOperationKey(cluster, *policy.Name), operations)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	return policy, pending, nil
 }
 
-func (c *Client) createRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rule *computepb.SecurityPolicyRule) error {
+// withFingerprintCheck reads the policy again before starting the operation
+// and fails with 412 Precondition Failed, like a patch with a stale
+// fingerprint, if the policy changed since readPolicy was read.
+func (c *Client) withFingerprintCheck(ctx context.Context, cluster *capg.GCPCluster, readPolicy *computepb.SecurityPolicy, start startOperation) startOperation {
+	return func() (*compute.Operation, error) {
+		latestPolicy, err := c.getSecurityPolicy(ctx, cluster, readPolicy.GetName())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if latestPolicy.GetFingerprint() != readPolicy.GetFingerprint() {
+			return nil, &googleapi.Error{
+				Code:    http.StatusPreconditionFailed,
+				Message: fmt.Sprintf("security policy %q changed since it was read", readPolicy.GetName()),
+			}
+		}
+
+		return start()
+	}
+}
+
+// createOrPatchRule patches the rule if the current policy has a rule with
+// the same priority, for example a temporary rule left over by an update
+// that failed halfway.
+func (c *Client) createOrPatchRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, currentRules map[int32]*computepb.SecurityPolicyRule, rule *computepb.SecurityPolicyRule) (*compute.Operation, error) {
+	_, ok := currentRules[*rule.Priority]
+	if ok {
+		return c.patchRule(ctx, cluster, policy, rule)
+	}
+
+	return c.createRule(ctx, cluster, policy, rule)
+}
+
+// patchPolicy sends the fingerprint the policy was read with, so that GCP
+// rejects the patch if the policy changed since. Rules can not be updated
+// with a policy patch, so only the advanced options are sent, and only when
+// they changed.
+func (c *Client) patchPolicy(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, fingerprint *string, updateAdvancedOptions bool) (*compute.Operation, error) {
+	securityPolicy := &computepb.SecurityPolicy{
+		Fingerprint: fingerprint,
+	}
+	if updateAdvancedOptions {
+		securityPolicy.AdvancedOptionsConfig = policy.AdvancedOptionsConfig
+	}
+
+	req := &computepb.PatchSecurityPolicyRequest{
+		Project:                cluster.Spec.Project,
+		SecurityPolicy:         *policy.Name,
+		SecurityPolicyResource: securityPolicy,
+	}
+	op, err := c.securityPolicies.Patch(ctx, req)
+	return op, errors.WithStack(err)
+}
+
+func (c *Client) createRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rule *computepb.SecurityPolicyRule) (*compute.Operation, error) {
 	req := &computepb.AddRuleSecurityPolicyRequest{
 		Project:                    cluster.Spec.Project,
 		SecurityPolicy:             *policy.Name,
 		SecurityPolicyRuleResource: rule,
 	}
 	op, err := c.securityPolicies.AddRule(ctx, req)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	err = op.Wait(ctx)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	return nil
+	return op, errors.WithStack(err)
 }
 
-func (c *Client) patchRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rule *computepb.SecurityPolicyRule) error {
+func (c *Client) patchRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rule *computepb.SecurityPolicyRule) (*compute.Operation, error) {
 	req := &computepb.PatchRuleSecurityPolicyRequest{
 		Priority:                   rule.Priority,
 		Project:                    cluster.Spec.Project,
@@ -242,28 +556,17 @@ func (c *Client) patchRule(ctx context.Context, cluster *capg.GCPCluster, policy
 		SecurityPolicyRuleResource: rule,
 	}
 	op, err := c.securityPolicies.PatchRule(ctx, req)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	return op, errors.WithStack(err)
 }
 
-func (c *Client) deleteRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rulePriority int32) error {
+func (c *Client) deleteRule(ctx context.Context, cluster *capg.GCPCluster, policy *computepb.SecurityPolicy, rulePriority int32) (*compute.Operation, error) {
 	req := &computepb.RemoveRuleSecurityPolicyRequest{
 		Priority:       &rulePriority,
 		Project:        cluster.Spec.Project,
 		SecurityPolicy: *policy.Name,
 	}
-
 	op, err := c.securityPolicies.RemoveRule(ctx, req)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	err = op.Wait(ctx)
-	return errors.WithStack(err)
+	return op, errors.WithStack(err)
 }
 
 func (c *Client) getLogger(ctx context.Context, ruleName string) logr.Logger {
@@ -272,15 +575,145 @@ func (c *Client) getLogger(ctx context.Context, ruleName string) logr.Logger {
 	return logger.WithValues("name", ruleName)
 }
 
-func constructRulePriorityMap(rules []*computepb.SecurityPolicyRule) map[int32]struct{} {
-	priorityMap := map[int32]struct{}{}
+func getPolicyOperationKey(cluster *capg.GCPCluster, name string) google.OperationKey {
+	return google.OperationKey{
+		Cluster:  types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name},
+		Resource: google.CollectionSecurityPolicies + "/" + name,
+	}
+}
+
+func getBackendServiceOperationKey(cluster *capg.GCPCluster) google.OperationKey {
+	return google.OperationKey{
+		Cluster:  types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name},
+		Resource: google.CollectionBackendServices + "/" + google.GetResourceName(*cluster.Status.Network.APIServerBackendService),
+	}
+}
+
+func constructRulePriorityMap(rules []*computepb.SecurityPolicyRule) map[int32]*computepb.SecurityPolicyRule {
+	priorityMap := map[int32]*computepb.SecurityPolicyRule{}
 	for _, rule := range rules {
-		priorityMap[*rule.Priority] = struct{}{}
+		priorityMap[*rule.Priority] = rule
 	}
 
 	return priorityMap
 }
 
+// getPrioritiesToDelete returns the temporary rules and the current rules
+// that are not wanted anymore. The default and break-glass rules are never
+// deleted.
+func getPrioritiesToDelete(currentRules map[int32]*computepb.SecurityPolicyRule, desiredPriorities map[int32]struct{}, temporaryPriorities []int32) []int32 {
+	priorities := []int32{}
+	priorities = append(priorities, temporaryPriorities...)
+
+	for priority, rule := range currentRules {
+		if _, ok := desiredPriorities[priority]; ok {
+			continue
+		}
+
+		if priority == DefaultRulePriority || isBreakGlassRule(rule) {
+			continue
+		}
+
+		if containsPriority(temporaryPriorities, priority) {
+			continue
+		}
+
+		priorities = append(priorities, priority)
+	}
+
+	return priorities
+}
+
+func containsPriority(priorities []int32, priority int32) bool {
+	for _, p := range priorities {
+		if p == priority {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAllowRule(rule *computepb.SecurityPolicyRule) bool {
+	return rule.GetAction() == ActionAllow
+}
+
+func isSameRule(current, desired *computepb.SecurityPolicyRule) bool {
+	if current.GetAction() != desired.GetAction() || current.GetDescription() != desired.GetDescription() {
+		return false
+	}
+
+	currentRanges := current.GetMatch().GetConfig().GetSrcIpRanges()
+	desiredRanges := desired.GetMatch().GetConfig().GetSrcIpRanges()
+	if len(currentRanges) != len(desiredRanges) {
+		return false
+	}
+
+	for i := range currentRanges {
+		if currentRanges[i] != desiredRanges[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toTemporaryRule(rule *computepb.SecurityPolicyRule) *computepb.SecurityPolicyRule {
+	return &computepb.SecurityPolicyRule{
+		Action:      rule.Action,
+		Description: to.StringP(TemporaryRuleDescription),
+		Match:       rule.Match,
+		Priority:    to.Int32P(*rule.Priority + TemporaryRulePriorityOffset),
+	}
+}
+
+// isBreakGlassRule returns true for the IPv4 and IPv6 break-glass rules. The
+// description is checked as well, because older versions of the operator
+// used the same priority for the user rule.
+func isBreakGlassRule(rule *computepb.SecurityPolicyRule) bool {
+	priority := rule.GetPriority()
+	if priority != BreakGlassRulePriority && priority != BreakGlassRulePriority+IPv6PriorityOffset {
+		return false
+	}
+
+	return rule.GetDescription() == BreakGlassRuleDescription
+}
+
+// validatePolicy refuses policies that would deny all traffic to the
+// kubernetes api, which would lock everyone out of the cluster. The
+// break-glass and NAT rules are always present, so they are not counted as
+// allow rules.
+func validatePolicy(policy Policy) error {
+	if policy.Name == "" {
+		return errors.New("security policy name must not be empty")
+	}
+
+	if policy.DefaultAction == ActionAllow {
+		return nil
+	}
+
+	for _, rule := range policy.Rules {
+		if isBreakGlassOrNATRule(rule) {
+			continue
+		}
+
+		if rule.Action == ActionAllow && len(rule.SourceIPRanges) != 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("security policy %q denies by default and has no allow rules", policy.Name)
+}
+
+func isBreakGlassOrNATRule(rule PolicyRule) bool {
+	switch rule.Description {
+	case BreakGlassRuleDescription, MCNATRuleDescription, WCNATRuleDescription:
+		return true
+	}
+
+	return false
+}
+
 func toGCPSecurityPolicy(cluster *capg.GCPCluster, policy Policy) *computepb.SecurityPolicy {
 	defaultRule := getDefaultRule(policy.DefaultAction)
 	rules := []*computepb.SecurityPolicyRule{defaultRule}
@@ -297,13 +730,30 @@ func toGCPSecurityPolicy(cluster *capg.GCPCluster, policy Policy) *computepb.Sec
 		})
 	}
 
+	var advancedOptions *computepb.SecurityPolicyAdvancedOptionsConfig
+	if policy.LogLevel != "" {
+		advancedOptions = &computepb.SecurityPolicyAdvancedOptionsConfig{
+			LogLevel: to.StringP(policy.LogLevel),
+		}
+	}
+
 	return &computepb.SecurityPolicy{
-		Description: to.StringP(policy.Description),
-		Name:        to.StringP(policy.Name),
-		Rules:       rules,
+		AdvancedOptionsConfig: advancedOptions,
+		Description:           to.StringP(policy.Description),
+		Name:                  to.StringP(policy.Name),
+		Rules:                 rules,
 	}
 }
 
+func hasSameLogLevel(current, desired *computepb.SecurityPolicy) bool {
+	currentLogLevel := LogLevelNormal
+	if current.AdvancedOptionsConfig != nil && !google.IsNilOrEmpty(current.AdvancedOptionsConfig.LogLevel) {
+		currentLogLevel = *current.AdvancedOptionsConfig.LogLevel
+	}
+
+	return currentLogLevel == desired.AdvancedOptionsConfig.GetLogLevel()
+}
+
 func getDefaultRule(defaultAction string) *computepb.SecurityPolicyRule {
 	return &computepb.SecurityPolicyRule{
 		Action:      to.StringP(defaultAction),
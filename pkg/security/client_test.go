@@ -0,0 +1,75 @@
+package security_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		breakGlassRule = security.PolicyRule{
+			Action:         security.ActionAllow,
+			Description:    security.BreakGlassRuleDescription,
+			SourceIPRanges: []string{"192.0.2.0/24"},
+			Priority:       security.BreakGlassRulePriority,
+		}
+		mcNATRule = security.PolicyRule{
+			Action:         security.ActionAllow,
+			Description:    security.MCNATRuleDescription,
+			SourceIPRanges: []string{"198.51.100.1/32"},
+			Priority:       2,
+		}
+		wcNATRule = security.PolicyRule{
+			Action:         security.ActionAllow,
+			Description:    security.WCNATRuleDescription,
+			SourceIPRanges: []string{"198.51.100.2/32"},
+			Priority:       3,
+		}
+		userRule = security.PolicyRule{
+			Action:         security.ActionAllow,
+			Description:    "allow user specified ips to connect to kubernetes api",
+			SourceIPRanges: []string{"10.0.0.0/24"},
+			Priority:       1,
+		}
+	)
+
+	DescribeTable("validatePolicy",
+		func(policy security.Policy, expectedError string) {
+			err := security.ValidatePolicy(policy)
+			if expectedError == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(MatchError(ContainSubstring(expectedError)))
+		},
+		Entry("empty name",
+			security.Policy{DefaultAction: security.ActionAllow},
+			"name must not be empty"),
+		Entry("allow by default without rules",
+			security.Policy{Name: "policy", DefaultAction: security.ActionAllow},
+			""),
+		Entry("deny by default without rules",
+			security.Policy{Name: "policy", DefaultAction: security.ActionDeny403},
+			"denies by default and has no allow rules"),
+		Entry("deny by default with a user rule",
+			security.Policy{Name: "policy", DefaultAction: security.ActionDeny403, Rules: []security.PolicyRule{breakGlassRule, userRule, mcNATRule, wcNATRule}},
+			""),
+		Entry("deny by default with only break-glass and NAT rules",
+			security.Policy{Name: "policy", DefaultAction: security.ActionDeny403, Rules: []security.PolicyRule{breakGlassRule, mcNATRule, wcNATRule}},
+			"denies by default and has no allow rules"),
+		Entry("deny by default with a user rule without ranges",
+			security.Policy{Name: "policy", DefaultAction: security.ActionDeny403, Rules: []security.PolicyRule{
+				{Action: security.ActionAllow, Description: userRule.Description, Priority: userRule.Priority},
+				mcNATRule,
+			}},
+			"denies by default and has no allow rules"),
+		Entry("deny by default with only deny rules",
+			security.Policy{Name: "policy", DefaultAction: security.ActionDeny403, Rules: []security.PolicyRule{
+				{Action: security.ActionDeny403, Description: userRule.Description, SourceIPRanges: userRule.SourceIPRanges, Priority: userRule.Priority},
+			}},
+			"denies by default and has no allow rules"),
+	)
+})
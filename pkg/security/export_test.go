@@ -0,0 +1,22 @@
+package security
+
+import (
+	"context"
+
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+)
+
+var (
+	ValidatePolicy          = validatePolicy
+	GetVersionedPolicyName  = getVersionedPolicyName
+	IsPolicyVersion         = isPolicyVersion
+	GetPolicyVersionsFilter = getPolicyVersionsFilter
+)
+
+func NewBlueGreenClientWithGetter(policies SecurityPolicyGetter) *BlueGreenClient {
+	return &BlueGreenClient{policies: policies}
+}
+
+func (c *BlueGreenClient) KeepBreakGlassRules(ctx context.Context, cluster *capg.GCPCluster, policy Policy, attachedSelfLink *string) (Policy, error) {
+	return c.keepBreakGlassRules(ctx, cluster, policy, attachedSelfLink)
+}
@@ -3,17 +3,43 @@ package security
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/cidr"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
 )
 
-const AnnotationAPIAllowListSubnets = "api.gcp.giantswarm.io/allowlist"
+const (
+	// ReconcilerName identifies the reconciler of the security policy in the
+	// GCPCluster controller.
+	ReconcilerName = "security-policy"
+
+	AnnotationAPIAllowListSubnets = "api.gcp.giantswarm.io/allowlist"
+	AnnotationAPIVerboseLogging   = "api.gcp.giantswarm.io/verbose-logging"
+
+	// IPv6PriorityOffset is added to the priority of a rule to get the
+	// priority of its IPv6 variant.
+	IPv6PriorityOffset = int32(1000)
+
+	// EventReasonAllowListEntryExpired is the reason of the event recorded
+	// when an expiring allowlist entry is left out of the policy.
+	EventReasonAllowListEntryExpired = "AllowListEntryExpired"
+
+	// EventReasonConcurrentModification is the reason of the event recorded
+	// when the security policy kept changing while it was being updated.
+	EventReasonConcurrentModification = "ConcurrentModification"
+)
 
 //counterfeiter:generate . SecurityPolicyClient
 type SecurityPolicyClient interface {
@@ -27,76 +53,180 @@ type ClusterNATIPResolver interface {
 }
 
 func NewPolicyReconciler(
+	breakGlassAllowList []string,
 	defaultAPIAllowList []string,
 	managementCluster types.NamespacedName,
+	defaultVerboseLogging bool,
+	aggregateRanges bool,
 	securityPolicyClient SecurityPolicyClient,
 	ipResolver ClusterNATIPResolver,
+	recorder record.EventRecorder,
 ) *PolicyReconciler {
 	return &PolicyReconciler{
-		defaultAPIAllowList:  defaultAPIAllowList,
+		breakGlassAllowList: breakGlassAllowList,
+		defaults: Defaults{
+			APIAllowList:    defaultAPIAllowList,
+			VerboseLogging:  defaultVerboseLogging,
+			AggregateRanges: aggregateRanges,
+		},
 		managementCluster:    managementCluster,
 		securityPolicyClient: securityPolicyClient,
 		ipResolver:           ipResolver,
+		recorder:             recorder,
+		expiredEntries:       cidr.NewExpiredEntries(),
 	}
 }
 
+// Defaults are the operator defaults for clusters that don't override them
+// with annotations.
+type Defaults struct {
+	APIAllowList    []string
+	VerboseLogging  bool
+	AggregateRanges bool
+}
+
 type PolicyReconciler struct {
-	defaultAPIAllowList []string
+	breakGlassAllowList []string
+	defaultsMutex       sync.RWMutex
+	defaults            Defaults
 	managementCluster   types.NamespacedName
 
 	securityPolicyClient SecurityPolicyClient
 	ipResolver           ClusterNATIPResolver
+	recorder             record.EventRecorder
+	expiredEntries       *cidr.ExpiredEntries
+}
+
+func (r *PolicyReconciler) Name() string {
+	return ReconcilerName
+}
+
+// AllowListAnnotations returns the allowlist annotations the reconciler
+// reads.
+func (r *PolicyReconciler) AllowListAnnotations() []string {
+	return []string{AnnotationAPIAllowListSubnets}
 }
 
-func (r *PolicyReconciler) Reconcile(ctx context.Context, cluster *capg.GCPCluster) error {
+// Reconcile applies the security policy of the cluster. The result requeues
+// the cluster when the first expiring allowlist entry expires.
+func (r *PolicyReconciler) Reconcile(ctx context.Context, cluster *capg.GCPCluster) (ctrl.Result, error) {
 	logger := r.getLogger(ctx)
 
-	userRules, err := r.getUserRules(logger, cluster)
+	userRules, nextExpiry, err := r.getUserRules(logger, cluster)
 	if err != nil {
-		return errors.WithStack(err)
+		return ctrl.Result{}, errors.WithStack(err)
 	}
 
 	defaultRules, err := r.getDefaultRules(ctx, cluster)
 	if err != nil {
-		return errors.WithStack(err)
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	logLevel, err := r.getLogLevel(cluster)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
 	}
 
 	rules := []PolicyRule{}
+	rules = append(rules, r.getBreakGlassRules()...)
 	rules = append(rules, userRules...)
 	rules = append(rules, defaultRules...)
+	rules, err = r.normalizeRanges(rules)
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+	rules = splitRulesByFamily(rules)
 
 	policyName := getAPISecurityPolicyName(cluster.Name)
 	policy := Policy{
 		Name:          policyName,
 		Description:   "allow IPs to connect to kubernetes api",
 		DefaultAction: ActionDeny403,
+		LogLevel:      logLevel,
 		Rules:         rules,
 	}
 
-	return r.securityPolicyClient.ApplyPolicy(ctx, cluster, policy)
+	err = r.securityPolicyClient.ApplyPolicy(ctx, cluster, policy)
+	if google.IsConflict(err) {
+		r.recorder.Eventf(cluster, corev1.EventTypeWarning, EventReasonConcurrentModification,
+			"Security policy %q was modified by someone else while it was being updated: %v", policyName, err)
+	}
+
+	if err != nil {
+		return ctrl.Result{}, errors.WithStack(err)
+	}
+
+	return ctrl.Result{RequeueAfter: cidr.UntilExpiry(nextExpiry)}, nil
 }
 
 func (r *PolicyReconciler) ReconcileDelete(ctx context.Context, cluster *capg.GCPCluster) error {
+	r.expiredEntries.Forget(fmt.Sprintf("%s/%s", cluster.Namespace, cluster.Name))
+
 	policyName := getAPISecurityPolicyName(cluster.Name)
 	return r.securityPolicyClient.DeletePolicy(ctx, cluster, policyName)
 }
 
-func (r *PolicyReconciler) getUserRules(logger logr.Logger, cluster *capg.GCPCluster) ([]PolicyRule, error) {
-	sourceIPRanges, err := getIPRanges(logger, cluster)
+func (r *PolicyReconciler) getUserRules(logger logr.Logger, cluster *capg.GCPCluster) ([]PolicyRule, time.Time, error) {
+	allowList, err := r.getAllowList(logger, cluster)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, time.Time{}, errors.WithStack(err)
 	}
 
 	rules := []PolicyRule{}
-	if len(sourceIPRanges) != 0 {
+	if len(allowList.Ranges) != 0 {
 		rules = append(rules, PolicyRule{
 			Action:         ActionAllow,
 			Description:    "allow user specified ips to connect to kubernetes api",
-			SourceIPRanges: sourceIPRanges,
-			Priority:       0,
+			SourceIPRanges: allowList.Ranges,
+			Priority:       1,
 		})
 	}
-	return rules, nil
+	return rules, allowList.NextExpiry, nil
+}
+
+// getBreakGlassRules returns the rule that keeps the kubernetes api reachable
+// from the break-glass allowlist, regardless of the cluster's allowlists.
+func (r *PolicyReconciler) getBreakGlassRules() []PolicyRule {
+	if len(r.breakGlassAllowList) == 0 {
+		return nil
+	}
+
+	return []PolicyRule{
+		{
+			Action:         ActionAllow,
+			Description:    BreakGlassRuleDescription,
+			SourceIPRanges: r.breakGlassAllowList,
+			Priority:       BreakGlassRulePriority,
+		},
+	}
+}
+
+// getAllowList parses the allowlist annotation and records an event for each
+// entry that has expired since the annotation was last parsed.
+func (r *PolicyReconciler) getAllowList(logger logr.Logger, gcpCluster *capg.GCPCluster) (cidr.AllowList, error) {
+	annotation, ok := gcpCluster.Annotations[AnnotationAPIAllowListSubnets]
+	if !ok {
+		logger.Info(fmt.Sprintf("Cluster does not have %q annotation. Skipping user rule", AnnotationAPIAllowListSubnets))
+		return cidr.AllowList{}, nil
+	}
+
+	allowList, err := cidr.ParseAllowList(annotation, time.Now())
+	if err != nil {
+		return cidr.AllowList{}, errors.WithStack(err)
+	}
+
+	err = checkPublicRanges(logger, allowList)
+	if err != nil {
+		return cidr.AllowList{}, errors.WithStack(err)
+	}
+
+	key := fmt.Sprintf("%s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	for _, entry := range r.expiredEntries.Observe(key, allowList.Expired) {
+		r.recorder.Eventf(gcpCluster, corev1.EventTypeNormal, EventReasonAllowListEntryExpired,
+			"Access from %q in annotation %q expired and was revoked", entry, AnnotationAPIAllowListSubnets)
+	}
+
+	return allowList, nil
 }
 
 func (r *PolicyReconciler) getDefaultRules(ctx context.Context, workloadCluster *capg.GCPCluster) ([]PolicyRule, error) {
@@ -112,23 +242,23 @@ func (r *PolicyReconciler) getDefaultRules(ctx context.Context, workloadCluster
 
 	allowMCNATRule := PolicyRule{
 		Action:         ActionAllow,
-		Description:    "allow MC NAT IPs",
+		Description:    MCNATRuleDescription,
 		SourceIPRanges: mcNATIPs,
-		Priority:       1,
+		Priority:       2,
 	}
 
 	allowWCNATRule := PolicyRule{
 		Action:         ActionAllow,
-		Description:    "allow WC NAT IPs",
+		Description:    WCNATRuleDescription,
 		SourceIPRanges: wcNATIPs,
-		Priority:       2,
+		Priority:       3,
 	}
 
 	allowDefaultAllowlist := PolicyRule{
 		Action:         ActionAllow,
 		Description:    "allow default IP ranges",
-		SourceIPRanges: r.defaultAPIAllowList,
-		Priority:       3,
+		SourceIPRanges: r.getDefaults().APIAllowList,
+		Priority:       4,
 	}
 
 	return []PolicyRule{
@@ -138,6 +268,58 @@ func (r *PolicyReconciler) getDefaultRules(ctx context.Context, workloadCluster
 	}, nil
 }
 
+func (r *PolicyReconciler) getLogLevel(cluster *capg.GCPCluster) (string, error) {
+	verbose := r.getDefaults().VerboseLogging
+
+	annotation, ok := cluster.Annotations[AnnotationAPIVerboseLogging]
+	if ok && annotation != "" {
+		var err error
+		verbose, err = strconv.ParseBool(annotation)
+		if err != nil {
+			return "", fmt.Errorf("annotation %q has invalid value %q", AnnotationAPIVerboseLogging, annotation)
+		}
+	}
+
+	if verbose {
+		return LogLevelVerbose, nil
+	}
+
+	return LogLevelNormal, nil
+}
+
+// normalizeRanges canonicalizes, deduplicates and sorts the ranges of each
+// rule, so that the same allowlists always result in the same policy.
+func (r *PolicyReconciler) normalizeRanges(rules []PolicyRule) ([]PolicyRule, error) {
+	normalizedRules := []PolicyRule{}
+	for _, rule := range rules {
+		ranges, err := cidr.Normalize(rule.SourceIPRanges, r.getDefaults().AggregateRanges)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		rule.SourceIPRanges = ranges
+		normalizedRules = append(normalizedRules, rule)
+	}
+
+	return normalizedRules, nil
+}
+
+// SetDefaults replaces the operator defaults. They are used from the next
+// reconciliation on.
+func (r *PolicyReconciler) SetDefaults(defaults Defaults) {
+	r.defaultsMutex.Lock()
+	defer r.defaultsMutex.Unlock()
+
+	r.defaults = defaults
+}
+
+func (r *PolicyReconciler) getDefaults() Defaults {
+	r.defaultsMutex.RLock()
+	defer r.defaultsMutex.RUnlock()
+
+	return r.defaults
+}
+
 func (r *PolicyReconciler) getLogger(ctx context.Context) logr.Logger {
 	logger := log.FromContext(ctx)
 	return logger.WithName("security-policy-reconciler")
@@ -150,16 +332,50 @@ func toNamespacedName(cluster *capg.GCPCluster) types.NamespacedName {
 	}
 }
 
+// splitRulesByFamily separates the IPv4 and IPv6 ranges of each rule into
+// their own rules, because Cloud Armor evaluates the families separately.
+// The IPv6 rule gets the priority offset by IPv6PriorityOffset. Rules without
+// any ranges are dropped.
+func splitRulesByFamily(rules []PolicyRule) []PolicyRule {
+	ipv4Rules := []PolicyRule{}
+	ipv6Rules := []PolicyRule{}
+	for _, rule := range rules {
+		ipv4Ranges, ipv6Ranges := cidr.SplitByFamily(rule.SourceIPRanges)
+
+		if len(ipv4Ranges) != 0 {
+			ipv4Rule := rule
+			ipv4Rule.SourceIPRanges = ipv4Ranges
+			ipv4Rules = append(ipv4Rules, ipv4Rule)
+		}
+
+		if len(ipv6Ranges) != 0 {
+			ipv6Rule := rule
+			ipv6Rule.SourceIPRanges = ipv6Ranges
+			ipv6Rule.Priority = rule.Priority + IPv6PriorityOffset
+			ipv6Rules = append(ipv6Rules, ipv6Rule)
+		}
+	}
+
+	return append(ipv4Rules, ipv6Rules...)
+}
+
 func getAPISecurityPolicyName(clusterName string) string {
 	return fmt.Sprintf("allow-%s-apiserver", clusterName)
 }
 
-func getIPRanges(logger logr.Logger, gcpCluster *capg.GCPCluster) ([]string, error) {
-	annotation, ok := gcpCluster.Annotations[AnnotationAPIAllowListSubnets]
-	if !ok {
-		logger.Info(fmt.Sprintf("Cluster does not have %q annotation. Skipping user rule", AnnotationAPIAllowListSubnets))
-		return nil, nil
+// checkPublicRanges rejects loopback ranges and warns about private ranges,
+// since requests to the public kubernetes api load balancer never have a
+// source IP in either of them.
+func checkPublicRanges(logger logr.Logger, allowList cidr.AllowList) error {
+	for _, ipRange := range allowList.Ranges {
+		if cidr.IsLoopback(ipRange) {
+			return fmt.Errorf("annotation %q contains loopback range %s", AnnotationAPIAllowListSubnets, allowList.Describe(ipRange))
+		}
+
+		if cidr.IsPrivate(ipRange) {
+			logger.Info(fmt.Sprintf("Annotation %q contains private range %s, which never reaches the public kubernetes api", AnnotationAPIAllowListSubnets, allowList.Describe(ipRange)))
+		}
 	}
 
-	return cidr.ParseFromCommaSeparated(annotation)
+	return nil
 }
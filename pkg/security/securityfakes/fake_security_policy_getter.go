@@ -0,0 +1,124 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package securityfakes
+
+import (
+	"context"
+	"sync"
+
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+)
+
+type FakeSecurityPolicyGetter struct {
+	GetPolicyStub        func(context.Context, *v1beta1.GCPCluster, string) (*computepb.SecurityPolicy, error)
+	getPolicyMutex       sync.RWMutex
+	getPolicyArgsForCall []struct {
+		arg1 context.Context
+		arg2 *v1beta1.GCPCluster
+		arg3 string
+	}
+	getPolicyReturns struct {
+		result1 *computepb.SecurityPolicy
+		result2 error
+	}
+	getPolicyReturnsOnCall map[int]struct {
+		result1 *computepb.SecurityPolicy
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicy(arg1 context.Context, arg2 *v1beta1.GCPCluster, arg3 string) (*computepb.SecurityPolicy, error) {
+	fake.getPolicyMutex.Lock()
+	ret, specificReturn := fake.getPolicyReturnsOnCall[len(fake.getPolicyArgsForCall)]
+	fake.getPolicyArgsForCall = append(fake.getPolicyArgsForCall, struct {
+		arg1 context.Context
+		arg2 *v1beta1.GCPCluster
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.GetPolicyStub
+	fakeReturns := fake.getPolicyReturns
+	fake.recordInvocation("GetPolicy", []interface{}{arg1, arg2, arg3})
+	fake.getPolicyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicyCallCount() int {
+	fake.getPolicyMutex.RLock()
+	defer fake.getPolicyMutex.RUnlock()
+	return len(fake.getPolicyArgsForCall)
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicyCalls(stub func(context.Context, *v1beta1.GCPCluster, string) (*computepb.SecurityPolicy, error)) {
+	fake.getPolicyMutex.Lock()
+	defer fake.getPolicyMutex.Unlock()
+	fake.GetPolicyStub = stub
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicyArgsForCall(i int) (context.Context, *v1beta1.GCPCluster, string) {
+	fake.getPolicyMutex.RLock()
+	defer fake.getPolicyMutex.RUnlock()
+	argsForCall := fake.getPolicyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicyReturns(result1 *computepb.SecurityPolicy, result2 error) {
+	fake.getPolicyMutex.Lock()
+	defer fake.getPolicyMutex.Unlock()
+	fake.GetPolicyStub = nil
+	fake.getPolicyReturns = struct {
+		result1 *computepb.SecurityPolicy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSecurityPolicyGetter) GetPolicyReturnsOnCall(i int, result1 *computepb.SecurityPolicy, result2 error) {
+	fake.getPolicyMutex.Lock()
+	defer fake.getPolicyMutex.Unlock()
+	fake.GetPolicyStub = nil
+	if fake.getPolicyReturnsOnCall == nil {
+		fake.getPolicyReturnsOnCall = make(map[int]struct {
+			result1 *computepb.SecurityPolicy
+			result2 error
+		})
+	}
+	fake.getPolicyReturnsOnCall[i] = struct {
+		result1 *computepb.SecurityPolicy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSecurityPolicyGetter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.getPolicyMutex.RLock()
+	defer fake.getPolicyMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeSecurityPolicyGetter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ security.SecurityPolicyGetter = new(FakeSecurityPolicyGetter)
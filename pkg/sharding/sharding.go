@@ -0,0 +1,121 @@
+package sharding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const shardHashLength = 8
+
+// Shard selects the GCPClusters an operator instance reconciles, so that
+// several instances with different credentials and defaults can run in the
+// same management cluster. The default shard selects all GCPClusters.
+type Shard struct {
+	Namespaces []string
+	Selector   labels.Selector
+}
+
+// New creates a shard from a list of namespaces and a GCPCluster label
+// selector. Empty values select everything.
+func New(namespaces []string, selector string) (Shard, error) {
+	parsedSelector, err := labels.Parse(selector)
+	if err != nil {
+		return Shard{}, errors.WithStack(err)
+	}
+
+	shardNamespaces := []string{}
+	for _, namespace := range namespaces {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" {
+			shardNamespaces = append(shardNamespaces, namespace)
+		}
+	}
+	sort.Strings(shardNamespaces)
+
+	return Shard{
+		Namespaces: shardNamespaces,
+		Selector:   parsedSelector,
+	}, nil
+}
+
+func (s Shard) IsDefault() bool {
+	return len(s.Namespaces) == 0 && (s.Selector == nil || s.Selector.Empty())
+}
+
+// LeaderElectionID returns base for the default shard. Other shards get an
+// ID derived from their namespaces and selector, so that instances of
+// different shards don't compete for the same lease.
+func (s Shard) LeaderElectionID(base string) string {
+	if s.IsDefault() {
+		return base
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s", strings.Join(s.Namespaces, ","), s.Selector.String())))
+	shardID := hex.EncodeToString(hash[:])[:shardHashLength]
+
+	name, domain, found := strings.Cut(base, ".")
+	if !found {
+		return fmt.Sprintf("%s-%s", base, shardID)
+	}
+
+	return fmt.Sprintf("%s-%s.%s", name, shardID, domain)
+}
+
+// NewCache restricts the manager cache to the namespaces of the shard and
+// the GCPClusters to its selector.
+func (s Shard) NewCache() cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		if s.Selector != nil && !s.Selector.Empty() {
+			if opts.SelectorsByObject == nil {
+				opts.SelectorsByObject = cache.SelectorsByObject{}
+			}
+			opts.SelectorsByObject[&capg.GCPCluster{}] = cache.ObjectSelector{Label: s.Selector}
+		}
+
+		switch len(s.Namespaces) {
+		case 0:
+			return cache.New(config, opts)
+		case 1:
+			opts.Namespace = s.Namespaces[0]
+			return cache.New(config, opts)
+		default:
+			return cache.MultiNamespacedCacheBuilder(s.Namespaces)(config, opts)
+		}
+	}
+}
+
+// Predicate filters out events of objects outside the shard.
+func (s Shard) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(s.Contains)
+}
+
+// Contains returns true if the object is in one of the namespaces of the
+// shard and matches its selector.
+func (s Shard) Contains(obj client.Object) bool {
+	if len(s.Namespaces) != 0 && !containsNamespace(s.Namespaces, obj.GetNamespace()) {
+		return false
+	}
+
+	return s.Selector == nil || s.Selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+func containsNamespace(namespaces []string, namespace string) bool {
+	for _, n := range namespaces {
+		if n == namespace {
+			return true
+		}
+	}
+
+	return false
+}
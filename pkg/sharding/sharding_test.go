@@ -0,0 +1,112 @@
+package sharding_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/sharding"
+)
+
+const baseLeaderElectionID = "d632xu17.giantswarm.io"
+
+func newShard(namespaces []string, selector string) sharding.Shard {
+	shard, err := sharding.New(namespaces, selector)
+	Expect(err).NotTo(HaveOccurred())
+	return shard
+}
+
+func newGCPCluster(namespace string, labels map[string]string) *capg.GCPCluster {
+	return &capg.GCPCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-gcp-cluster",
+			Namespace: namespace,
+			Labels:    labels,
+		},
+	}
+}
+
+var _ = Describe("Shard", func() {
+	Describe("New", func() {
+		It("trims, drops empty and sorts the namespaces", func() {
+			shard := newShard([]string{" org-b", "", "org-a ", "  "}, "")
+			Expect(shard.Namespaces).To(Equal([]string{"org-a", "org-b"}))
+		})
+
+		It("parses the selector", func() {
+			shard := newShard(nil, "shard=a,tier!=test")
+			Expect(shard.Selector.String()).To(Equal("shard=a,tier!=test"))
+		})
+
+		It("creates the default shard from empty values", func() {
+			Expect(newShard(nil, "").IsDefault()).To(BeTrue())
+			Expect(newShard([]string{"", " "}, "").IsDefault()).To(BeTrue())
+		})
+
+		It("is not the default shard with namespaces or a selector", func() {
+			Expect(newShard([]string{"org-a"}, "").IsDefault()).To(BeFalse())
+			Expect(newShard(nil, "shard=a").IsDefault()).To(BeFalse())
+		})
+
+		It("fails for invalid selectors", func() {
+			_, err := sharding.New(nil, "shard in (a")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LeaderElectionID", func() {
+		It("returns the base ID for the default shard", func() {
+			Expect(newShard(nil, "").LeaderElectionID(baseLeaderElectionID)).To(Equal(baseLeaderElectionID))
+		})
+
+		It("keeps the domain of the base ID", func() {
+			id := newShard([]string{"org-a"}, "").LeaderElectionID(baseLeaderElectionID)
+			Expect(id).To(MatchRegexp(`^d632xu17-[0-9a-f]{8}\.giantswarm\.io$`))
+		})
+
+		It("appends the shard to base IDs without domain", func() {
+			id := newShard([]string{"org-a"}, "").LeaderElectionID("the-lease")
+			Expect(id).To(MatchRegexp(`^the-lease-[0-9a-f]{8}$`))
+		})
+
+		It("is stable for the same shard", func() {
+			first := newShard([]string{"org-a", "org-b"}, "shard=a").LeaderElectionID(baseLeaderElectionID)
+			second := newShard([]string{"org-b", " org-a"}, "shard=a").LeaderElectionID(baseLeaderElectionID)
+			Expect(first).To(Equal(second))
+		})
+
+		It("is distinct for different shards", func() {
+			ids := map[string]struct{}{}
+			for _, shard := range []sharding.Shard{
+				newShard([]string{"org-a"}, ""),
+				newShard([]string{"org-b"}, ""),
+				newShard([]string{"org-a", "org-b"}, ""),
+				newShard(nil, "shard=a"),
+				newShard(nil, "shard=b"),
+				newShard([]string{"org-a"}, "shard=a"),
+			} {
+				ids[shard.LeaderElectionID(baseLeaderElectionID)] = struct{}{}
+			}
+			Expect(ids).To(HaveLen(6))
+		})
+	})
+
+	DescribeTable("Contains",
+		func(namespaces []string, selector string, gcpCluster *capg.GCPCluster, expected bool) {
+			shard := newShard(namespaces, selector)
+			Expect(shard.Contains(gcpCluster)).To(Equal(expected))
+			Expect(shard.Predicate().Generic(event.GenericEvent{Object: gcpCluster})).To(Equal(expected))
+		},
+		Entry("the default shard contains everything", nil, "", newGCPCluster("org-a", nil), true),
+		Entry("clusters in a namespace of the shard", []string{"org-a", "org-b"}, "", newGCPCluster("org-b", nil), true),
+		Entry("clusters in other namespaces", []string{"org-a", "org-b"}, "", newGCPCluster("org-c", nil), false),
+		Entry("clusters matching the selector", nil, "shard=a", newGCPCluster("org-a", map[string]string{"shard": "a"}), true),
+		Entry("clusters not matching the selector", nil, "shard=a", newGCPCluster("org-a", map[string]string{"shard": "b"}), false),
+		Entry("clusters without labels", nil, "shard=a", newGCPCluster("org-a", nil), false),
+		Entry("clusters matching namespace and selector", []string{"org-a"}, "shard=a", newGCPCluster("org-a", map[string]string{"shard": "a"}), true),
+		Entry("clusters only matching the selector", []string{"org-a"}, "shard=a", newGCPCluster("org-b", map[string]string{"shard": "a"}), false),
+		Entry("clusters only matching the namespace", []string{"org-a"}, "shard=a", newGCPCluster("org-a", nil), false),
+	)
+})
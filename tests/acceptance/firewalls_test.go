@@ -39,6 +39,7 @@ var _ = Describe("Firewalls", func() {
 
 		name               string
 		firewallName       string
+		controlPlaneName   string
 		securityPolicyName string
 		cluster            *capi.Cluster
 		network            *computepb.Network
@@ -74,6 +75,7 @@ var _ = Describe("Firewalls", func() {
 		name = tests.GenerateGUID("test")
 		securityPolicyName = fmt.Sprintf("allow-%s-apiserver", name)
 		firewallName = fmt.Sprintf("allow-%s-bastion-ssh", name)
+		controlPlaneName = fmt.Sprintf("allow-%s-control-plane-api", name)
 		network = tests.GetDefaultNetwork(networks, gcpProject)
 		backendService := tests.CreateBackendService(backendServices, gcpProject, name)
 		address = tests.CreateIPAddress(addresses, gcpProject, name)
@@ -162,6 +164,7 @@ var _ = Describe("Firewalls", func() {
 		Expect(k8sClient.Delete(ctx, managementCluster)).To(Succeed())
 
 		tests.DeleteFirewall(firewalls, gcpProject, firewallName)
+		tests.DeleteFirewall(firewalls, gcpProject, controlPlaneName)
 		tests.DeleteSecurityPolicy(securityPolicies, gcpProject, securityPolicyName)
 		tests.DeleteRouter(routers, gcpProject, name)
 		tests.DeleteIPAddress(addresses, gcpProject, name)
@@ -192,6 +195,23 @@ var _ = Describe("Firewalls", func() {
 		expectedSourceRanges = append(expectedSourceRanges, defaultBastionHostAllowList...)
 		Expect(actualFirewall.SourceRanges).To(ConsistOf(expectedSourceRanges))
 
+		By("creating the control plane firewall rule")
+		getControlPlaneFirewall := &computepb.GetFirewallRequest{
+			Firewall: controlPlaneName,
+			Project:  gcpProject,
+		}
+		var controlPlaneFirewall *computepb.Firewall
+		Eventually(func() error {
+			var err error
+			controlPlaneFirewall, err = firewalls.Get(ctx, getControlPlaneFirewall)
+			return err
+		}).Should(Succeed())
+
+		Expect(controlPlaneFirewall.TargetTags).To(ConsistOf(fmt.Sprintf("%s-control-plane", name)))
+		Expect(controlPlaneFirewall.SourceTags).To(ConsistOf(name))
+		Expect(controlPlaneFirewall.Allowed).To(HaveLen(1))
+		Expect(controlPlaneFirewall.Allowed[0].Ports).To(ConsistOf("6443"))
+
 		By("creating the kube api security policy")
 		getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
 			Project:        gcpProject,
@@ -211,10 +231,10 @@ var _ = Describe("Firewalls", func() {
 		rules := tests.MapRulesByPriority(securityPolicy.Rules)
 
 		By("creating the user specified rule in the policy")
-		userRule := rules[0]
+		userRule := rules[1]
 		Expect(*userRule.Action).To(Equal(security.ActionAllow))
 		Expect(*userRule.Description).To(Equal("allow user specified ips to connect to kubernetes api"))
-		Expect(*userRule.Priority).To(Equal(int32(0)))
+		Expect(*userRule.Priority).To(Equal(int32(1)))
 		Expect(userRule.Match).NotTo(BeNil())
 		Expect(userRule.Match.Config).NotTo(BeNil())
 		Expect(userRule.Match.Config.SrcIpRanges).To(ConsistOf(
@@ -223,28 +243,28 @@ var _ = Describe("Firewalls", func() {
 		))
 
 		By("creating the default MC NAT IPs rule in the policy")
-		defaultMCNATRule := rules[1]
+		defaultMCNATRule := rules[2]
 		Expect(*defaultMCNATRule.Action).To(Equal(security.ActionAllow))
 		Expect(*defaultMCNATRule.Description).To(Equal("allow MC NAT IPs"))
-		Expect(*defaultMCNATRule.Priority).To(Equal(int32(1)))
+		Expect(*defaultMCNATRule.Priority).To(Equal(int32(2)))
 		Expect(defaultMCNATRule.Match).NotTo(BeNil())
 		Expect(defaultMCNATRule.Match.Config).NotTo(BeNil())
-		Expect(defaultMCNATRule.Match.Config.SrcIpRanges).To(ConsistOf(*address.Address))
+		Expect(defaultMCNATRule.Match.Config.SrcIpRanges).To(ConsistOf(*address.Address + "/32"))
 
 		By("creating the default WC NAT IPs rule in the policy")
-		defaultWCNATRule := rules[2]
+		defaultWCNATRule := rules[3]
 		Expect(*defaultWCNATRule.Action).To(Equal(security.ActionAllow))
 		Expect(*defaultWCNATRule.Description).To(Equal("allow WC NAT IPs"))
-		Expect(*defaultWCNATRule.Priority).To(Equal(int32(2)))
+		Expect(*defaultWCNATRule.Priority).To(Equal(int32(3)))
 		Expect(defaultWCNATRule.Match).NotTo(BeNil())
 		Expect(defaultWCNATRule.Match.Config).NotTo(BeNil())
-		Expect(defaultWCNATRule.Match.Config.SrcIpRanges).To(ConsistOf(*address.Address))
+		Expect(defaultWCNATRule.Match.Config.SrcIpRanges).To(ConsistOf(*address.Address + "/32"))
 
 		By("creating the default allow list rule in the policy")
-		defaultAllowListRule := rules[3]
+		defaultAllowListRule := rules[4]
 		Expect(*defaultAllowListRule.Action).To(Equal(security.ActionAllow))
 		Expect(*defaultAllowListRule.Description).To(Equal("allow default IP ranges"))
-		Expect(*defaultAllowListRule.Priority).To(Equal(int32(3)))
+		Expect(*defaultAllowListRule.Priority).To(Equal(int32(4)))
 		Expect(defaultAllowListRule.Match).NotTo(BeNil())
 		Expect(defaultAllowListRule.Match.Config).NotTo(BeNil())
 		Expect(defaultAllowListRule.Match.Config.SrcIpRanges).To(ConsistOf(defaultAPIAllowList))
@@ -279,6 +299,12 @@ var _ = Describe("Firewalls", func() {
 			return err
 		}).Should(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
 
+		By("removing the control plane firewall rule")
+		Eventually(func() error {
+			_, err := firewalls.Get(ctx, getControlPlaneFirewall)
+			return err
+		}).Should(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+
 		By("removing the security policy")
 		Eventually(func() error {
 			_, err := securityPolicies.Get(ctx, getSecurityPolicy)
@@ -18,6 +18,7 @@ import (
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
 	. "github.com/giantswarm/capg-firewall-rule-operator/tests/matchers"
 )
 
@@ -29,6 +30,14 @@ const (
 	backendServiceProtocol = "TCP"
 )
 
+// RetryConfig is used by the GCP clients under test, so that transient
+// errors don't make the tests flaky.
+var RetryConfig = google.RetryConfig{
+	Attempts:     google.DefaultRetryAttempts,
+	InitialDelay: google.DefaultRetryInitialDelay,
+	MaxDelay:     google.DefaultRetryMaxDelay,
+}
+
 func GenerateGUID(prefix string) string {
 	guid := uuid.NewString()
 
@@ -11,9 +11,11 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	"k8s.io/apimachinery/pkg/types"
 	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 
 	"github.com/giantswarm/capg-firewall-rule-operator/pkg/firewall"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
 	"github.com/giantswarm/capg-firewall-rule-operator/tests"
 	. "github.com/giantswarm/capg-firewall-rule-operator/tests/matchers"
 )
@@ -74,9 +76,10 @@ var _ = Describe("Client", func() {
 			Name:         name,
 			TargetTags:   []string{"first-tag", "second-tag"},
 			SourceRanges: []string{"10.0.0.0/32", "127.0.0.0/24"},
+			SourceTags:   []string{"source-tag"},
 		}
 
-		client = firewall.NewClient(firewalls)
+		client = firewall.NewClient(firewalls, tests.RetryConfig, nil)
 	})
 
 	AfterEach(func() {
@@ -85,7 +88,7 @@ var _ = Describe("Client", func() {
 
 	Describe("ApplyRule", func() {
 		It("creates a firewall rule in GCP", func() {
-			err := client.ApplyRule(ctx, cluster, rule)
+			_, err := client.ApplyRule(ctx, cluster, rule)
 			Expect(err).NotTo(HaveOccurred())
 
 			req := &computepb.GetFirewallRequest{
@@ -107,15 +110,49 @@ var _ = Describe("Client", func() {
 			Expect(actualFirewall.Allowed[1].IPProtocol).To(Equal(to.StringP("tcp")))
 			Expect(actualFirewall.Allowed[1].Ports).To(ConsistOf("8080", "9090"))
 			Expect(actualFirewall.SourceRanges).To(ConsistOf("10.0.0.0/32", "127.0.0.0/24"))
+			Expect(actualFirewall.SourceTags).To(ConsistOf("source-tag"))
+		})
+
+		When("operations are tracked", func() {
+			var operations *google.OperationTracker
+
+			BeforeEach(func() {
+				operations = google.NewOperationTracker()
+				client = firewall.NewClient(firewalls, tests.RetryConfig, operations)
+			})
+
+			It("creates the rule without waiting for the operation", func() {
+				pending, err := client.ApplyRule(ctx, cluster, rule)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending).To(BeTrue())
+				Expect(operations.HasPending(types.NamespacedName{})).To(BeTrue())
+
+				Eventually(func() bool {
+					pending, err := client.ApplyRule(ctx, cluster, rule)
+					Expect(err).NotTo(HaveOccurred())
+					return pending
+				}).Should(BeFalse())
+				Expect(operations.HasPending(types.NamespacedName{})).To(BeFalse())
+
+				req := &computepb.GetFirewallRequest{
+					Firewall: name,
+					Project:  gcpProject,
+				}
+				actualFirewall, err := firewalls.Get(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualFirewall.SourceRanges).To(ConsistOf("10.0.0.0/32", "127.0.0.0/24"))
+			})
 		})
 
 		When("the firewall rule already exists", func() {
 			BeforeEach(func() {
-				err := client.ApplyRule(ctx, cluster, rule)
+				_, err := client.ApplyRule(ctx, cluster, rule)
 				Expect(err).NotTo(HaveOccurred())
 
 				rule.Description = "capg-firewall-rule-operator test firewall with another description"
 				rule.Direction = firewall.DirectionEgress
+				rule.SourceTags = nil
+				rule.SourceServiceAccounts = nil
 				rule.TargetTags = []string{"third-tag", "fourth-tag"}
 				rule.Allowed = []firewall.Allowed{
 					{
@@ -127,7 +164,7 @@ var _ = Describe("Client", func() {
 			})
 
 			It("updates the rule", func() {
-				err := client.ApplyRule(ctx, cluster, rule)
+				_, err := client.ApplyRule(ctx, cluster, rule)
 				Expect(err).NotTo(HaveOccurred())
 
 				req := &computepb.GetFirewallRequest{
@@ -148,13 +185,126 @@ var _ = Describe("Client", func() {
 			})
 		})
 
+		When("applying a deny egress rule", func() {
+			BeforeEach(func() {
+				rule.Allowed = nil
+				rule.Denied = []firewall.Denied{
+					{
+						IPProtocol: firewall.ProtocolAll,
+					},
+				}
+				rule.Direction = firewall.DirectionEgress
+				rule.Priority = to.Int32P(65000)
+				rule.SourceRanges = nil
+				rule.SourceTags = nil
+				rule.DestinationRanges = []string{"0.0.0.0/0"}
+			})
+
+			It("creates the deny rule", func() {
+				_, err := client.ApplyRule(ctx, cluster, rule)
+				Expect(err).NotTo(HaveOccurred())
+
+				req := &computepb.GetFirewallRequest{
+					Firewall: name,
+					Project:  gcpProject,
+				}
+				actualFirewall, err := firewalls.Get(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*actualFirewall.Direction).To(Equal(firewall.DirectionEgress))
+				Expect(*actualFirewall.Priority).To(Equal(int32(65000)))
+				Expect(actualFirewall.Allowed).To(BeEmpty())
+				Expect(actualFirewall.Denied).To(HaveLen(1))
+				Expect(actualFirewall.Denied[0].IPProtocol).To(Equal(to.StringP("all")))
+				Expect(actualFirewall.DestinationRanges).To(ConsistOf("0.0.0.0/0"))
+			})
+		})
+
 		When("applying an empty rule", func() {
 			It("returns an error", func() {
-				err := client.ApplyRule(ctx, cluster, firewall.Rule{})
+				_, err := client.ApplyRule(ctx, cluster, firewall.Rule{})
 				Expect(err).To(HaveOccurred())
 			})
 		})
 
+		DescribeTable("when the rule is invalid",
+			func(modify func(*firewall.Rule), expectedError string) {
+				modify(&rule)
+				_, err := client.ApplyRule(ctx, cluster, rule)
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+
+				req := &computepb.GetFirewallRequest{
+					Firewall: name,
+					Project:  gcpProject,
+				}
+				_, err = firewalls.Get(ctx, req)
+				Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+			},
+			Entry("it has no protocols", func(r *firewall.Rule) {
+				r.Allowed = nil
+			}, "must have allowed or denied protocols"),
+			Entry("it has both allowed and denied protocols", func(r *firewall.Rule) {
+				r.Denied = []firewall.Denied{{IPProtocol: firewall.ProtocolTCP}}
+			}, "can not have both allowed and denied protocols"),
+			Entry("the direction is invalid", func(r *firewall.Rule) {
+				r.Direction = "SIDEWAYS"
+			}, `direction "SIDEWAYS" is invalid`),
+			Entry("it has target tags and target service accounts", func(r *firewall.Rule) {
+				r.TargetServiceAccounts = []string{"sa@project.iam.gserviceaccount.com"}
+			}, "can not have both target tags and target service accounts"),
+			Entry("it has source tags and source service accounts", func(r *firewall.Rule) {
+				r.TargetTags = nil
+				r.SourceServiceAccounts = []string{"sa@project.iam.gserviceaccount.com"}
+			}, "can not have both source tags and source service accounts"),
+			Entry("it has source service accounts and target tags", func(r *firewall.Rule) {
+				r.SourceTags = nil
+				r.SourceServiceAccounts = []string{"sa@project.iam.gserviceaccount.com"}
+			}, "can not have both source service accounts and target tags"),
+			Entry("it has source tags and target service accounts", func(r *firewall.Rule) {
+				r.TargetTags = nil
+				r.TargetServiceAccounts = []string{"sa@project.iam.gserviceaccount.com"}
+			}, "can not have both source tags and target service accounts"),
+			Entry("it is an egress rule with source tags", func(r *firewall.Rule) {
+				r.Direction = firewall.DirectionEgress
+			}, "egress firewall rule can not have source tags or source service accounts"),
+			Entry("it is an egress rule with source service accounts", func(r *firewall.Rule) {
+				r.Direction = firewall.DirectionEgress
+				r.SourceTags = nil
+				r.TargetTags = nil
+				r.SourceServiceAccounts = []string{"sa@project.iam.gserviceaccount.com"}
+			}, "egress firewall rule can not have source tags or source service accounts"),
+			Entry("the priority is out of range", func(r *firewall.Rule) {
+				r.Priority = to.Int32P(70000)
+			}, "priority 70000 is not between"),
+			Entry("the log metadata is invalid", func(r *firewall.Rule) {
+				r.Logging = firewall.Logging{Enabled: true, Metadata: "SOME_METADATA"}
+			}, `log metadata "SOME_METADATA" is invalid`),
+		)
+
+		When("the rule is disabled and has logging enabled", func() {
+			BeforeEach(func() {
+				rule.Disabled = true
+				rule.Logging = firewall.Logging{
+					Enabled:  true,
+					Metadata: firewall.LogMetadataExcludeAll,
+				}
+			})
+
+			It("creates the rule with the options", func() {
+				_, err := client.ApplyRule(ctx, cluster, rule)
+				Expect(err).NotTo(HaveOccurred())
+
+				req := &computepb.GetFirewallRequest{
+					Firewall: name,
+					Project:  gcpProject,
+				}
+				actualFirewall, err := firewalls.Get(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*actualFirewall.Disabled).To(BeTrue())
+				Expect(*actualFirewall.LogConfig.Enable).To(BeTrue())
+				Expect(*actualFirewall.LogConfig.Metadata).To(Equal(firewall.LogMetadataExcludeAll))
+			})
+		})
+
 		When("applying a rule with only the required values", func() {
 			It("does not return an error", func() {
 				minimalRule := firewall.Rule{
@@ -166,7 +316,7 @@ var _ = Describe("Client", func() {
 						},
 					},
 				}
-				err := client.ApplyRule(ctx, cluster, minimalRule)
+				_, err := client.ApplyRule(ctx, cluster, minimalRule)
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
@@ -178,7 +328,7 @@ var _ = Describe("Client", func() {
 			})
 
 			It("returns an error", func() {
-				err := client.ApplyRule(ctx, cluster, rule)
+				_, err := client.ApplyRule(ctx, cluster, rule)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -188,7 +338,7 @@ var _ = Describe("Client", func() {
 				canceledCtx, cancel := context.WithCancel(ctx)
 				cancel()
 
-				err := client.ApplyRule(canceledCtx, cluster, rule)
+				_, err := client.ApplyRule(canceledCtx, cluster, rule)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -196,7 +346,8 @@ var _ = Describe("Client", func() {
 
 	Describe("DeleteRule", func() {
 		BeforeEach(func() {
-			Expect(client.ApplyRule(ctx, cluster, rule)).To(Succeed())
+			_, err := client.ApplyRule(ctx, cluster, rule)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("deletes the firewall", func() {
@@ -223,7 +374,7 @@ var _ = Describe("Client", func() {
 				canceledCtx, cancel := context.WithCancel(ctx)
 				cancel()
 
-				err := client.ApplyRule(canceledCtx, cluster, rule)
+				_, err := client.ApplyRule(canceledCtx, cluster, rule)
 				Expect(err).To(HaveOccurred())
 			})
 		})
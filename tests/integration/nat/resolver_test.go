@@ -71,7 +71,7 @@ var _ = Describe("Client", func() {
 		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
 
 		gcpClusters := k8sclient.NewGCPCluster(k8sClient)
-		resolver = nat.NewIPResolver(gcpClusters, addresses, routers)
+		resolver = nat.NewIPResolver(gcpClusters, addresses, routers, tests.RetryConfig)
 	})
 
 	Describe("GetIPs", func() {
@@ -0,0 +1,83 @@
+package readiness_test
+
+import (
+	"context"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/readiness"
+	"github.com/giantswarm/capg-firewall-rule-operator/tests"
+)
+
+var _ = Describe("GCPChecker", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		project string
+		checker *readiness.GCPChecker
+	)
+
+	BeforeEach(func() {
+		SetDefaultEventuallyPollingInterval(time.Second)
+		SetDefaultEventuallyTimeout(time.Second * 60)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		project = gcpProject
+
+		firewalls, err := compute.NewFirewallsRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		securityPolicies, err := compute.NewSecurityPoliciesRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		backendServices, err := compute.NewBackendServicesRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		routers, err := compute.NewRoutersRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		addresses, err := compute.NewAddressesRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		getProject := func(context.Context) (string, error) {
+			return project, nil
+		}
+		checker = readiness.NewGCPChecker(getProject, firewalls, securityPolicies, backendServices, routers, addresses, readiness.DefaultCheckInterval)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("is not ready before the first check", func() {
+		Expect(checker.Check(nil)).To(MatchError(ContainSubstring("not been checked yet")))
+	})
+
+	It("is ready when the resources can be listed", func() {
+		go func() {
+			defer GinkgoRecover()
+			Expect(checker.Start(ctx)).To(Succeed())
+		}()
+
+		Eventually(func() error {
+			return checker.Check(nil)
+		}).Should(Succeed())
+	})
+
+	When("the project does not exist", func() {
+		BeforeEach(func() {
+			project = tests.GenerateGUID("does-not-exist")
+		})
+
+		It("is not ready", func() {
+			go func() {
+				defer GinkgoRecover()
+				Expect(checker.Start(ctx)).To(Succeed())
+			}()
+
+			Eventually(func() error {
+				return checker.Check(nil)
+			}).Should(MatchError(ContainSubstring("failed to list GCP resources")))
+		})
+	})
+})
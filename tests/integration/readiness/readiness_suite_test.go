@@ -0,0 +1,22 @@
+package readiness_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/tests"
+)
+
+var gcpProject string
+
+func TestReadiness(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Readiness Suite")
+}
+
+var _ = BeforeSuite(func() {
+	tests.GetEnvOrSkip("GOOGLE_APPLICATION_CREDENTIALS")
+	gcpProject = tests.GetEnvOrSkip("GCP_PROJECT_ID")
+})
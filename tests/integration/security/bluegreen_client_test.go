@@ -0,0 +1,227 @@
+package security_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
+	capg "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/google"
+	"github.com/giantswarm/capg-firewall-rule-operator/pkg/security"
+	"github.com/giantswarm/capg-firewall-rule-operator/tests"
+	. "github.com/giantswarm/capg-firewall-rule-operator/tests/matchers"
+)
+
+var _ = Describe("BlueGreenClient", func() {
+	var (
+		ctx context.Context
+
+		securityPolicies *compute.SecurityPoliciesClient
+		backendServices  *compute.BackendServicesClient
+		client           *security.BlueGreenClient
+
+		cluster *capg.GCPCluster
+		policy  security.Policy
+		name    string
+	)
+
+	getAttachedPolicyName := func() string {
+		getBackendService := &computepb.GetBackendServiceRequest{
+			Project:        gcpProject,
+			BackendService: name,
+		}
+		backendService, err := backendServices.Get(ctx, getBackendService)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backendService.SecurityPolicy).NotTo(BeNil())
+
+		return google.GetResourceName(*backendService.SecurityPolicy)
+	}
+
+	BeforeEach(func() {
+		SetDefaultEventuallyPollingInterval(time.Second)
+		SetDefaultEventuallyTimeout(time.Second * 60)
+
+		ctx = context.Background()
+		name = tests.GenerateGUID("test")
+
+		var err error
+		securityPolicies, err = compute.NewSecurityPoliciesRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		backendServices, err = compute.NewBackendServicesRESTClient(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		backendService := tests.CreateBackendService(backendServices, gcpProject, name)
+
+		cluster = &capg.GCPCluster{
+			Spec: capg.GCPClusterSpec{
+				Project: gcpProject,
+			},
+			Status: capg.GCPClusterStatus{
+				Network: capg.Network{
+					APIServerBackendService: backendService.SelfLink,
+				},
+			},
+		}
+
+		policy = security.Policy{
+			Name:          name,
+			Description:   tests.TestDescription,
+			DefaultAction: security.ActionDeny403,
+			Rules: []security.PolicyRule{
+				{
+					Action:         security.ActionAllow,
+					Description:    tests.TestDescription,
+					SourceIPRanges: []string{"10.0.0.0/24"},
+					Priority:       1,
+				},
+			},
+		}
+
+		client = security.NewBlueGreenClient(securityPolicies, backendServices, tests.RetryConfig, nil)
+	})
+
+	AfterEach(func() {
+		tests.DeleteBackendService(backendServices, gcpProject, name)
+		cluster.Status.Network.APIServerBackendService = nil
+		Expect(client.DeletePolicy(ctx, cluster, name)).To(Succeed())
+	})
+
+	Describe("ApplyPolicy", func() {
+		It("attaches a versioned security policy", func() {
+			err := client.ApplyPolicy(ctx, cluster, policy)
+			Expect(err).NotTo(HaveOccurred())
+
+			attachedPolicyName := getAttachedPolicyName()
+			Expect(attachedPolicyName).To(HavePrefix(name + "-"))
+
+			getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+				Project:        gcpProject,
+				SecurityPolicy: attachedPolicyName,
+			}
+			securityPolicy, err := securityPolicies.Get(ctx, getSecurityPolicy)
+			Expect(err).NotTo(HaveOccurred())
+
+			rules := tests.MapRulesByPriority(securityPolicy.Rules)
+			Expect(rules[1].Match.Config.SrcIpRanges).To(ConsistOf("10.0.0.0/24"))
+		})
+
+		When("the policy changes", func() {
+			var previousPolicyName string
+
+			BeforeEach(func() {
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				previousPolicyName = getAttachedPolicyName()
+
+				policy.Rules[0].SourceIPRanges = []string{"10.1.0.0/24"}
+			})
+
+			It("attaches a new version and deletes the previous one", func() {
+				err := client.ApplyPolicy(ctx, cluster, policy)
+				Expect(err).NotTo(HaveOccurred())
+
+				attachedPolicyName := getAttachedPolicyName()
+				Expect(attachedPolicyName).NotTo(Equal(previousPolicyName))
+				Expect(strings.HasPrefix(attachedPolicyName, name+"-")).To(BeTrue())
+
+				getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+					Project:        gcpProject,
+					SecurityPolicy: previousPolicyName,
+				}
+				_, err = securityPolicies.Get(ctx, getSecurityPolicy)
+				Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+			})
+		})
+
+		When("the policy has a break-glass rule", func() {
+			getAttachedRules := func() map[int32]*computepb.SecurityPolicyRule {
+				getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+					Project:        gcpProject,
+					SecurityPolicy: getAttachedPolicyName(),
+				}
+				securityPolicy, err := securityPolicies.Get(ctx, getSecurityPolicy)
+				Expect(err).NotTo(HaveOccurred())
+
+				return tests.MapRulesByPriority(securityPolicy.Rules)
+			}
+
+			expectBreakGlassRule := func() {
+				rules := getAttachedRules()
+				Expect(rules).To(HaveKey(security.BreakGlassRulePriority))
+				Expect(*rules[security.BreakGlassRulePriority].Description).To(Equal(security.BreakGlassRuleDescription))
+				Expect(rules[security.BreakGlassRulePriority].Match.Config.SrcIpRanges).To(ConsistOf("192.0.2.0/24"))
+			}
+
+			BeforeEach(func() {
+				policy.Rules = append(policy.Rules, security.PolicyRule{
+					Action:         security.ActionAllow,
+					Description:    security.BreakGlassRuleDescription,
+					SourceIPRanges: []string{"192.0.2.0/24"},
+					Priority:       security.BreakGlassRulePriority,
+				})
+			})
+
+			It("keeps the break-glass rule in every version", func() {
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				expectBreakGlassRule()
+
+				By("changing the user rule")
+				policy.Rules[0].SourceIPRanges = []string{"10.1.0.0/24"}
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				expectBreakGlassRule()
+
+				By("removing the break-glass rule from the policy")
+				policy.Rules = policy.Rules[:1]
+				policy.Rules[0].SourceIPRanges = []string{"10.2.0.0/24"}
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				expectBreakGlassRule()
+				Expect(getAttachedRules()[1].Match.Config.SrcIpRanges).To(ConsistOf("10.2.0.0/24"))
+
+				By("applying the same policy again")
+				previousPolicyName := getAttachedPolicyName()
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				Expect(getAttachedPolicyName()).To(Equal(previousPolicyName))
+			})
+		})
+
+		When("the policy does not change", func() {
+			It("keeps the attached version", func() {
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				previousPolicyName := getAttachedPolicyName()
+
+				Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+				Expect(getAttachedPolicyName()).To(Equal(previousPolicyName))
+			})
+		})
+	})
+
+	Describe("DeletePolicy", func() {
+		It("detaches and deletes the attached version", func() {
+			Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+			attachedPolicyName := getAttachedPolicyName()
+
+			Expect(client.DeletePolicy(ctx, cluster, name)).To(Succeed())
+
+			getBackendService := &computepb.GetBackendServiceRequest{
+				Project:        gcpProject,
+				BackendService: name,
+			}
+			backendService, err := backendServices.Get(ctx, getBackendService)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backendService.SecurityPolicy).To(BeNil())
+
+			getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+				Project:        gcpProject,
+				SecurityPolicy: attachedPolicyName,
+			}
+			_, err = securityPolicies.Get(ctx, getSecurityPolicy)
+			Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+		})
+	})
+})
@@ -75,7 +75,7 @@ var _ = Describe("Client", func() {
 			},
 		}
 
-		client = security.NewClient(securityPolicies, backendServices)
+		client = security.NewClient(securityPolicies, backendServices, tests.RetryConfig, nil)
 	})
 
 	AfterEach(func() {
@@ -197,6 +197,93 @@ var _ = Describe("Client", func() {
 				))
 			})
 
+			When("the policy changes the log level", func() {
+				BeforeEach(func() {
+					policy.LogLevel = security.LogLevelVerbose
+				})
+
+				It("updates the advanced options", func() {
+					err := client.ApplyPolicy(ctx, cluster, policy)
+					Expect(err).NotTo(HaveOccurred())
+
+					getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+						Project:        gcpProject,
+						SecurityPolicy: name,
+					}
+					securityPolicy, err := securityPolicies.Get(ctx, getSecurityPolicy)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(securityPolicy.AdvancedOptionsConfig).NotTo(BeNil())
+					Expect(*securityPolicy.AdvancedOptionsConfig.LogLevel).To(Equal(security.LogLevelVerbose))
+				})
+			})
+
+			When("the policy moves ranges between rules", func() {
+				BeforeEach(func() {
+					Expect(client.ApplyPolicy(ctx, cluster, policy)).To(Succeed())
+
+					policy.Rules[0].SourceIPRanges = []string{"10.255.0.0/24"}
+					policy.Rules[1].SourceIPRanges = []string{"10.1.0.0/24", "172.158.1.0/24"}
+				})
+
+				It("applies the new rules and removes the temporary rules", func() {
+					err := client.ApplyPolicy(ctx, cluster, policy)
+					Expect(err).NotTo(HaveOccurred())
+
+					getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+						Project:        gcpProject,
+						SecurityPolicy: name,
+					}
+					securityPolicy, err := securityPolicies.Get(ctx, getSecurityPolicy)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(securityPolicy.Rules).To(HaveLen(3))
+
+					rules := tests.MapRulesByPriority(securityPolicy.Rules)
+					Expect(rules[0].Match.Config.SrcIpRanges).To(ConsistOf("10.255.0.0/24"))
+					Expect(rules[3].Match.Config.SrcIpRanges).To(ConsistOf("10.1.0.0/24", "172.158.1.0/24"))
+					Expect(rules).NotTo(HaveKey(int32(3) + security.TemporaryRulePriorityOffset))
+				})
+			})
+
+			When("the current policy has a break-glass rule", func() {
+				BeforeEach(func() {
+					breakGlassPolicy := policy
+					breakGlassPolicy.Rules = []security.PolicyRule{
+						{
+							Action:         security.ActionAllow,
+							Description:    security.BreakGlassRuleDescription,
+							SourceIPRanges: []string{"198.51.100.0/24"},
+							Priority:       security.BreakGlassRulePriority,
+						},
+						{
+							Action:         security.ActionAllow,
+							Description:    tests.TestDescription,
+							SourceIPRanges: []string{"10.255.0.0/24"},
+							Priority:       3,
+						},
+					}
+					err := client.ApplyPolicy(ctx, cluster, breakGlassPolicy)
+					Expect(err).NotTo(HaveOccurred())
+
+					policy.Rules = policy.Rules[1:]
+				})
+
+				It("does not remove the break-glass rule", func() {
+					err := client.ApplyPolicy(ctx, cluster, policy)
+					Expect(err).NotTo(HaveOccurred())
+
+					getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+						Project:        gcpProject,
+						SecurityPolicy: name,
+					}
+					securityPolicy, err := securityPolicies.Get(ctx, getSecurityPolicy)
+					Expect(err).NotTo(HaveOccurred())
+
+					rules := tests.MapRulesByPriority(securityPolicy.Rules)
+					Expect(rules).To(HaveKey(security.BreakGlassRulePriority))
+					Expect(*rules[security.BreakGlassRulePriority].Description).To(Equal(security.BreakGlassRuleDescription))
+				})
+			})
+
 			When("the policy removes a rule", func() {
 				BeforeEach(func() {
 					policy.Rules = []security.PolicyRule{}
@@ -237,6 +324,24 @@ var _ = Describe("Client", func() {
 			})
 		})
 
+		When("the policy denies by default and has no allow rules", func() {
+			BeforeEach(func() {
+				policy.Rules = []security.PolicyRule{}
+			})
+
+			It("returns an error", func() {
+				err := client.ApplyPolicy(ctx, cluster, policy)
+				Expect(err).To(MatchError(ContainSubstring("has no allow rules")))
+
+				getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+					Project:        gcpProject,
+					SecurityPolicy: name,
+				}
+				_, err = securityPolicies.Get(ctx, getSecurityPolicy)
+				Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+			})
+		})
+
 		When("the cluster doesn't have a backend service yet", func() {
 			BeforeEach(func() {
 				cluster.Status.Network.APIServerBackendService = nil
@@ -292,11 +397,11 @@ var _ = Describe("Client", func() {
 			Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
 		})
 
-		When("the backend service hasn't been deleted", func() {
-			It("returns an error", func() {
+		When("the backend service of the cluster no longer exists", func() {
+			It("does not return an error", func() {
 				cluster.Status.Network.APIServerBackendService = to.StringP("example.com/interesting")
 				err := client.DeletePolicy(ctx, cluster, name)
-				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(HaveOccurred())
 			})
 		})
 
@@ -316,4 +421,31 @@ var _ = Describe("Client", func() {
 			})
 		})
 	})
+
+	Describe("DeletePolicy while the backend service exists", func() {
+		BeforeEach(func() {
+			err := client.ApplyPolicy(ctx, cluster, policy)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("detaches and deletes the security policy", func() {
+			err := client.DeletePolicy(ctx, cluster, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			getBackendService := &computepb.GetBackendServiceRequest{
+				Project:        gcpProject,
+				BackendService: name,
+			}
+			backendService, err := backendServices.Get(ctx, getBackendService)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backendService.SecurityPolicy).To(BeNil())
+
+			getSecurityPolicy := &computepb.GetSecurityPolicyRequest{
+				Project:        gcpProject,
+				SecurityPolicy: name,
+			}
+			_, err = securityPolicies.Get(ctx, getSecurityPolicy)
+			Expect(err).To(BeGoogleAPIErrorWithStatus(http.StatusNotFound))
+		})
+	})
 })